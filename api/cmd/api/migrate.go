@@ -0,0 +1,109 @@
+package goapi
+
+import (
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/system"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func newMigrateCmd() *cobra.Command {
+	migrateConfig, err := newConfig()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create migrate options")
+	}
+
+	// the migrate command always runs migrations explicitly
+	migrateConfig.Database.MigrateOnStartup = false
+
+	envHelpText := generateEnvHelpText(migrateConfig, "")
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the database migrations.",
+		Long:  "Manage the versioned SQL database migrations.",
+	}
+
+	upCmd := &cobra.Command{
+		Use:   "up",
+		Short: "Apply all pending migrations.",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return withMigrateStore(migrateConfig, func(postgresStore *store.PostgresStore) error {
+				return postgresStore.MigrateUp(cmd.Context())
+			})
+		},
+	}
+
+	var steps int
+	downCmd := &cobra.Command{
+		Use:   "down",
+		Short: "Roll back applied migrations.",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return withMigrateStore(migrateConfig, func(postgresStore *store.PostgresStore) error {
+				return postgresStore.MigrateDown(cmd.Context(), steps)
+			})
+		},
+	}
+	downCmd.Flags().IntVar(&steps, "steps", 1, "The number of migrations to roll back.")
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Print the current migration version.",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return withMigrateStore(migrateConfig, func(postgresStore *store.PostgresStore) error {
+				status, err := postgresStore.MigrationStatus(cmd.Context())
+				if err != nil {
+					return err
+				}
+				cmd.Printf("version: %d (dirty: %t)\n", status.Version, status.Dirty)
+				for _, migration := range status.Migrations {
+					mark := " "
+					if migration.Applied {
+						mark = "x"
+					}
+					cmd.Printf("[%s] %06d_%s\n", mark, migration.Version, migration.Name)
+				}
+				return nil
+			})
+		},
+	}
+
+	var dir string
+	createCmd := &cobra.Command{
+		Use:     "create NAME",
+		Short:   "Create a new empty up/down migration pair.",
+		Example: "migrate create add_comic_status",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files, err := store.CreateMigration(dir, args[0])
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				cmd.Println(file)
+			}
+			return nil
+		},
+	}
+	createCmd.Flags().StringVar(&dir, "dir", "api/pkg/store/migrations", "The migrations folder in the source tree.")
+
+	migrateCmd.AddCommand(upCmd, downCmd, statusCmd, createCmd)
+	migrateCmd.Long += "\n\nEnvironment Variables:\n\n" + envHelpText
+
+	return migrateCmd
+}
+
+// withMigrateStore connects to the database, runs fn and closes the connection
+func withMigrateStore(cfg *config.Config, fn func(postgresStore *store.PostgresStore) error) error {
+	system.SetupLogging()
+
+	postgresStore, err := store.NewPostgresStore(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer postgresStore.Close()
+
+	return fn(postgresStore)
+}
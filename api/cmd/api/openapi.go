@@ -0,0 +1,63 @@
+package goapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/server"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func newOpenAPICmd() *cobra.Command {
+	openAPIConfig, err := newConfig()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create openapi options")
+	}
+
+	var output string
+	openAPICmd := &cobra.Command{
+		Use:     "openapi",
+		Short:   "Write the OpenAPI document for the api.",
+		Long:    "Build the api routes without connecting to the database and write the OpenAPI document they describe.",
+		Example: "openapi --output frontend/src/openapi.json",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return writeOpenAPI(cmd, openAPIConfig, output)
+		},
+	}
+	openAPICmd.Flags().StringVar(&output, "output", "openapi.json", "The file to write, - for stdout.")
+
+	return openAPICmd
+}
+
+func writeOpenAPI(cmd *cobra.Command, cfg *config.Config, output string) error {
+	offlineStore, err := store.NewOfflineStore(cfg.Database)
+	if err != nil {
+		return err
+	}
+
+	apiServer, err := server.NewServer(cfg, offlineStore, nil)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(apiServer.OpenAPI(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode OpenAPI document: %w", err)
+	}
+	data = append(data, '\n')
+
+	if output == "-" {
+		_, err = cmd.OutOrStdout().Write(data)
+		return err
+	}
+	if err := os.WriteFile(output, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write OpenAPI document: %w", err)
+	}
+	cmd.Println(output)
+	return nil
+}
@@ -23,6 +23,8 @@ func NewRootCmd() *cobra.Command {
 	// Commands available on all platforms
 	RootCmd.AddCommand(newServeCmd())
 	RootCmd.AddCommand(newWorkerCmd())
+	RootCmd.AddCommand(newMigrateCmd())
+	RootCmd.AddCommand(newOpenAPICmd())
 	RootCmd.AddCommand(newVersionCommand())
 
 	return RootCmd
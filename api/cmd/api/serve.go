@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"syscall"
 
 	"github.com/binocarlos/kai-stack/api/pkg/config"
 	"github.com/binocarlos/kai-stack/api/pkg/jobqueue"
@@ -53,8 +54,8 @@ func serve(cmd *cobra.Command, cfg *config.Config) error {
 	ctx, cancel := context.WithCancel(cmd.Context())
 	defer cancel()
 
-	// Context ensures main goroutine waits until killed with ctrl+c:
-	ctx, signalCancel := signal.NotifyContext(ctx, os.Interrupt)
+	// Context ensures main goroutine waits until killed with ctrl+c or SIGTERM:
+	ctx, signalCancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer signalCancel()
 
 	postgresStore, err := store.NewPostgresStore(cfg.Database)
@@ -78,13 +79,6 @@ func serve(cmd *cobra.Command, cfg *config.Config) error {
 
 	log.Info().Msgf("Platinum server listening on %s:%d", cfg.WebServer.Host, cfg.WebServer.Port)
 
-	go func() {
-		err := server.ListenAndServe(ctx, cm)
-		if err != nil {
-			panic(err)
-		}
-	}()
-
-	<-ctx.Done()
-	return nil
+	// Blocks until the signal context is done and in-flight requests have drained
+	return server.ListenAndServe(ctx, cm)
 }
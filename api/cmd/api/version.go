@@ -3,6 +3,8 @@ package goapi
 import (
 	"fmt"
 
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+
 	"github.com/spf13/cobra"
 )
 
@@ -11,7 +13,7 @@ func newVersionCommand() *cobra.Command {
 		Use:   "version",
 		Short: "Print version",
 		Run: func(*cobra.Command, []string) {
-			fmt.Println("0.0.1")
+			fmt.Println(config.API_VERSION)
 		},
 	}
 	return versionCmd
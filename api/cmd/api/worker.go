@@ -2,11 +2,17 @@ package goapi
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 
 	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/health"
 	"github.com/binocarlos/kai-stack/api/pkg/jobqueue"
+	"github.com/binocarlos/kai-stack/api/pkg/metrics"
 	"github.com/binocarlos/kai-stack/api/pkg/store"
 	"github.com/binocarlos/kai-stack/api/pkg/system"
 
@@ -52,27 +58,64 @@ func worker(cmd *cobra.Command, cfg *config.Config) error {
 	ctx, cancel := context.WithCancel(cmd.Context())
 	defer cancel()
 
-	// Context ensures main goroutine waits until killed with ctrl+c:
-	ctx, signalCancel := signal.NotifyContext(ctx, os.Interrupt)
+	// Context ensures main goroutine waits until killed with ctrl+c or SIGTERM:
+	ctx, signalCancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer signalCancel()
 
 	postgresStore, err := store.NewPostgresStore(cfg.Database)
 	if err != nil {
 		return err
 	}
+	cm.RegisterCallback(postgresStore.Close)
 
 	workerClient, err := jobqueue.NewClient(ctx, cfg, postgresStore)
 	if err != nil {
 		return err
 	}
+	cm.RegisterCallback(workerClient.Close)
 
 	err = workerClient.Start()
 	if err != nil {
 		return err
 	}
 
+	if cfg.Health.WorkerPort != 0 {
+		checker := health.NewChecker(cfg.Health.Timeout)
+		checker.Add("postgres", postgresStore.Ping)
+		checker.Add("pgx_pool", workerClient.Ping)
+		checker.Add("river", workerClient.Status)
+		startHealthServer(cfg.Health, checker, cm)
+	}
+
 	log.Info().Msgf("Platinum worker listening for jobs")
 
 	<-ctx.Done()
-	return nil
+
+	// Let running jobs finish (or release them) before the pools are closed
+	return workerClient.Stop(cfg.Worker.ShutdownTimeout, cfg.Worker.HardShutdownTimeout)
+}
+
+// startHealthServer serves /healthz, /readyz and /metrics for the worker on a
+// small net/http listener that is shut down by the cleanup manager
+func startHealthServer(cfg config.Health, checker *health.Checker, cm *system.CleanupManager) {
+	addr := fmt.Sprintf("%s:%d", cfg.WorkerHost, cfg.WorkerPort)
+
+	mux := health.NewServeMux(checker)
+	mux.Handle("/metrics", metrics.Handler())
+
+	healthServer := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: cfg.Timeout,
+	}
+
+	go func() {
+		log.Info().Msgf("Platinum worker health listening on %s", addr)
+		err := healthServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("worker health listener failed")
+		}
+	}()
+
+	cm.RegisterCallbackWithContext(healthServer.Shutdown)
 }
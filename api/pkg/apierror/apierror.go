@@ -0,0 +1,170 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+	"github.com/rs/zerolog/log"
+)
+
+// CONTENT_TYPE is the media type of error responses
+const CONTENT_TYPE = "application/problem+json"
+
+// PROBLEM_TYPE is the problem type of every error - the code carries the detail
+// so the type is the RFC 7807 default and the title is the HTTP status text
+const PROBLEM_TYPE = "about:blank"
+
+// Error is an API error with a status and a stable code
+// Handlers return it and Handler renders it as a types.Problem
+type Error struct {
+	Status int
+	Code   types.ErrorCode
+	Detail string
+	Fields []types.FieldError
+	// cause is logged for server errors but never sent to the client
+	cause error
+}
+
+// New creates an error
+func New(status int, code types.ErrorCode, detail string) *Error {
+	return &Error{
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Wrap returns a copy of the error that records the cause for the logs
+func (e *Error) Wrap(cause error) *Error {
+	wrapped := *e
+	wrapped.cause = cause
+	return &wrapped
+}
+
+// BadRequest is a 400 with the given code
+func BadRequest(code types.ErrorCode, detail string) *Error {
+	return New(fiber.StatusBadRequest, code, detail)
+}
+
+// Unauthorized is a 401 - the request has no valid credentials
+func Unauthorized(detail string) *Error {
+	return New(fiber.StatusUnauthorized, types.ErrorCodeUnauthorized, detail)
+}
+
+// Forbidden is a 403 - the credentials are valid but not allowed to do this
+func Forbidden(detail string) *Error {
+	return New(fiber.StatusForbidden, types.ErrorCodeForbidden, detail)
+}
+
+// NotFound is a 404 with the NOT_FOUND code
+func NotFound(detail string) *Error {
+	return New(fiber.StatusNotFound, types.ErrorCodeNotFound, detail)
+}
+
+// Conflict is a 409 with the given code
+func Conflict(code types.ErrorCode, detail string) *Error {
+	return New(fiber.StatusConflict, code, detail)
+}
+
+// Internal is a 500 with the given code
+// the handler logs the underlying error, the detail is what the client sees
+func Internal(code types.ErrorCode, detail string) *Error {
+	return New(fiber.StatusInternalServerError, code, detail)
+}
+
+// Validation is a 400 listing the fields that failed validation
+func Validation(fields []types.FieldError) *Error {
+	err := BadRequest(types.ErrorCodeValidationFailed, "Validation failed")
+	err.Fields = fields
+	return err
+}
+
+// From converts any error returned by a handler to an *Error
+// Fiber errors (e.g. unknown routes or oversized bodies) keep their status
+// and anything else is an internal error
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return New(fiberErr.Code, statusCode(fiberErr.Code), fiberErr.Message)
+	}
+	return Internal(types.ErrorCodeInternalError, "Internal server error").Wrap(err)
+}
+
+// statusCode is the code used for errors that only have a status
+func statusCode(status int) types.ErrorCode {
+	switch status {
+	case fiber.StatusNotFound:
+		return types.ErrorCodeNotFound
+	case fiber.StatusMethodNotAllowed:
+		return types.ErrorCodeMethodNotAllowed
+	case fiber.StatusRequestEntityTooLarge:
+		return types.ErrorCodeRequestTooLarge
+	case fiber.StatusUnsupportedMediaType:
+		return types.ErrorCodeUnsupportedMediaType
+	case fiber.StatusPreconditionFailed:
+		return types.ErrorCodePreconditionFailed
+	case fiber.StatusUnauthorized:
+		return types.ErrorCodeUnauthorized
+	case fiber.StatusForbidden:
+		return types.ErrorCodeForbidden
+	}
+	if status >= fiber.StatusInternalServerError {
+		return types.ErrorCodeInternalError
+	}
+	return types.ErrorCodeInvalidRequest
+}
+
+// Status is the status code the error will be rendered with
+func Status(err error) int {
+	return From(err).Status
+}
+
+// Problem is the response body for the error
+func (e *Error) Problem(c fiber.Ctx) types.Problem {
+	return types.Problem{
+		Type:      PROBLEM_TYPE,
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Detail,
+		Instance:  c.Path(),
+		Code:      e.Code,
+		RequestID: requestid.FromContext(c),
+		Fields:    e.Fields,
+	}
+}
+
+// Handler is the fiber.ErrorHandler that renders every error as problem+json
+// errors that are not API errors were not expected by the handler so they are logged here
+func Handler(c fiber.Ctx, err error) error {
+	apiErr := From(err)
+
+	if apiErr.cause != nil && apiErr.Status >= fiber.StatusInternalServerError {
+		log.Error().
+			Err(err).
+			Str("code", string(apiErr.Code)).
+			Str("path", c.Path()).
+			Str("request_id", requestid.FromContext(c)).
+			Msg("Request failed")
+	}
+
+	return c.Status(apiErr.Status).JSON(apiErr.Problem(c), CONTENT_TYPE)
+}
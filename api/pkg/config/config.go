@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
@@ -11,42 +12,72 @@ type Config struct {
 	Database  Database
 	WebServer WebServer
 	Worker    Worker
+	Health    Health
+	Auth      Auth
 }
 
 type OpenAI struct {
 	APIKey string `envconfig:"OPENAI_KEY" description:"The api key for openAI" required:"true"`
 	URL    string `envconfig:"OPENAI_URL"  description:"The URL for openAI" required:"true"`
+	Model  string `envconfig:"OPENAI_MODEL" default:"gpt-4o-mini" description:"The chat model used to generate comic scripts."`
 }
 
 type Database struct {
-	Host            string        `envconfig:"POSTGRES_HOST" description:"The host to connect to the postgres server." required:"true"`
-	Port            int           `envconfig:"POSTGRES_PORT_OVERRIDE" default:"5432" description:"The port to connect to the postgres server."`
-	Database        string        `envconfig:"POSTGRES_DATABASE" default:"forum" description:"The database to connect to the postgres server."`
-	Username        string        `envconfig:"POSTGRES_USER" description:"The username to connect to the postgres server." required:"true"`
-	Password        string        `envconfig:"POSTGRES_PASSWORD" description:"The password to connect to the postgres server." required:"true"`
-	SSL             bool          `envconfig:"POSTGRES_SSL" default:"false"`
-	Schema          string        `envconfig:"POSTGRES_SCHEMA"` // Defaults to public
-	AutoMigrate     bool          `envconfig:"POSTGRES_AUTO_MIGRATE" default:"true" description:"Should we automatically run the migrations?"`
-	MaxConns        int           `envconfig:"POSTGRES_MAX_CONNS" default:"50"`
-	IdleConns       int           `envconfig:"POSTGRES_IDLE_CONNS" default:"25"`
-	MaxConnLifetime time.Duration `envconfig:"POSTGRES_MAX_CONN_LIFETIME" default:"1h"`
-	MaxConnIdleTime time.Duration `envconfig:"POSTGRES_MAX_CONN_IDLE_TIME" default:"1m"`
+	Host             string        `envconfig:"POSTGRES_HOST" description:"The host to connect to the postgres server." required:"true"`
+	Port             int           `envconfig:"POSTGRES_PORT_OVERRIDE" default:"5432" description:"The port to connect to the postgres server."`
+	Database         string        `envconfig:"POSTGRES_DATABASE" default:"forum" description:"The database to connect to the postgres server."`
+	Username         string        `envconfig:"POSTGRES_USER" description:"The username to connect to the postgres server." required:"true"`
+	Password         string        `envconfig:"POSTGRES_PASSWORD" description:"The password to connect to the postgres server." required:"true"`
+	SSL              bool          `envconfig:"POSTGRES_SSL" default:"false"`
+	Schema           string        `envconfig:"POSTGRES_SCHEMA"` // Defaults to public
+	MigrateOnStartup bool          `envconfig:"POSTGRES_MIGRATE_ON_STARTUP" default:"true" description:"Should we apply the SQL migrations on startup?"`
+	MaxConns         int           `envconfig:"POSTGRES_MAX_CONNS" default:"50"`
+	IdleConns        int           `envconfig:"POSTGRES_IDLE_CONNS" default:"25"`
+	MaxConnLifetime  time.Duration `envconfig:"POSTGRES_MAX_CONN_LIFETIME" default:"1h"`
+	MaxConnIdleTime  time.Duration `envconfig:"POSTGRES_MAX_CONN_IDLE_TIME" default:"1m"`
 }
 
 type WebServer struct {
-	Host          string `envconfig:"SERVER_HOST" default:"0.0.0.0" description:"The host to bind the api server to."`
-	Port          int    `envconfig:"SERVER_PORT" default:"80" description:"The port to bind the api server to."`
-	URL           string `envconfig:"SERVER_URL" default:"http://localhost" description:"The base url for the api server."`
-	APIPath       string `envconfig:"SERVER_API_PATH" default:"/api/v1" description:"The base path for the api server."`
-	JWTSecret     string `envconfig:"SERVER_JWT_SECRET" description:"The secret for the jwt." required:"true"`
-	FixedPassword string `envconfig:"SERVER_FIXED_PASSWORD" description:"The fixed password for the api." required:"true"`
+	Host            string        `envconfig:"SERVER_HOST" default:"0.0.0.0" description:"The host to bind the api server to."`
+	Port            int           `envconfig:"SERVER_PORT" default:"80" description:"The port to bind the api server to."`
+	URL             string        `envconfig:"SERVER_URL" default:"http://localhost" description:"The base url for the api server."`
+	APIPath         string        `envconfig:"SERVER_API_PATH" default:"/api/v1" description:"The base path for the api server."`
+	JWTSecret       string        `envconfig:"SERVER_JWT_SECRET" description:"The secret for the jwt." required:"true"`
+	FixedPassword   string        `envconfig:"SERVER_FIXED_PASSWORD" description:"Bootstrap password that logs in as the admin root user - only used while SERVER_BOOTSTRAP_ADMIN is true."`
+	BootstrapAdmin  bool          `envconfig:"SERVER_BOOTSTRAP_ADMIN" default:"false" description:"Allow logging in as the admin root user with SERVER_FIXED_PASSWORD to set up a new install."`
+	Registration    bool          `envconfig:"SERVER_REGISTRATION_ENABLED" default:"false" description:"Can new users register an account?"`
+	ShutdownTimeout time.Duration `envconfig:"SERVER_SHUTDOWN_TIMEOUT" default:"30s" description:"How long to wait for in-flight requests to finish on shutdown."`
+	AccessTokenTTL  time.Duration `envconfig:"SERVER_ACCESS_TOKEN_TTL" default:"15m" description:"How long an access token (JWT) is valid for."`
+	RefreshTokenTTL time.Duration `envconfig:"SERVER_REFRESH_TOKEN_TTL" default:"720h" description:"How long a refresh token is valid for - each refresh issues a new one."`
+	EventsBuffer    int           `envconfig:"SERVER_EVENTS_BUFFER" default:"1000" description:"How many recent change events are kept for clients resuming the /events stream."`
+	EventsHeartbeat time.Duration `envconfig:"SERVER_EVENTS_HEARTBEAT" default:"15s" description:"How often an idle /events stream sends a comment to keep the connection open."`
 }
 
 type Worker struct {
-	Concurrency int    `envconfig:"WORKER_CONCURRENCY" default:"10" description:"The number parallel workers to run - this should be the number of cores on the machine."`
-	MaxAttempts int    `envconfig:"WORKER_MAX_ATTEMPTS" default:"3" description:"The maximum number of attempts for a job."`
-	APIURL      string `envconfig:"WORKER_SERVER_URL" default:"http://api" description:"The url for workers to connect to the api."`
-	Secret      string `envconfig:"WORKER_SECRET" description:"The secret for the worker." required:"true"`
+	Concurrency         int           `envconfig:"WORKER_CONCURRENCY" default:"10" description:"The number parallel workers to run - this should be the number of cores on the machine."`
+	MaxAttempts         int           `envconfig:"WORKER_MAX_ATTEMPTS" default:"3" description:"The maximum number of attempts for a job."`
+	APIURL              string        `envconfig:"WORKER_SERVER_URL" default:"http://api" description:"The url for workers to connect to the api."`
+	Secret              string        `envconfig:"WORKER_SECRET" description:"The secret for the worker." required:"true"`
+	ShutdownTimeout     time.Duration `envconfig:"WORKER_SHUTDOWN_TIMEOUT" default:"60s" description:"How long to wait for running jobs to finish on shutdown."`
+	HardShutdownTimeout time.Duration `envconfig:"WORKER_HARD_SHUTDOWN_TIMEOUT" default:"10s" description:"How long to wait for cancelled jobs to stop after the shutdown timeout."`
+	TrashRetention      time.Duration `envconfig:"WORKER_TRASH_RETENTION" default:"720h" description:"How long deleted comics stay in the trash before they are purged."`
+	TrashPurgeInterval  time.Duration `envconfig:"WORKER_TRASH_PURGE_INTERVAL" default:"1h" description:"How often the purge_trash job runs."`
+	WebhookTimeout      time.Duration `envconfig:"WORKER_WEBHOOK_TIMEOUT" default:"10s" description:"How long a webhook endpoint has to respond to a delivery."`
+	WebhookMaxAttempts  int           `envconfig:"WORKER_WEBHOOK_MAX_ATTEMPTS" default:"8" description:"The maximum number of attempts to send a webhook delivery."`
+	WebhookRetryBackoff time.Duration `envconfig:"WORKER_WEBHOOK_RETRY_BACKOFF" default:"30s" description:"The wait before retrying a webhook delivery - it doubles after every failed attempt."`
+	WebhookAllowPrivate bool          `envconfig:"WORKER_WEBHOOK_ALLOW_PRIVATE" default:"false" description:"Allow webhooks to plain http URLs and private, loopback and link-local addresses - only for local development."`
+}
+
+type Health struct {
+	Timeout    time.Duration `envconfig:"HEALTH_CHECK_TIMEOUT" default:"2s" description:"How long each readiness check can take before it fails."`
+	WorkerHost string        `envconfig:"HEALTH_WORKER_HOST" default:"0.0.0.0" description:"The host to bind the worker health and metrics listener to."`
+	WorkerPort int           `envconfig:"HEALTH_WORKER_PORT" default:"8081" description:"The port for the worker health and metrics listener - 0 disables it."`
+}
+
+type Auth struct {
+	RolePermissions     string        `envconfig:"AUTH_ROLE_PERMISSIONS" description:"Override the permissions of roles e.g. 'viewer=comics:read;user=comics:read,comics:write' - roles with rows in the role_permissions table use those instead."`
+	PermissionsCacheTTL time.Duration `envconfig:"AUTH_PERMISSIONS_CACHE_TTL" default:"30s" description:"How long role permissions loaded from the database are cached."`
+	RevokedTokensTTL    time.Duration `envconfig:"AUTH_REVOKED_TOKENS_CACHE_TTL" default:"5s" description:"How long the revoked access tokens loaded from the database are cached - a logout on another replica takes up to this long to apply."`
 }
 
 func LoadConfig() (Config, error) {
@@ -55,5 +86,22 @@ func LoadConfig() (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
+	if err := cfg.validate(); err != nil {
+		return Config{}, err
+	}
 	return cfg, nil
 }
+
+// validate rejects values that would fail at runtime rather than at startup
+func (cfg *Config) validate() error {
+	if cfg.WebServer.EventsHeartbeat <= 0 {
+		return fmt.Errorf("SERVER_EVENTS_HEARTBEAT must be greater than zero, got %s", cfg.WebServer.EventsHeartbeat)
+	}
+	if cfg.WebServer.EventsBuffer < 0 {
+		return fmt.Errorf("SERVER_EVENTS_BUFFER must not be negative, got %d", cfg.WebServer.EventsBuffer)
+	}
+	if cfg.Auth.RevokedTokensTTL <= 0 {
+		return fmt.Errorf("AUTH_REVOKED_TOKENS_CACHE_TTL must be greater than zero, got %s", cfg.Auth.RevokedTokensTTL)
+	}
+	return nil
+}
@@ -1,6 +1,50 @@
 package config
 
-// this is because we are using a fixed password
-// and so don't have the concept of a user database record
-// so this is the string we use
+// API_VERSION is reported by the version command and in the OpenAPI document
+const API_VERSION = "0.0.1"
+
+// this is the user ID issued when logging in with the
+// opt-in fixed password (SERVER_FIXED_PASSWORD) - it does
+// not have a record in the users table
 const FIXED_USER_ID = "root_user"
+
+// roles that are written into the JWT claims
+const (
+	ADMIN_ROLE  = "admin"
+	USER_ROLE   = "user"
+	VIEWER_ROLE = "viewer"
+)
+
+// permissions checked by RequirePermission and the resource routers
+// PERMISSION_ALL grants every permission
+const (
+	PERMISSION_ALL             = "*"
+	PERMISSION_COMICS_READ     = "comics:read"
+	PERMISSION_COMICS_WRITE    = "comics:write"
+	PERMISSION_COMICS_DELETE   = "comics:delete"
+	PERMISSION_COMICS_PUBLISH  = "comics:publish"
+	PERMISSION_JOBS_MANAGE     = "jobs:manage"
+	PERMISSION_AUDIT_READ      = "audit:read"
+	PERMISSION_WEBHOOKS_MANAGE = "webhooks:manage"
+)
+
+// DEFAULT_ROLE_PERMISSIONS is the role to permission table used unless it is
+// overridden by AUTH_ROLE_PERMISSIONS or the role_permissions table
+var DEFAULT_ROLE_PERMISSIONS = map[string][]string{
+	ADMIN_ROLE: {PERMISSION_ALL},
+	USER_ROLE: {
+		PERMISSION_COMICS_READ,
+		PERMISSION_COMICS_WRITE,
+		PERMISSION_COMICS_DELETE,
+		PERMISSION_COMICS_PUBLISH,
+		PERMISSION_WEBHOOKS_MANAGE,
+	},
+	VIEWER_ROLE: {PERMISSION_COMICS_READ},
+}
+
+// the minimum length of a password when registering
+const MIN_PASSWORD_LENGTH = 8
+
+// every API key starts with this prefix so it can be told apart from a JWT
+// in the Authorization header (and spotted by secret scanners)
+const API_KEY_PREFIX = "kai_"
@@ -0,0 +1,183 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// subscriptionBuffer is how many events a subscriber can fall behind by
+// before it is dropped - its client reconnects and resumes with Last-Event-ID
+const subscriptionBuffer = 64
+
+// reconnectDelay is the wait before listening again after the connection is lost
+const reconnectDelay = time.Second
+
+// Subscription receives the change events of one user
+// Events is closed when the subscriber falls behind or the broker stops
+type Subscription struct {
+	Events <-chan types.ChangeEvent
+	userID string
+	events chan types.ChangeEvent
+}
+
+// Broker LISTENs for change events published by any API replica and fans them
+// out to the subscriptions of the users they belong to
+// It keeps the most recent events so a reconnecting client can resume from the
+// last event it saw
+type Broker struct {
+	dsn        string
+	channel    string
+	bufferSize int
+
+	mu            sync.Mutex
+	buffer        []types.ChangeEvent
+	subscriptions map[*Subscription]struct{}
+	stopped       bool
+}
+
+// NewBroker creates a broker that listens on channel and keeps the last bufferSize events
+func NewBroker(dsn string, channel string, bufferSize int) *Broker {
+	return &Broker{
+		dsn:           dsn,
+		channel:       channel,
+		bufferSize:    bufferSize,
+		subscriptions: map[*Subscription]struct{}{},
+	}
+}
+
+// Run listens until ctx is cancelled, reconnecting whenever the connection is lost
+// Events published while it is reconnecting are missed
+// Every subscription is closed once it returns
+func (b *Broker) Run(ctx context.Context) {
+	defer b.stop()
+	for {
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Error().Err(err).Str("channel", b.channel).Msg("Lost change event listener, reconnecting")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// listen opens a connection, LISTENs on the channel and dispatches notifications until it fails
+func (b *Broker) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, b.dsn)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize()); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	log.Info().Str("channel", b.channel).Msg("Listening for change events")
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to wait for notification: %w", err)
+		}
+		var event types.ChangeEvent
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			log.Warn().Err(err).Msg("Ignoring invalid change event")
+			continue
+		}
+		b.dispatch(event)
+	}
+}
+
+// dispatch buffers an event and sends it to the subscriptions of its user
+// Subscriptions that are too far behind to take it are dropped
+func (b *Broker) dispatch(event types.ChangeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buffer = append(b.buffer, event)
+	if len(b.buffer) > b.bufferSize {
+		b.buffer = b.buffer[len(b.buffer)-b.bufferSize:]
+	}
+
+	for subscription := range b.subscriptions {
+		if subscription.userID != event.UserID {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			log.Warn().Str("user_id", subscription.userID).Msg("Dropping change event subscriber that fell behind")
+			b.remove(subscription)
+		}
+	}
+}
+
+// Subscribe starts receiving the events of a user
+// If lastEventID is set the user's buffered events after it are returned to be sent first -
+// when it is no longer buffered that is every buffered event with a greater ID
+func (b *Broker) Subscribe(userID string, lastEventID int64) (*Subscription, []types.ChangeEvent) {
+	events := make(chan types.ChangeEvent, subscriptionBuffer)
+	subscription := &Subscription{Events: events, userID: userID, events: events}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		close(events)
+		return subscription, nil
+	}
+	b.subscriptions[subscription] = struct{}{}
+
+	var replay []types.ChangeEvent
+	if lastEventID > 0 {
+		start := 0
+		for i, event := range b.buffer {
+			if event.ID == lastEventID {
+				start = i + 1
+				break
+			}
+		}
+		for _, event := range b.buffer[start:] {
+			if event.UserID == userID && (start > 0 || event.ID > lastEventID) {
+				replay = append(replay, event)
+			}
+		}
+	}
+	return subscription, replay
+}
+
+// Unsubscribe stops a subscription, it is safe to call more than once
+func (b *Broker) Unsubscribe(subscription *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(subscription)
+}
+
+// remove closes a subscription - the lock must be held
+func (b *Broker) remove(subscription *Subscription) {
+	if _, ok := b.subscriptions[subscription]; !ok {
+		return
+	}
+	delete(b.subscriptions, subscription)
+	close(subscription.events)
+}
+
+// stop closes every subscription so their streams end
+func (b *Broker) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = true
+	for subscription := range b.subscriptions {
+		b.remove(subscription)
+	}
+}
@@ -2,9 +2,12 @@ package jobqueue
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/metrics"
 	"github.com/binocarlos/kai-stack/api/pkg/store"
 	pgx "github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -19,9 +22,12 @@ import (
 // It exposes helper methods for enqueuing jobs.
 // The Worker side is started by worker.go in a separate process.
 type Client struct {
-	ctx   context.Context
-	river *river.Client[pgx.Tx]
-	pool  *pgxpool.Pool
+	ctx     context.Context
+	config  *config.Config
+	store   *store.PostgresStore
+	river   *river.Client[pgx.Tx]
+	pool    *pgxpool.Pool
+	started bool
 }
 
 // NewClient constructs an insert-only River client using the provided
@@ -34,18 +40,31 @@ func NewClient(ctx context.Context, config *config.Config, storeInstance *store.
 		return nil, fmt.Errorf("failed to create pgx pool for client: %w", err)
 	}
 
+	metrics.RegisterPgxPool("river", pool)
+
 	// Create client struct first (river will be populated later)
-	client := &Client{ctx: ctx, pool: pool}
+	client := &Client{ctx: ctx, config: config, store: storeInstance, pool: pool}
 
 	// Register workers, passing client as JobQueue interface
 	workers := river.NewWorkers()
 	river.AddWorker(workers, newTestWorker(config))
+	river.AddWorker(workers, newGenerateComicWorker(config, storeInstance.Comics(), storeInstance, client, NewOpenAIClient(config.OpenAI)))
+	river.AddWorker(workers, newPurgeTrashWorker(config, storeInstance))
+	river.AddWorker(workers, newDeliverWebhookWorker(config, storeInstance))
 
 	// Create River client with pgxv5 driver
 	// Note: River requires river.NewClient[pgx.Tx](...) for pgx with transaction support
 	c, err := river.NewClient(riverpgxv5.New(pool), &river.Config{
 		MaxAttempts:  config.Worker.MaxAttempts,
 		ErrorHandler: newErrorHandler(config),
+		Middleware: []rivertype.Middleware{
+			metrics.NewWorkerMiddleware(),
+			newEventsMiddleware(storeInstance),
+		},
+		// Periodic jobs are only enqueued by the elected leader of the started worker clients
+		PeriodicJobs: []*river.PeriodicJob{
+			newPurgeTrashPeriodicJob(config),
+		},
 		Queues: map[string]river.QueueConfig{
 			river.QueueDefault: {MaxWorkers: config.Worker.Concurrency},
 		},
@@ -62,15 +81,73 @@ func NewClient(ctx context.Context, config *config.Config, storeInstance *store.
 	return client, nil
 }
 
+// Start begins working jobs. River is started with a context that is not
+// cancelled with the client's context so that shutdown is controlled by Stop
+// rather than immediately cancelling every running job.
 func (c *Client) Start() error {
-	return c.river.Start(c.ctx)
+	if err := c.river.Start(context.WithoutCancel(c.ctx)); err != nil {
+		return err
+	}
+	c.started = true
+	return nil
+}
+
+// Ping checks the pgx pool can reach the database
+func (c *Client) Ping(ctx context.Context) error {
+	return c.pool.Ping(ctx)
+}
+
+// Status checks River can read its queues and, if this client was started
+// to work jobs, that it has not stopped
+func (c *Client) Status(ctx context.Context) error {
+	if c.started {
+		select {
+		case <-c.river.Stopped():
+			return fmt.Errorf("river client has stopped")
+		default:
+		}
+	}
+	if _, err := c.river.QueueList(ctx, river.NewQueueListParams().First(1)); err != nil {
+		return fmt.Errorf("failed to list river queues: %w", err)
+	}
+	return nil
+}
+
+// Stop shuts down a started client in two phases. The soft stop stops fetching
+// new jobs and waits up to softTimeout for running jobs to finish. Any jobs
+// still running then have their context cancelled and get up to hardTimeout
+// to return, after which River releases them back to the queue to be retried.
+func (c *Client) Stop(softTimeout time.Duration, hardTimeout time.Duration) error {
+	softCtx, softCancel := context.WithTimeout(context.Background(), softTimeout)
+	defer softCancel()
+
+	log.Info().Dur("timeout", softTimeout).Msg("stopping worker, waiting for running jobs to finish")
+	err := c.river.Stop(softCtx)
+	if err == nil {
+		log.Info().Msg("worker stopped")
+		return nil
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("failed to stop worker: %w", err)
+	}
+
+	hardCtx, hardCancel := context.WithTimeout(context.Background(), hardTimeout)
+	defer hardCancel()
+
+	log.Warn().Dur("timeout", hardTimeout).Msg("worker jobs did not finish in time, cancelling them")
+	if err := c.river.StopAndCancel(hardCtx); err != nil {
+		return fmt.Errorf("failed to hard stop worker: %w", err)
+	}
+	log.Info().Msg("worker stopped after cancelling running jobs")
+	return nil
 }
 
 // Close cleans up resources, specifically the database pool connection.
-func (c *Client) Close() {
+func (c *Client) Close() error {
 	if c.pool != nil {
 		c.pool.Close()
 	}
+	return nil
 }
 
 func (c *Client) EnqueueTest(
@@ -85,11 +162,14 @@ func (c *Client) EnqueueTest(
 
 // EnqueueJob is a generic method to enqueue any River job
 // The args parameter must implement river.JobArgs interface (have a Kind() method)
-func (c *Client) EnqueueJob(ctx context.Context, args river.JobArgs) error {
-	_, err := c.river.Insert(ctx, args, nil)
+// It returns the inserted job so callers can track it by ID
+// Jobs whose args have a user_id are streamed to that user's /events clients
+func (c *Client) EnqueueJob(ctx context.Context, args river.JobArgs) (*rivertype.JobRow, error) {
+	result, err := c.river.Insert(ctx, args, nil)
 	if err != nil {
-		return fmt.Errorf("failed to enqueue job: %w", err)
+		return nil, fmt.Errorf("failed to enqueue job: %w", err)
 	}
-	log.Info().Msgf("📋 Enqueued job: kind=%s", args.Kind())
-	return nil
+	log.Info().Msgf("📋 Enqueued job: id=%d kind=%s", result.Job.ID, args.Kind())
+	publishJobState(ctx, c.store, result.Job, result.Job.State)
+	return result.Job, nil
 }
@@ -0,0 +1,155 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/prompts"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/rs/zerolog/log"
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/gorm"
+)
+
+// GenerateComicArgs asks the worker to write a script for a comic using the LLM
+type GenerateComicArgs struct {
+	ComicID string `json:"comic_id"`
+	// UserID is the owner of the comic, the job's progress is streamed to them
+	UserID string `json:"user_id,omitempty"`
+}
+
+func (GenerateComicArgs) Kind() string { return "generate_comic" }
+
+// ChatCompleter is the part of the OpenAI client used by the worker
+// *openai.Client satisfies it - pointing OPENAI_URL at a fake server is enough for local testing
+type ChatCompleter interface {
+	CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+}
+
+// NewOpenAIClient returns an OpenAI client for any OpenAI compatible endpoint
+// OPENAI_URL is the API base URL including the version (e.g. https://api.openai.com/v1)
+func NewOpenAIClient(cfg config.OpenAI) *openai.Client {
+	clientConfig := openai.DefaultConfig(cfg.APIKey)
+	if cfg.URL != "" {
+		clientConfig.BaseURL = cfg.URL
+	}
+	return openai.NewClientWithConfig(clientConfig)
+}
+
+// ComicScriptStore is the part of the store the worker loads comics from and saves scripts to
+// *store.ComicRepository satisfies it
+type ComicScriptStore interface {
+	FindByID(id string, comic *types.Comic, filters ...store.Filter) error
+	SaveScript(id string, userID string, script *types.ComicScript) (*types.Comic, error)
+	Table() (string, error)
+}
+
+// ChangeEventPublisher streams changes to the /events clients of their user
+// *store.PostgresStore satisfies it
+type ChangeEventPublisher interface {
+	PublishChangeEvent(ctx context.Context, event *types.ChangeEvent) error
+}
+
+// WebhookDispatcher sends events to the webhook subscriptions of their user
+// *Client satisfies it
+type WebhookDispatcher interface {
+	DispatchWebhook(ctx context.Context, userID string, event types.WebhookEvent, data any)
+}
+
+type GenerateComicWorker struct {
+	river.WorkerDefaults[GenerateComicArgs]
+	config   *config.Config
+	comics   ComicScriptStore
+	events   ChangeEventPublisher
+	webhooks WebhookDispatcher
+	llm      ChatCompleter
+}
+
+func newGenerateComicWorker(config *config.Config, comics ComicScriptStore, events ChangeEventPublisher, webhooks WebhookDispatcher, llm ChatCompleter) *GenerateComicWorker {
+	return &GenerateComicWorker{
+		WorkerDefaults: river.WorkerDefaults[GenerateComicArgs]{},
+		config:         config,
+		comics:         comics,
+		events:         events,
+		webhooks:       webhooks,
+		llm:            llm,
+	}
+}
+
+// Work renders the prompt from the comic's config, asks the model for a script
+// and saves the validated script back onto the comic, keeping the previous comic as a revision
+// The owner's /events clients and comic.updated webhook subscriptions are told about the change
+// Missing comics and comics without a name are cancelled as retrying would not help,
+// LLM and validation errors are returned so River retries the job
+func (w *GenerateComicWorker) Work(ctx context.Context, job *river.Job[GenerateComicArgs]) error {
+	log.Info().Msgf("🟡 running generate comic job: %d %+v", job.ID, job.Args)
+
+	var comic types.Comic
+	if err := w.comics.FindByID(job.Args.ComicID, &comic); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return river.JobCancel(fmt.Errorf("comic %s not found", job.Args.ComicID))
+		}
+		return fmt.Errorf("failed to load comic %s: %w", job.Args.ComicID, err)
+	}
+
+	req, err := prompts.GetComicScriptPrompt(w.config.OpenAI.Model, comic.Config)
+	if err != nil {
+		return river.JobCancel(err)
+	}
+
+	resp, err := w.llm.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to generate comic script: %w", err)
+	}
+
+	script, err := prompts.ParseComicScriptResponse(resp)
+	if err != nil {
+		return fmt.Errorf("model returned an invalid comic script: %w", err)
+	}
+
+	updated, err := w.comics.SaveScript(comic.ID, job.Args.UserID, script)
+	if err != nil {
+		return fmt.Errorf("failed to save comic script: %w", err)
+	}
+	w.publishScriptSaved(ctx, updated)
+	w.webhooks.DispatchWebhook(ctx, updated.UserID, types.WebhookEventComicUpdated, updated)
+
+	log.Info().
+		Int64("job_id", job.ID).
+		Str("comic_id", comic.ID).
+		Int("panels", len(script.Panels)).
+		Msg("Generated comic script")
+
+	return nil
+}
+
+// publishScriptSaved streams the comic's update to its owner's /events clients
+func (w *GenerateComicWorker) publishScriptSaved(ctx context.Context, comic *types.Comic) {
+	resource, err := w.comics.Table()
+	if err != nil {
+		log.Error().Err(err).Str("comic_id", comic.ID).Msg("Failed to find the comics resource")
+		return
+	}
+	err = w.events.PublishChangeEvent(ctx, &types.ChangeEvent{
+		Kind:       types.ChangeEventKindResource,
+		UserID:     comic.UserID,
+		Resource:   resource,
+		ResourceID: comic.ID,
+		Action:     types.AuditActionUpdate,
+		CreatedAt:  time.Now().Unix(),
+	})
+	if err != nil {
+		log.Error().Err(err).Str("comic_id", comic.ID).Msg("Failed to publish comic script change")
+	}
+}
+
+// EnqueueGenerateComic enqueues a job that writes the script for a comic owned by userID
+func (c *Client) EnqueueGenerateComic(ctx context.Context, comicID string, userID string) (*rivertype.JobRow, error) {
+	return c.EnqueueJob(ctx, GenerateComicArgs{ComicID: comicID, UserID: userID})
+}
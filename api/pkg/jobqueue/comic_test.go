@@ -0,0 +1,262 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/prompts"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/gorm"
+)
+
+// fakeComicStore keeps comics and their revisions in memory in place of the comics table
+type fakeComicStore struct {
+	comics    map[string]*types.Comic
+	revisions []types.Revision
+}
+
+func (s *fakeComicStore) FindByID(id string, comic *types.Comic, _ ...store.Filter) error {
+	found, ok := s.comics[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	*comic = *found
+	return nil
+}
+
+func (s *fakeComicStore) SaveScript(id string, userID string, script *types.ComicScript) (*types.Comic, error) {
+	comic, ok := s.comics[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	previous, err := json.Marshal(comic)
+	if err != nil {
+		return nil, err
+	}
+	s.revisions = append(s.revisions, types.Revision{
+		Resource: "comics",
+		EntityID: id,
+		Number:   len(s.revisions) + 1,
+		UserID:   userID,
+		Snapshot: previous,
+	})
+	comic.Script = script
+	comic.Version++
+	updated := *comic
+	return &updated, nil
+}
+
+func (s *fakeComicStore) Table() (string, error) {
+	return "comics", nil
+}
+
+// fakePublisher records the change events the worker publishes
+type fakePublisher struct {
+	events []types.ChangeEvent
+}
+
+func (p *fakePublisher) PublishChangeEvent(_ context.Context, event *types.ChangeEvent) error {
+	p.events = append(p.events, *event)
+	return nil
+}
+
+// sentWebhook is a webhook event the worker dispatched
+type sentWebhook struct {
+	userID string
+	event  types.WebhookEvent
+	data   any
+}
+
+// fakeWebhooks records the webhook events the worker dispatches
+type fakeWebhooks struct {
+	sent []sentWebhook
+}
+
+func (w *fakeWebhooks) DispatchWebhook(_ context.Context, userID string, event types.WebhookEvent, data any) {
+	w.sent = append(w.sent, sentWebhook{userID: userID, event: event, data: data})
+}
+
+// newFakeOpenAIServer answers every chat completion with a call to the script function
+// with the given arguments and records the requests it was sent
+func newFakeOpenAIServer(t *testing.T, arguments string, requests *[]openai.ChatCompletionRequest) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*requests = append(*requests, req)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			ID:    "chatcmpl-test",
+			Model: req.Model,
+			Choices: []openai.ChatCompletionChoice{{
+				FinishReason: openai.FinishReasonToolCalls,
+				Message: openai.ChatCompletionMessage{
+					Role: openai.ChatMessageRoleAssistant,
+					ToolCalls: []openai.ToolCall{{
+						ID:   "call_1",
+						Type: openai.ToolTypeFunction,
+						Function: openai.FunctionCall{
+							Name:      prompts.COMIC_SCRIPT_FUNCTION_NAME,
+							Arguments: arguments,
+						},
+					}},
+				},
+			}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestGenerateComicWorker(t *testing.T, arguments string) (*GenerateComicWorker, *fakeComicStore, *fakePublisher, *fakeWebhooks, *[]openai.ChatCompletionRequest) {
+	t.Helper()
+	var requests []openai.ChatCompletionRequest
+	server := newFakeOpenAIServer(t, arguments, &requests)
+
+	cfg := &config.Config{}
+	cfg.OpenAI.APIKey = "test"
+	cfg.OpenAI.URL = server.URL + "/v1"
+	cfg.OpenAI.Model = "test-model"
+
+	comics := &fakeComicStore{comics: map[string]*types.Comic{
+		"comic-1": {
+			ID:     "comic-1",
+			UserID: "user-1",
+			Config: &types.ComicConfig{Name: "The Cat", Description: "A cat finds a hat"},
+		},
+	}}
+	events := &fakePublisher{}
+	webhooks := &fakeWebhooks{}
+	worker := newGenerateComicWorker(cfg, comics, events, webhooks, NewOpenAIClient(cfg.OpenAI))
+	return worker, comics, events, webhooks, &requests
+}
+
+func generateComicJob(comicID string) *river.Job[GenerateComicArgs] {
+	return &river.Job[GenerateComicArgs]{
+		JobRow: &rivertype.JobRow{ID: 1, Kind: GenerateComicArgs{}.Kind(), Attempt: 1, MaxAttempts: 3},
+		Args:   GenerateComicArgs{ComicID: comicID, UserID: "user-1"},
+	}
+}
+
+func TestGenerateComicWorkerSavesScript(t *testing.T) {
+	arguments := `{
+		"title": "The Cat in the Hat",
+		"synopsis": "A cat finds a hat",
+		"panels": [
+			{"description": "A cat on a wall", "dialogue": [{"character": "Cat", "text": "Meow"}]},
+			{"description": "The cat wears a hat", "caption": "Later", "dialogue": []}
+		]
+	}`
+	worker, comics, events, webhooks, requests := newTestGenerateComicWorker(t, arguments)
+
+	if err := worker.Work(context.Background(), generateComicJob("comic-1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("expected 1 request to the model, got %d", len(*requests))
+	}
+	req := (*requests)[0]
+	if req.Model != "test-model" {
+		t.Errorf("expected model test-model, got %q", req.Model)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Function.Name != prompts.COMIC_SCRIPT_FUNCTION_NAME {
+		t.Errorf("expected the %s tool, got %+v", prompts.COMIC_SCRIPT_FUNCTION_NAME, req.Tools)
+	}
+
+	script := comics.comics["comic-1"].Script
+	if script == nil {
+		t.Fatal("expected the script to be saved on the comic")
+	}
+	if script.Title != "The Cat in the Hat" || len(script.Panels) != 2 {
+		t.Errorf("unexpected script saved: %+v", script)
+	}
+	if script.Panels[1].Caption != "Later" || script.Panels[0].Dialogue[0].Text != "Meow" {
+		t.Errorf("unexpected panels saved: %+v", script.Panels)
+	}
+
+	if len(events.events) != 1 {
+		t.Fatalf("expected 1 change event, got %d", len(events.events))
+	}
+	event := events.events[0]
+	if event.UserID != "user-1" || event.Resource != "comics" || event.ResourceID != "comic-1" || event.Action != types.AuditActionUpdate {
+		t.Errorf("unexpected change event: %+v", event)
+	}
+
+	if len(comics.revisions) != 1 {
+		t.Fatalf("expected 1 revision, got %d", len(comics.revisions))
+	}
+	revision := comics.revisions[0]
+	if revision.EntityID != "comic-1" || revision.UserID != "user-1" {
+		t.Errorf("unexpected revision: %+v", revision)
+	}
+	var previous types.Comic
+	if err := json.Unmarshal(revision.Snapshot, &previous); err != nil {
+		t.Fatalf("failed to decode revision snapshot: %v", err)
+	}
+	if previous.Script != nil {
+		t.Errorf("expected the revision to hold the comic from before the script, got %+v", previous.Script)
+	}
+
+	if len(webhooks.sent) != 1 {
+		t.Fatalf("expected 1 webhook, got %d", len(webhooks.sent))
+	}
+	sent := webhooks.sent[0]
+	if sent.userID != "user-1" || sent.event != types.WebhookEventComicUpdated {
+		t.Errorf("unexpected webhook: %+v", sent)
+	}
+	if comic, ok := sent.data.(*types.Comic); !ok || comic.Script == nil || comic.Version != 1 {
+		t.Errorf("expected the webhook to send the updated comic, got %+v", sent.data)
+	}
+}
+
+func TestGenerateComicWorkerRejectsInvalidScript(t *testing.T) {
+	worker, comics, events, webhooks, _ := newTestGenerateComicWorker(t, `{"title": "The Cat", "panels": []}`)
+
+	err := worker.Work(context.Background(), generateComicJob("comic-1"))
+	if err == nil {
+		t.Fatal("expected an error for a script without panels")
+	}
+	// the job is retried as the model may do better next time
+	if errors.Is(err, &rivertype.JobCancelError{}) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+	if comics.comics["comic-1"].Script != nil {
+		t.Error("expected no script to be saved")
+	}
+	if len(events.events) != 0 {
+		t.Errorf("expected no change events, got %d", len(events.events))
+	}
+	if len(comics.revisions) != 0 || len(webhooks.sent) != 0 {
+		t.Errorf("expected no revisions or webhooks, got %d and %d", len(comics.revisions), len(webhooks.sent))
+	}
+}
+
+func TestGenerateComicWorkerCancelsMissingComic(t *testing.T) {
+	worker, _, _, _, requests := newTestGenerateComicWorker(t, `{}`)
+
+	err := worker.Work(context.Background(), generateComicJob("missing"))
+	if !errors.Is(err, &rivertype.JobCancelError{}) {
+		t.Fatalf("expected the job to be cancelled, got %v", err)
+	}
+	if len(*requests) != 0 {
+		t.Errorf("expected no request to the model, got %d", len(*requests))
+	}
+}
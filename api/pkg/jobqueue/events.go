@@ -0,0 +1,95 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/rs/zerolog/log"
+)
+
+// jobUserID returns the user a job was enqueued for from the user_id of its args
+// it is empty for system jobs, whose state changes are not streamed
+func jobUserID(job *rivertype.JobRow) string {
+	var args struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.Unmarshal(job.EncodedArgs, &args); err != nil {
+		return ""
+	}
+	return args.UserID
+}
+
+// publishJobState streams a job's new state to the /events clients of the user it was enqueued for
+// the job has already moved so a failure is only logged
+func publishJobState(ctx context.Context, store *store.PostgresStore, job *rivertype.JobRow, state rivertype.JobState) {
+	userID := jobUserID(job)
+	if userID == "" {
+		return
+	}
+	err := store.PublishChangeEvent(context.WithoutCancel(ctx), &types.ChangeEvent{
+		Kind:      types.ChangeEventKindJob,
+		UserID:    userID,
+		JobID:     job.ID,
+		JobKind:   job.Kind,
+		JobState:  string(state),
+		CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		log.Error().Err(err).Int64("job_id", job.ID).Str("state", string(state)).Msg("Failed to publish job state")
+	}
+}
+
+// EventsMiddleware streams the state transitions of every job attempt to the user it was enqueued for
+type EventsMiddleware struct {
+	river.MiddlewareDefaults
+	store *store.PostgresStore
+}
+
+var _ rivertype.WorkerMiddleware = &EventsMiddleware{}
+
+func newEventsMiddleware(store *store.PostgresStore) *EventsMiddleware {
+	return &EventsMiddleware{store: store}
+}
+
+func (m *EventsMiddleware) Work(ctx context.Context, job *rivertype.JobRow, doInner func(context.Context) error) (err error) {
+	publishJobState(ctx, m.store, job, rivertype.JobStateRunning)
+
+	defer func() {
+		// River turns a panic into a failed attempt so it is published as one
+		if recovered := recover(); recovered != nil {
+			publishJobState(ctx, m.store, job, failedJobState(job))
+			panic(recovered)
+		}
+		publishJobState(ctx, m.store, job, attemptJobState(job, err))
+	}()
+
+	return doInner(ctx)
+}
+
+// attemptJobState returns the state River moves a job to after an attempt returns err
+func attemptJobState(job *rivertype.JobRow, err error) rivertype.JobState {
+	switch {
+	case err == nil:
+		return rivertype.JobStateCompleted
+	case errors.Is(err, &rivertype.JobCancelError{}):
+		return rivertype.JobStateCancelled
+	case errors.Is(err, &rivertype.JobSnoozeError{}):
+		return rivertype.JobStateScheduled
+	default:
+		return failedJobState(job)
+	}
+}
+
+// failedJobState returns retryable until the job has run out of attempts
+func failedJobState(job *rivertype.JobRow) rivertype.JobState {
+	if job.Attempt >= job.MaxAttempts {
+		return rivertype.JobStateDiscarded
+	}
+	return rivertype.JobStateRetryable
+}
@@ -0,0 +1,121 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// ErrInvalidCursor is returned by ListJobs when the cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// JobListFilter restricts which jobs ListJobs returns
+// Empty slices mean no filtering on that field
+type JobListFilter struct {
+	Kinds  []string
+	States []rivertype.JobState
+	Queues []string
+	Limit  int
+	Cursor string
+}
+
+// GetJob loads a job by ID, returning rivertype.ErrNotFound if it doesn't exist
+func (c *Client) GetJob(ctx context.Context, id int64) (*rivertype.JobRow, error) {
+	return c.river.JobGet(ctx, id)
+}
+
+// ListJobs returns a page of jobs, newest first, along with the cursor for the next page
+func (c *Client) ListJobs(ctx context.Context, filter JobListFilter) ([]*rivertype.JobRow, string, error) {
+	params := river.NewJobListParams().
+		OrderBy(river.JobListOrderByID, river.SortOrderDesc)
+
+	if filter.Limit > 0 {
+		params = params.First(filter.Limit)
+	}
+	if len(filter.Kinds) > 0 {
+		params = params.Kinds(filter.Kinds...)
+	}
+	if len(filter.States) > 0 {
+		params = params.States(filter.States...)
+	}
+	if len(filter.Queues) > 0 {
+		params = params.Queues(filter.Queues...)
+	}
+	if filter.Cursor != "" {
+		var cursor river.JobListCursor
+		if err := cursor.UnmarshalText([]byte(filter.Cursor)); err != nil {
+			return nil, "", fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+		}
+		params = params.After(&cursor)
+	}
+
+	result, err := c.river.JobList(ctx, params)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if result.LastCursor != nil && filter.Limit > 0 && len(result.Jobs) == filter.Limit {
+		text, err := result.LastCursor.MarshalText()
+		if err != nil {
+			return nil, "", err
+		}
+		nextCursor = string(text)
+	}
+
+	return result.Jobs, nextCursor, nil
+}
+
+// CancelJob cancels a job - a running job has its context cancelled
+func (c *Client) CancelJob(ctx context.Context, id int64) (*rivertype.JobRow, error) {
+	return c.river.JobCancel(ctx, id)
+}
+
+// RetryJob makes a job available to be worked again immediately
+func (c *Client) RetryJob(ctx context.Context, id int64) (*rivertype.JobRow, error) {
+	return c.river.JobRetry(ctx, id)
+}
+
+// DeleteJob deletes a job, returning rivertype.ErrJobRunning if it is being worked
+func (c *Client) DeleteJob(ctx context.Context, id int64) (*rivertype.JobRow, error) {
+	return c.river.JobDelete(ctx, id)
+}
+
+// ToJob converts a River job row into the api representation
+func ToJob(row *rivertype.JobRow) types.Job {
+	job := types.Job{
+		ID:          row.ID,
+		Kind:        row.Kind,
+		State:       string(row.State),
+		Queue:       row.Queue,
+		Attempt:     row.Attempt,
+		MaxAttempts: row.MaxAttempts,
+		Args:        row.EncodedArgs,
+		Errors:      make([]types.JobError, 0, len(row.Errors)),
+		CreatedAt:   row.CreatedAt.Unix(),
+		ScheduledAt: row.ScheduledAt.Unix(),
+		AttemptedAt: unixOrZero(row.AttemptedAt),
+		FinalizedAt: unixOrZero(row.FinalizedAt),
+	}
+	for _, attemptError := range row.Errors {
+		job.Errors = append(job.Errors, types.JobError{
+			Attempt: attemptError.Attempt,
+			At:      attemptError.At.Unix(),
+			Error:   attemptError.Error,
+			Trace:   attemptError.Trace,
+		})
+	}
+	return job
+}
+
+func unixOrZero(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.Unix()
+}
@@ -46,7 +46,7 @@ func (w *TestWorker) Work(ctx context.Context, job *river.Job[TestArgs]) error {
 		Message: args.Message,
 	}
 
-	if err := postWorkerResult(*w.config, "/test", result); err != nil {
+	if err := postWorkerResult(*w.config, "/worker/test", result); err != nil {
 		log.Error().Msgf("error posting test job result: %s", err)
 		return err
 	}
@@ -0,0 +1,74 @@
+package jobqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/riverqueue/river"
+	"github.com/rs/zerolog/log"
+)
+
+// PurgeTrashArgs asks the worker to permanently remove entities that have been
+// in the trash for longer than the retention period
+type PurgeTrashArgs struct{}
+
+func (PurgeTrashArgs) Kind() string { return "purge_trash" }
+
+type PurgeTrashWorker struct {
+	river.WorkerDefaults[PurgeTrashArgs]
+	config *config.Config
+	store  *store.PostgresStore
+}
+
+func newPurgeTrashWorker(config *config.Config, store *store.PostgresStore) *PurgeTrashWorker {
+	return &PurgeTrashWorker{
+		WorkerDefaults: river.WorkerDefaults[PurgeTrashArgs]{},
+		config:         config,
+		store:          store,
+	}
+}
+
+// newPurgeTrashPeriodicJob runs the purge_trash job every WORKER_TRASH_PURGE_INTERVAL
+// and once when the worker starts
+func newPurgeTrashPeriodicJob(config *config.Config) *river.PeriodicJob {
+	return river.NewPeriodicJob(
+		river.PeriodicInterval(config.Worker.TrashPurgeInterval),
+		func() (river.JobArgs, *river.InsertOpts) {
+			return PurgeTrashArgs{}, nil
+		},
+		&river.PeriodicJobOpts{RunOnStart: true},
+	)
+}
+
+// Work removes the comics that were moved to the trash before the retention period
+// Their pages, panels and published versions are removed with them by the database
+// and their revisions are removed afterwards
+func (w *PurgeTrashWorker) Work(ctx context.Context, job *river.Job[PurgeTrashArgs]) error {
+	cutoff := time.Now().Add(-w.config.Worker.TrashRetention)
+
+	comics, err := w.store.Comics().Purge(cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to purge trashed comics: %w", err)
+	}
+
+	table, err := w.store.Comics().Table()
+	if err != nil {
+		return err
+	}
+	revisions, err := w.store.Revisions().PurgeOrphans(table)
+	if err != nil {
+		return fmt.Errorf("failed to purge revisions of purged comics: %w", err)
+	}
+
+	log.Info().
+		Int64("job_id", job.ID).
+		Int64("comics", comics).
+		Int64("revisions", revisions).
+		Time("cutoff", cutoff).
+		Msg("Purged trash")
+
+	return nil
+}
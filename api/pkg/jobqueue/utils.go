@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/metrics"
 	"github.com/riverqueue/river"
 	"github.com/riverqueue/river/rivertype"
 	"github.com/rs/zerolog/log"
@@ -52,17 +53,21 @@ func (errorHandler *JobErrorHandler) HandleError(ctx context.Context, job *river
 		Err(err).
 		Msg("Job error occurred")
 
-	if job.Attempt >= job.MaxAttempts {
-		postError := postWorkerResult(*errorHandler.config, "/error", ErrorResult{
+	if job.Attempt < job.MaxAttempts {
+		metrics.RecordJob(job, metrics.JobStateRetryable)
+	} else {
+		postError := postWorkerResult(*errorHandler.config, "/worker/error", ErrorResult{
 			JobID: job.ID,
 			Error: err.Error(),
 		})
 		if postError != nil {
 			log.Error().Msgf("error posting error job result: %s", postError)
+			metrics.RecordJob(job, metrics.JobStateCancelled)
 			return &river.ErrorHandlerResult{
 				SetCancelled: true,
 			}
 		}
+		metrics.RecordJob(job, metrics.JobStateDiscarded)
 	}
 	return nil
 }
@@ -76,13 +81,16 @@ func (errorHandler *JobErrorHandler) HandlePanic(ctx context.Context, job *river
 		Str("stack", trace).
 		Msg("Job panicked")
 
+	metrics.RecordJob(job, metrics.JobStatePanicked)
+
 	if job.Attempt >= job.MaxAttempts {
-		postError := postWorkerResult(*errorHandler.config, "/panic", PanicResult{
+		postError := postWorkerResult(*errorHandler.config, "/worker/panic", PanicResult{
 			JobID: job.ID,
 			Trace: trace,
 		})
 		if postError != nil {
 			log.Error().Msgf("error posting panic job result: %s", postError)
+			metrics.RecordJob(job, metrics.JobStateCancelled)
 			return &river.ErrorHandlerResult{
 				SetCancelled: true,
 			}
@@ -101,7 +109,7 @@ func postWorkerResult(config config.Config, apiPath string, body any) error {
 	// Ensure BasePath starts with a slash and apiPath doesn't (or vice versa)
 	// to avoid double slashes when joining.
 	fullPath := strings.TrimSuffix(baseURL.Path, "/") + "/" + strings.TrimPrefix(config.WebServer.APIPath, "/")
-	fullPath = strings.TrimSuffix(fullPath, "/") + strings.TrimPrefix(apiPath, "/")
+	fullPath = strings.TrimSuffix(fullPath, "/") + "/" + strings.TrimPrefix(apiPath, "/")
 
 	// Resolve the final path relative to the base URL
 	apiURL := baseURL.ResolveReference(&url.URL{Path: fullPath})
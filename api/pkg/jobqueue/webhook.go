@@ -0,0 +1,329 @@
+package jobqueue
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/google/uuid"
+	"github.com/riverqueue/river"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// headers sent with every webhook delivery
+const (
+	WebhookEventHeader     = "X-Kai-Event"
+	WebhookDeliveryHeader  = "X-Kai-Delivery"
+	WebhookSignatureHeader = "X-Kai-Signature-256"
+)
+
+// webhookResponseLimit is how much of an endpoint's response body is kept in the delivery log
+const webhookResponseLimit = 1024
+
+// webhookMaxBackoff caps the wait between attempts of a delivery
+const webhookMaxBackoff = 6 * time.Hour
+
+// SignWebhook returns the signature header value of a delivery body
+// It is "sha256=" and the hex HMAC-SHA256 of the body keyed with the subscription's secret
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// DeliverWebhookArgs asks the worker to POST a webhook delivery's payload to its subscription
+type DeliverWebhookArgs struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+func (DeliverWebhookArgs) Kind() string { return "deliver_webhook" }
+
+type DeliverWebhookWorker struct {
+	river.WorkerDefaults[DeliverWebhookArgs]
+	config *config.Config
+	store  *store.PostgresStore
+	http   *http.Client
+}
+
+func newDeliverWebhookWorker(config *config.Config, store *store.PostgresStore) *DeliverWebhookWorker {
+	return &DeliverWebhookWorker{
+		WorkerDefaults: river.WorkerDefaults[DeliverWebhookArgs]{},
+		config:         config,
+		store:          store,
+		http:           newWebhookHTTPClient(config),
+	}
+}
+
+// ErrWebhookURLNotAllowed is returned for webhook URLs the worker won't send to
+var ErrWebhookURLNotAllowed = errors.New("webhook URL is not allowed")
+
+// ValidateWebhookURL checks a subscription URL is https, unless WORKER_WEBHOOK_ALLOW_PRIVATE
+// is set for local development, and that a literal IP host is a public address
+// Hostnames are checked again when each delivery connects so DNS rebinding can't get around it
+func ValidateWebhookURL(config *config.Config, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("%w: invalid URL", ErrWebhookURLNotAllowed)
+	}
+	allowPrivate := config.Worker.WebhookAllowPrivate
+	if u.Scheme != "https" && !(allowPrivate && u.Scheme == "http") {
+		return fmt.Errorf("%w: the URL must use https", ErrWebhookURLNotAllowed)
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && !allowPrivate && !isPublicAddr(ip) {
+		return fmt.Errorf("%w: %s is not a public address", ErrWebhookURLNotAllowed, ip)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the RFC 6598 carrier-grade NAT range some clouds use internally
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublicAddr reports whether ip is a routable public address
+// loopback, private (RFC 1918 and fc00::/7), shared, link-local (e.g. 169.254.169.254),
+// multicast and unspecified addresses are not
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLoopback() &&
+		!sharedAddressSpace.Contains(ip)
+}
+
+// newWebhookHTTPClient returns the client deliveries are sent with
+// Every connection is refused unless it is to a public address - checked once the
+// hostname has resolved so a rebinding DNS record can't point it at an internal service -
+// and redirects are not followed so the endpoint's 3xx response is logged as a failure
+func newWebhookHTTPClient(config *config.Config) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: config.Worker.WebhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if config.Worker.WebhookAllowPrivate {
+				return nil
+			}
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrWebhookURLNotAllowed, err)
+			}
+			if !isPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s is not a public address", ErrWebhookURLNotAllowed, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: config.Worker.WebhookTimeout,
+		Transport: &http.Transport{
+			// no proxy - the dialer must see the endpoint's own address
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: config.Worker.WebhookTimeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// NextRetry backs off exponentially - WORKER_WEBHOOK_RETRY_BACKOFF after the first
+// failed attempt, doubling after each one up to a maximum of 6 hours
+func (w *DeliverWebhookWorker) NextRetry(job *river.Job[DeliverWebhookArgs]) time.Time {
+	backoff := w.config.Worker.WebhookRetryBackoff
+	for i := 1; i < job.Attempt && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return time.Now().Add(min(backoff, webhookMaxBackoff))
+}
+
+// Work POSTs the delivery's payload signed with the subscription's secret and logs the response
+// Any response other than a 2xx is returned as an error so River retries the delivery,
+// the delivery is marked as failed once it has run out of attempts
+// Deliveries whose subscription has been removed or whose URL is not allowed are cancelled
+func (w *DeliverWebhookWorker) Work(ctx context.Context, job *river.Job[DeliverWebhookArgs]) error {
+	var delivery types.WebhookDelivery
+	if err := w.store.WebhookDeliveries().FindByID(job.Args.DeliveryID, &delivery); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return river.JobCancel(fmt.Errorf("webhook delivery %s not found", job.Args.DeliveryID))
+		}
+		return fmt.Errorf("failed to load webhook delivery %s: %w", job.Args.DeliveryID, err)
+	}
+
+	var subscription types.WebhookSubscription
+	if err := w.store.WebhookSubscriptions().FindByID(delivery.SubscriptionID, &subscription); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return river.JobCancel(fmt.Errorf("webhook subscription %s not found", delivery.SubscriptionID))
+		}
+		return fmt.Errorf("failed to load webhook subscription %s: %w", delivery.SubscriptionID, err)
+	}
+
+	// subscriptions saved before the URL rules (or while private URLs were allowed) are never sent
+	if err := ValidateWebhookURL(w.config, subscription.URL); err != nil {
+		delivery.Status = types.WebhookDeliveryStatusFailed
+		delivery.Error = err.Error()
+		if err := w.store.WebhookDeliveries().RecordAttempt(&delivery); err != nil {
+			log.Error().Err(err).Str("delivery_id", delivery.ID).Msg("Failed to log webhook delivery attempt")
+		}
+		return river.JobCancel(err)
+	}
+
+	sendErr := w.send(ctx, &subscription, &delivery)
+
+	delivery.Status = types.WebhookDeliveryStatusSucceeded
+	delivery.Error = ""
+	if sendErr != nil {
+		delivery.Status = types.WebhookDeliveryStatusPending
+		if job.Attempt >= job.MaxAttempts {
+			delivery.Status = types.WebhookDeliveryStatusFailed
+		}
+		delivery.Error = sendErr.Error()
+	}
+	if err := w.store.WebhookDeliveries().RecordAttempt(&delivery); err != nil {
+		log.Error().Err(err).Str("delivery_id", delivery.ID).Msg("Failed to log webhook delivery attempt")
+	}
+
+	if sendErr != nil {
+		return fmt.Errorf("failed to deliver webhook %s: %w", delivery.ID, sendErr)
+	}
+
+	log.Info().
+		Int64("job_id", job.ID).
+		Str("delivery_id", delivery.ID).
+		Str("event", string(delivery.Event)).
+		Int("status", delivery.ResponseStatus).
+		Msg("Delivered webhook")
+
+	return nil
+}
+
+// send POSTs the payload and sets the response status and body on the delivery
+func (w *DeliverWebhookWorker) send(ctx context.Context, subscription *types.WebhookSubscription, delivery *types.WebhookDelivery) error {
+	delivery.ResponseStatus = 0
+	delivery.ResponseBody = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kai-stack-webhooks")
+	req.Header.Set(WebhookEventHeader, string(delivery.Event))
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(subscription.Secret, delivery.Payload))
+
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	delivery.ResponseStatus = resp.StatusCode
+	delivery.ResponseBody = string(body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// DispatchWebhook sends an event to every webhook subscription of the user that wants it
+// The change has already been saved so failures are only logged
+func (c *Client) DispatchWebhook(ctx context.Context, userID string, event types.WebhookEvent, data any) {
+	subscriptions, err := c.store.WebhookSubscriptions().LoadForEvent(userID, event)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Str("event", string(event)).Msg("Failed to load webhook subscriptions")
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		log.Error().Err(err).Str("event", string(event)).Msg("Failed to encode webhook data")
+		return
+	}
+	payload, err := json.Marshal(types.WebhookPayload{
+		ID:        uuid.New().String(),
+		Event:     event,
+		CreatedAt: time.Now().Unix(),
+		Data:      encoded,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("event", string(event)).Msg("Failed to encode webhook payload")
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		delivery := &types.WebhookDelivery{
+			ID:             uuid.New().String(),
+			SubscriptionID: subscription.ID,
+			Event:          event,
+			Payload:        payload,
+		}
+		if err := c.CreateWebhookDelivery(ctx, delivery); err != nil {
+			log.Error().Err(err).
+				Str("subscription_id", subscription.ID).
+				Str("event", string(event)).
+				Msg("Failed to dispatch webhook")
+		}
+	}
+}
+
+// CreateWebhookDelivery saves a pending delivery and inserts the job that sends it in one
+// transaction so the delivery log never shows a send with no job behind it
+// It is attempted up to WORKER_WEBHOOK_MAX_ATTEMPTS times
+func (c *Client) CreateWebhookDelivery(ctx context.Context, delivery *types.WebhookDelivery) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin webhook delivery transaction: %w", err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	result, err := c.river.InsertTx(ctx, tx, DeliverWebhookArgs{DeliveryID: delivery.ID}, &river.InsertOpts{
+		MaxAttempts: c.config.Worker.WebhookMaxAttempts,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook delivery: %w", err)
+	}
+
+	delivery.Status = types.WebhookDeliveryStatusPending
+	delivery.JobID = result.Job.ID
+	delivery.CreatedAt = time.Now().Unix()
+	_, err = tx.Exec(ctx, `INSERT INTO webhook_deliveries
+		(id, subscription_id, event, payload, status, redelivery_of, job_id, created_at)
+		VALUES ($1, $2, $3, $4::jsonb, $5, $6, $7, $8)`,
+		delivery.ID,
+		delivery.SubscriptionID,
+		string(delivery.Event),
+		string(delivery.Payload),
+		string(delivery.Status),
+		delivery.RedeliveryOf,
+		delivery.JobID,
+		delivery.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit webhook delivery: %w", err)
+	}
+	return nil
+}
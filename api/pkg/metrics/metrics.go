@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+const namespace = "kai"
+
+// Handler serves every registered metric in the prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDB exposes the sql.DBStats of a database pool
+func RegisterDB(name string, db *sql.DB) {
+	register(collectors.NewDBStatsCollector(db, name))
+}
+
+// register adds a collector to the default registry
+// registering the same collector twice (e.g. from two commands) is not an error
+func register(collector prometheus.Collector) {
+	err := prometheus.Register(collector)
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if err != nil && !errors.As(err, &alreadyRegistered) {
+		log.Error().Err(err).Msg("failed to register metrics collector")
+	}
+}
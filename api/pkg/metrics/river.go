@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// job states recorded by the counters - these match the River job states
+// apart from "started" (a job began an attempt) and "panicked"
+const (
+	JobStateStarted   = "started"
+	JobStateCompleted = string(rivertype.JobStateCompleted)
+	JobStateRetryable = string(rivertype.JobStateRetryable)
+	JobStateDiscarded = string(rivertype.JobStateDiscarded)
+	JobStateCancelled = string(rivertype.JobStateCancelled)
+	JobStatePanicked  = "panicked"
+)
+
+var jobsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "river",
+	Name:      "jobs_total",
+	Help:      "Number of job attempts by kind, resulting state and attempt number.",
+}, []string{"kind", "state", "attempt"})
+
+var jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "river",
+	Name:      "job_duration_seconds",
+	Help:      "Duration of job attempts by kind.",
+	Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+}, []string{"kind"})
+
+// RecordJob increments the job counter for a job attempt ending in state
+func RecordJob(job *rivertype.JobRow, state string) {
+	jobsTotal.WithLabelValues(job.Kind, state, strconv.Itoa(job.Attempt)).Inc()
+}
+
+// WorkerMiddleware records the start, duration and success of every job attempt
+// failures are recorded by the job error handler which knows if the job will retry
+type WorkerMiddleware struct {
+	river.MiddlewareDefaults
+}
+
+var _ rivertype.WorkerMiddleware = &WorkerMiddleware{}
+
+func NewWorkerMiddleware() *WorkerMiddleware {
+	return &WorkerMiddleware{}
+}
+
+func (m *WorkerMiddleware) Work(ctx context.Context, job *rivertype.JobRow, doInner func(context.Context) error) error {
+	RecordJob(job, JobStateStarted)
+	start := time.Now()
+
+	err := doInner(ctx)
+
+	jobDuration.WithLabelValues(job.Kind).Observe(time.Since(start).Seconds())
+	if err == nil {
+		RecordJob(job, JobStateCompleted)
+	}
+	return err
+}
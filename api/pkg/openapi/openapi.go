@@ -0,0 +1,330 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// VERSION is the OpenAPI version of the generated documents
+const VERSION = "3.1.0"
+
+// PROBLEM_CONTENT_TYPE is the media type of error responses (RFC 7807)
+const PROBLEM_CONTENT_TYPE = "application/problem+json"
+
+// Operation describes a route as it is registered with the router
+type Operation struct {
+	// Method is the HTTP method (e.g. "GET")
+	Method string
+	// Path uses the router syntax (e.g. "/comics/:id") and is relative to the api path
+	Path    string
+	Summary string
+	Tags    []string
+	// Query lists the query string parameters, path parameters are taken from Path
+	Query []Parameter
+	// Headers lists the request headers the route reads (e.g. If-Match)
+	Headers []Parameter
+	// Request is a value of the request body type - nil means no body
+	Request any
+	// RequestBodies are values of the request body types of routes that accept
+	// other media types than JSON, keyed by media type (e.g. "application/merge-patch+json")
+	RequestBodies map[string]any
+	// Response is a value of the success response type - nil means no body
+	Response any
+	// ResponseType is the media type of Response - defaults to application/json
+	ResponseType string
+	// Status is the success status code - defaults to 200
+	Status int
+	// Security names the security schemes that are accepted (any one of them)
+	// empty means the route is public
+	Security []string
+	// Permission is the permission the caller's roles must grant, if any
+	Permission string
+	// Errors lists the error responses the route can return
+	Errors []ErrorResponse
+}
+
+// Parameter is a query string parameter or request header
+type Parameter struct {
+	Name        string
+	Description string
+	// Type is the JSON Schema type of the value - defaults to "string"
+	Type     string
+	Required bool
+}
+
+// ErrorResponse is an error status and the code carried in the error body
+type ErrorResponse struct {
+	Status int
+	Code   string
+}
+
+// SecurityScheme is an OpenAPI security scheme object
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+}
+
+// Info is the OpenAPI info object
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document is a generated OpenAPI document
+type Document struct {
+	OpenAPI    string                               `json:"openapi"`
+	Info       Info                                 `json:"info"`
+	Servers    []ServerObject                       `json:"servers,omitempty"`
+	Paths      map[string]map[string]*PathOperation `json:"paths"`
+	Components Components                           `json:"components"`
+}
+
+// ServerObject is the base URL the paths are relative to
+type ServerObject struct {
+	URL string `json:"url"`
+}
+
+// Components holds the shared schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// PathOperation is an OpenAPI operation object
+type PathOperation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []ParameterObject     `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Permission  string                `json:"x-permission,omitempty"`
+}
+
+// ParameterObject is an OpenAPI parameter object
+type ParameterObject struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is an OpenAPI request body object
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is an OpenAPI response object
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+	// ErrorCodes lists the codes an error response can carry
+	ErrorCodes []string `json:"x-error-codes,omitempty"`
+}
+
+// MediaType is an OpenAPI media type object
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// pathParam matches router path parameters like :id
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// Registry collects the operations registered by the router
+type Registry struct {
+	info            Info
+	serverURL       string
+	errorBody       any
+	securitySchemes map[string]SecurityScheme
+
+	mu         sync.Mutex
+	operations []Operation
+}
+
+// NewRegistry creates an empty registry
+// serverURL is the base the paths are relative to and errorBody is a value of the
+// type every error response has
+func NewRegistry(info Info, serverURL string, errorBody any, securitySchemes map[string]SecurityScheme) *Registry {
+	return &Registry{
+		info:            info,
+		serverURL:       serverURL,
+		errorBody:       errorBody,
+		securitySchemes: securitySchemes,
+	}
+}
+
+// Add registers an operation
+func (r *Registry) Add(operation Operation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operations = append(r.operations, operation)
+}
+
+// Operations returns the registered operations in registration order
+func (r *Registry) Operations() []Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.operations)
+}
+
+// Document generates the OpenAPI document for the registered operations
+func (r *Registry) Document() *Document {
+	generator := newSchemaGenerator()
+	errorSchema := generator.schemaFor(r.errorBody)
+
+	doc := &Document{
+		OpenAPI: VERSION,
+		Info:    r.info,
+		Paths:   map[string]map[string]*PathOperation{},
+		Components: Components{
+			Schemas:         generator.components,
+			SecuritySchemes: r.securitySchemes,
+		},
+	}
+	if r.serverURL != "" {
+		doc.Servers = []ServerObject{{URL: r.serverURL}}
+	}
+
+	for _, operation := range r.Operations() {
+		path := pathParam.ReplaceAllString(operation.Path, "{$1}")
+		if len(path) > 1 {
+			path = strings.TrimSuffix(path, "/")
+		}
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*PathOperation{}
+		}
+		doc.Paths[path][strings.ToLower(operation.Method)] = r.pathOperation(generator, errorSchema, path, operation)
+	}
+
+	return doc
+}
+
+// pathOperation converts a registered operation to an OpenAPI operation object
+func (r *Registry) pathOperation(generator *schemaGenerator, errorSchema *Schema, path string, operation Operation) *PathOperation {
+	result := &PathOperation{
+		OperationID: operationID(operation.Method, path),
+		Summary:     operation.Summary,
+		Tags:        operation.Tags,
+		Responses:   map[string]Response{},
+		Permission:  operation.Permission,
+	}
+	if operation.Permission != "" {
+		result.Description = fmt.Sprintf("Requires the %s permission.", operation.Permission)
+	}
+
+	for _, match := range pathParam.FindAllStringSubmatch(operation.Path, -1) {
+		result.Parameters = append(result.Parameters, ParameterObject{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	result.Parameters = append(result.Parameters, parameterObjects("query", operation.Query)...)
+	result.Parameters = append(result.Parameters, parameterObjects("header", operation.Headers)...)
+
+	if operation.Request != nil || len(operation.RequestBodies) > 0 {
+		result.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{},
+		}
+		if operation.Request != nil {
+			result.RequestBody.Content = jsonContent(generator.schemaFor(operation.Request))
+		}
+		for mediaType, body := range operation.RequestBodies {
+			result.RequestBody.Content[mediaType] = MediaType{Schema: generator.schemaFor(body)}
+		}
+	}
+
+	status := operation.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	if operation.Response != nil {
+		schema := generator.schemaFor(operation.Response)
+		success.Content = jsonContent(schema)
+		if operation.ResponseType != "" {
+			success.Content = map[string]MediaType{operation.ResponseType: {Schema: schema}}
+		}
+	}
+	result.Responses[strconv.Itoa(status)] = success
+
+	codes := map[int][]string{}
+	for _, errorResponse := range operation.Errors {
+		if _, ok := codes[errorResponse.Status]; !ok {
+			codes[errorResponse.Status] = nil
+		}
+		if errorResponse.Code != "" && !slices.Contains(codes[errorResponse.Status], errorResponse.Code) {
+			codes[errorResponse.Status] = append(codes[errorResponse.Status], errorResponse.Code)
+		}
+	}
+	for errorStatus, errorCodes := range codes {
+		sort.Strings(errorCodes)
+		response := Response{
+			Description: http.StatusText(errorStatus),
+			ErrorCodes:  errorCodes,
+		}
+		if errorSchema != nil {
+			response.Content = map[string]MediaType{PROBLEM_CONTENT_TYPE: {Schema: errorSchema}}
+		}
+		result.Responses[strconv.Itoa(errorStatus)] = response
+	}
+
+	for _, scheme := range operation.Security {
+		result.Security = append(result.Security, map[string][]string{scheme: {}})
+	}
+
+	return result
+}
+
+// parameterObjects converts query or header parameters to OpenAPI parameter objects
+func parameterObjects(in string, params []Parameter) []ParameterObject {
+	var objects []ParameterObject
+	for _, param := range params {
+		paramType := param.Type
+		if paramType == "" {
+			paramType = "string"
+		}
+		objects = append(objects, ParameterObject{
+			Name:        param.Name,
+			In:          in,
+			Description: param.Description,
+			Required:    param.Required,
+			Schema:      &Schema{Type: paramType},
+		})
+	}
+	return objects
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+// operationID builds a stable ID from the method and path
+// e.g. GET /comics/{id}/pages becomes getComicsIdPages
+func operationID(method string, path string) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		id.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return id.String()
+}
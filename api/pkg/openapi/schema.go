@@ -0,0 +1,237 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema (draft 2020-12) as used by OpenAPI 3.1
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *float64           `json:"minLength,omitempty"`
+	MaxLength            *float64           `json:"maxLength,omitempty"`
+	MinItems             *float64           `json:"minItems,omitempty"`
+	MaxItems             *float64           `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	// packagePath matches the import path in front of a type name
+	// e.g. "github.com/binocarlos/kai-stack/api/pkg/types." in a generic type name
+	packagePath = regexp.MustCompile(`[\w.\-]+/|[\w\-]+\.`)
+)
+
+// schemaGenerator turns Go types into schemas, named structs become components
+// that are referenced with $ref so each one is only described once
+type schemaGenerator struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		components: map[string]*Schema{},
+		names:      map[reflect.Type]string{},
+	}
+}
+
+// schemaFor returns the schema of the value's type - nil values have no schema
+func (g *schemaGenerator) schemaFor(value any) *Schema {
+	if value == nil {
+		return nil
+	}
+	return g.schema(reflect.TypeOf(value))
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		// any JSON value
+		return &Schema{}
+	case t.Kind() != reflect.Struct && (t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)):
+		return &Schema{Type: "string"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// custom encodings can't be described from the Go type
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes byte slices as base64 strings
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	default:
+		return &Schema{}
+	}
+}
+
+// component registers a named struct as a component and returns its name
+func (g *schemaGenerator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := componentName(t)
+	g.names[t] = name
+	// reserve the name before walking the fields so recursive types terminate
+	g.components[name] = &Schema{}
+	*g.components[name] = *g.structSchema(t)
+	return name
+}
+
+// componentName is the type name without package paths or brackets
+// e.g. ListResponse[github.com/.../types.Comic] becomes ListResponseComic
+func componentName(t reflect.Type) string {
+	name := packagePath.ReplaceAllString(t.Name(), "")
+	return strings.NewReplacer("[", "", "]", "", ",", "", "*", "").Replace(name)
+}
+
+// structSchema describes the fields of a struct the way encoding/json writes them
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(schema, t)
+	return schema
+}
+
+func (g *schemaGenerator) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		// embedded structs without a json name are flattened into the parent
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schema(field.Type)
+		schema.Properties[name] = property
+
+		optional := field.Type.Kind() == reflect.Pointer
+		for _, option := range strings.Split(options, ",") {
+			if option == "omitempty" || option == "omitzero" {
+				optional = true
+			}
+		}
+		if applyValidateTag(property, field.Type, field.Tag.Get("validate")) {
+			optional = false
+		}
+		if !optional {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// applyValidateTag adds the constraints of a validate struct tag to a property
+// and reports whether the tag makes the property required
+// rules after dive apply to the elements of a slice and are not described
+func applyValidateTag(property *Schema, t reflect.Type, tag string) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		value, err := strconv.ParseFloat(param, 64)
+		hasValue := err == nil
+		switch {
+		case name == "dive":
+			return required
+		case name == "required":
+			required = true
+		case name == "pattern":
+			property.Pattern = param
+		case name == "oneof":
+			property.Enum = strings.Fields(param)
+		case name == "url":
+			property.Format = "uri"
+		case name == "email":
+			property.Format = "email"
+		case name == "gt" && hasValue:
+			property.ExclusiveMinimum = &value
+		case (name == "min" || name == "gte") && hasValue:
+			switch t.Kind() {
+			case reflect.String:
+				property.MinLength = &value
+			case reflect.Slice, reflect.Array:
+				property.MinItems = &value
+			default:
+				property.Minimum = &value
+			}
+		case (name == "max" || name == "lte") && hasValue:
+			switch t.Kind() {
+			case reflect.String:
+				property.MaxLength = &value
+			case reflect.Slice, reflect.Array:
+				property.MaxItems = &value
+			default:
+				property.Maximum = &value
+			}
+		}
+	}
+	return required
+}
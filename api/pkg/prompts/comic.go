@@ -1,53 +1,182 @@
 package prompts
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"strings"
 
+	"github.com/binocarlos/kai-stack/api/pkg/types"
 	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
-const AUTOCODER_CODING_RESULTS_FUNCTION_NAME = "coding_results"
+const COMIC_SCRIPT_FUNCTION_NAME = "generate_comic_script"
+
+// the model is asked for this many panels and anything outside the range is rejected
+const (
+	COMIC_SCRIPT_PANEL_COUNT = 6
+	COMIC_SCRIPT_MAX_PANELS  = 12
+)
+
+// GetComicScriptPrompt builds the chat request that asks the model to write a script
+// for the comic described by the config - the model must answer with a tool call
+func GetComicScriptPrompt(model string, comicConfig *types.ComicConfig) (openai.ChatCompletionRequest, error) {
+	if comicConfig == nil || strings.TrimSpace(comicConfig.Name) == "" {
+		return openai.ChatCompletionRequest{}, fmt.Errorf("comic name is required to generate a script")
+	}
 
-func GetComicExamplePrompt() (openai.ChatCompletionRequest, error) {
 	systemMessage := openai.ChatCompletionMessage{
-		Role:    "system",
-		Content: `This is the system message.`,
+		Role: openai.ChatMessageRoleSystem,
+		Content: fmt.Sprintf(`You are a comic book writer.
+You write short, visual comic scripts of about %d panels.
+Each panel has a description of what is drawn, an optional caption and the lines of dialogue spoken in it.
+Always answer by calling the %s function.`,
+			COMIC_SCRIPT_PANEL_COUNT,
+			COMIC_SCRIPT_FUNCTION_NAME,
+		),
+	}
+
+	description := strings.TrimSpace(comicConfig.Description)
+	if description == "" {
+		description = "(no description given - invent a story that fits the title)"
 	}
 
 	userMessage := openai.ChatCompletionMessage{
-		Role: "user",
-		Content: fmt.Sprintf(`Hello: %s`,
-			"world",
+		Role: openai.ChatMessageRoleUser,
+		Content: fmt.Sprintf(`Write the script for this comic.
+
+Title: %s
+
+Description:
+%s`,
+			comicConfig.Name,
+			description,
 		),
 	}
 
 	req := openai.ChatCompletionRequest{
+		Model:    model,
 		Messages: []openai.ChatCompletionMessage{systemMessage, userMessage},
 		Tools: []openai.Tool{
 			{
 				Type: openai.ToolTypeFunction,
 				Function: &openai.FunctionDefinition{
-					Name:        "generate_comics",
-					Description: "Generate comics for the given ids",
-					Parameters: jsonschema.Definition{
-						Type: jsonschema.Object,
-						Properties: map[string]jsonschema.Definition{
-							"ids": {
-								Type: jsonschema.Array,
-								Items: &jsonschema.Definition{
-									Type:        jsonschema.String,
-									Description: "The id of the comic",
+					Name:        COMIC_SCRIPT_FUNCTION_NAME,
+					Description: "Save the script for the comic",
+					Parameters:  comicScriptSchema(),
+				},
+			},
+		},
+		ToolChoice: openai.ToolChoice{
+			Type: openai.ToolTypeFunction,
+			Function: openai.ToolFunction{
+				Name: COMIC_SCRIPT_FUNCTION_NAME,
+			},
+		},
+	}
+
+	return req, nil
+}
+
+// comicScriptSchema is the JSON schema of types.ComicScript
+func comicScriptSchema() jsonschema.Definition {
+	return jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"title": {
+				Type:        jsonschema.String,
+				Description: "The title of the comic",
+			},
+			"synopsis": {
+				Type:        jsonschema.String,
+				Description: "A one paragraph summary of the story",
+			},
+			"panels": {
+				Type:        jsonschema.Array,
+				Description: "The panels of the comic in reading order",
+				Items: &jsonschema.Definition{
+					Type: jsonschema.Object,
+					Properties: map[string]jsonschema.Definition{
+						"description": {
+							Type:        jsonschema.String,
+							Description: "What is drawn in the panel",
+						},
+						"caption": {
+							Type:        jsonschema.String,
+							Description: "Narration shown in a caption box, if any",
+						},
+						"dialogue": {
+							Type:        jsonschema.Array,
+							Description: "The lines spoken in the panel",
+							Items: &jsonschema.Definition{
+								Type: jsonschema.Object,
+								Properties: map[string]jsonschema.Definition{
+									"character": {
+										Type:        jsonschema.String,
+										Description: "The name of the character speaking",
+									},
+									"text": {
+										Type:        jsonschema.String,
+										Description: "What the character says",
+									},
 								},
-								Description: "The ids of the comics to generate",
+								Required: []string{"character", "text"},
 							},
 						},
-						Required: []string{"ids"},
 					},
+					Required: []string{"description", "dialogue"},
 				},
 			},
 		},
+		Required: []string{"title", "synopsis", "panels"},
+	}
+}
+
+// ParseComicScriptResponse extracts the script from the model's tool call and validates it
+func ParseComicScriptResponse(resp openai.ChatCompletionResponse) (*types.ComicScript, error) {
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("response has no choices")
 	}
 
-	return req, nil
+	for _, toolCall := range resp.Choices[0].Message.ToolCalls {
+		if toolCall.Function.Name != COMIC_SCRIPT_FUNCTION_NAME {
+			continue
+		}
+		return ParseComicScript(toolCall.Function.Arguments)
+	}
+
+	return nil, fmt.Errorf("response did not call %s", COMIC_SCRIPT_FUNCTION_NAME)
+}
+
+// ParseComicScript decodes and validates the arguments of a generate_comic_script tool call
+func ParseComicScript(arguments string) (*types.ComicScript, error) {
+	var script types.ComicScript
+	decoder := json.NewDecoder(bytes.NewReader([]byte(arguments)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&script); err != nil {
+		return nil, fmt.Errorf("invalid %s arguments: %w", COMIC_SCRIPT_FUNCTION_NAME, err)
+	}
+
+	if strings.TrimSpace(script.Title) == "" {
+		return nil, fmt.Errorf("script title is required")
+	}
+	if len(script.Panels) == 0 {
+		return nil, fmt.Errorf("script has no panels")
+	}
+	if len(script.Panels) > COMIC_SCRIPT_MAX_PANELS {
+		return nil, fmt.Errorf("script has %d panels, the maximum is %d", len(script.Panels), COMIC_SCRIPT_MAX_PANELS)
+	}
+	for i, panel := range script.Panels {
+		if strings.TrimSpace(panel.Description) == "" {
+			return nil, fmt.Errorf("panel %d has no description", i+1)
+		}
+		for j, line := range panel.Dialogue {
+			if strings.TrimSpace(line.Character) == "" || strings.TrimSpace(line.Text) == "" {
+				return nil, fmt.Errorf("panel %d dialogue line %d needs a character and text", i+1, j+1)
+			}
+		}
+	}
+
+	return &script, nil
 }
@@ -0,0 +1,105 @@
+package prompts
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+)
+
+// scriptArguments returns tool call arguments for a script with the given number of panels
+func scriptArguments(t *testing.T, panels int) string {
+	t.Helper()
+	script := types.ComicScript{Title: "The Cat", Synopsis: "A cat finds a hat"}
+	for i := range panels {
+		script.Panels = append(script.Panels, types.ComicScriptPanel{
+			Description: fmt.Sprintf("Panel %d", i+1),
+			Dialogue:    []types.ComicDialogueLine{{Character: "Cat", Text: "Meow"}},
+		})
+	}
+	arguments, err := json.Marshal(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(arguments)
+}
+
+func TestParseComicScript(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments string
+		wantErr   string
+		wantPanel int
+	}{
+		{
+			name:      "valid",
+			arguments: scriptArguments(t, COMIC_SCRIPT_PANEL_COUNT),
+			wantPanel: COMIC_SCRIPT_PANEL_COUNT,
+		},
+		{
+			name:      "maximum panels",
+			arguments: scriptArguments(t, COMIC_SCRIPT_MAX_PANELS),
+			wantPanel: COMIC_SCRIPT_MAX_PANELS,
+		},
+		{
+			name:      "unknown field",
+			arguments: `{"title":"The Cat","panels":[{"description":"A cat","dialogue":[]}],"mood":"happy"}`,
+			wantErr:   `unknown field "mood"`,
+		},
+		{
+			name:      "unknown panel field",
+			arguments: `{"title":"The Cat","panels":[{"description":"A cat","dialogue":[],"camera":"wide"}]}`,
+			wantErr:   `unknown field "camera"`,
+		},
+		{
+			name:      "no panels",
+			arguments: scriptArguments(t, 0),
+			wantErr:   "script has no panels",
+		},
+		{
+			name:      "too many panels",
+			arguments: scriptArguments(t, COMIC_SCRIPT_MAX_PANELS+1),
+			wantErr:   "script has 13 panels, the maximum is 12",
+		},
+		{
+			name:      "no title",
+			arguments: `{"title":" ","panels":[{"description":"A cat","dialogue":[]}]}`,
+			wantErr:   "script title is required",
+		},
+		{
+			name:      "panel without description",
+			arguments: `{"title":"The Cat","panels":[{"description":"","dialogue":[]}]}`,
+			wantErr:   "panel 1 has no description",
+		},
+		{
+			name:      "dialogue without character",
+			arguments: `{"title":"The Cat","panels":[{"description":"A cat","dialogue":[{"character":"","text":"Meow"}]}]}`,
+			wantErr:   "panel 1 dialogue line 1 needs a character and text",
+		},
+		{
+			name:      "invalid JSON",
+			arguments: `{"title":`,
+			wantErr:   "invalid generate_comic_script arguments",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := ParseComicScript(tt.arguments)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(script.Panels) != tt.wantPanel {
+				t.Fatalf("expected %d panels, got %d", tt.wantPanel, len(script.Panels))
+			}
+		})
+	}
+}
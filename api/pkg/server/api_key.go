@@ -0,0 +1,257 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	// APIKeyIDContextKey is set when the request was authenticated with an API key
+	APIKeyIDContextKey ContextKey = "apiKeyID"
+
+	// apiKeyTouchInterval limits how often last_used_at is written for a key
+	apiKeyTouchInterval = time.Minute
+)
+
+// GetAPIKeyIDFromContext returns the ID of the API key used to authenticate the request
+func GetAPIKeyIDFromContext(c fiber.Ctx) (string, bool) {
+	keyID, ok := c.Locals(string(APIKeyIDContextKey)).(string)
+	return keyID, ok && keyID != ""
+}
+
+// newAPIKey returns a random API key and the hash that is stored for it
+func newAPIKey() (string, string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key := config.API_KEY_PREFIX + base64.RawURLEncoding.EncodeToString(data)
+	return key, hashSecret(key), nil
+}
+
+// authenticateAPIKey validates an API key and stores its user in the context
+// The roles in the context are the key's scopes that its user still has
+func (apiServer *StackAPIServer) authenticateAPIKey(c fiber.Ctx, key string) error {
+	apiKey, err := apiServer.store.APIKeys().FindActiveByHash(hashSecret(key))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Warn().
+			Str("path", c.Path()).
+			Str("ip", c.IP()).
+			Msg("Auth middleware: Invalid API key")
+		return apierror.Unauthorized("Invalid or revoked API key")
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Auth middleware: Failed to look up API key")
+		return apierror.Internal(types.ErrorCodeInternalError, "Failed to check authentication")
+	}
+
+	userRoles, err := apiServer.currentRoles(apiKey.UserID)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("userID", apiKey.UserID).
+			Str("path", c.Path()).
+			Msg("Auth middleware: API key user is not active")
+		return apierror.Unauthorized("Invalid or revoked API key")
+	}
+
+	roles := []string{}
+	for _, scope := range apiKey.Scopes {
+		if slices.Contains(userRoles, scope) {
+			roles = append(roles, scope)
+		}
+	}
+
+	if err := apiServer.store.APIKeys().TouchLastUsed(apiKey, apiKeyTouchInterval); err != nil {
+		log.Warn().Err(err).Str("api_key_id", apiKey.ID).Msg("Auth middleware: Failed to update API key last used")
+	}
+
+	log.Debug().
+		Str("userID", apiKey.UserID).
+		Str("apiKeyID", apiKey.ID).
+		Strs("roles", roles).
+		Str("path", c.Path()).
+		Msg("Auth middleware: API key authentication successful")
+
+	c.Locals(string(JWTUserIDContextKey), apiKey.UserID)
+	c.Locals(string(JWTUserRolesContextKey), roles)
+	c.Locals(string(APIKeyIDContextKey), apiKey.ID)
+
+	return nil
+}
+
+// currentRoles returns the roles a user has right now
+// The fixed admin user has no account and only exists while SERVER_BOOTSTRAP_ADMIN is enabled
+func (apiServer *StackAPIServer) currentRoles(userID string) ([]string, error) {
+	if userID == config.FIXED_USER_ID {
+		if !apiServer.fixedPasswordEnabled() {
+			return nil, fmt.Errorf("fixed password login is disabled")
+		}
+		return []string{config.ADMIN_ROLE}, nil
+	}
+	var account types.UserAccount
+	if err := apiServer.store.Users().FindByID(userID, &account); err != nil {
+		return nil, err
+	}
+	return account.Roles, nil
+}
+
+// RegisterAPIKeyRoutes registers the routes for users to manage their own API keys
+func (apiServer *StackAPIServer) RegisterAPIKeyRoutes() {
+	apiKeyRouter := apiServer.router.Group("/user/api-keys", apiServer.RequireAuth)
+	apiKeyRouter.Get("/", apiServer.ListAPIKeys)
+	apiKeyRouter.Post("/", apiServer.CreateAPIKey)
+	apiKeyRouter.Delete("/:id", apiServer.RevokeAPIKey)
+
+	apiServer.document(openapi.Operation{
+		Method:   fiber.MethodGet,
+		Path:     "/user/api-keys",
+		Summary:  "List the user's active API keys",
+		Tags:     []string{"api-keys"},
+		Response: []types.APIKey{},
+		Security: userSecurity,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusInternalServerError, Code: "LOAD_API_KEYS_FAILED"},
+		},
+	})
+	apiServer.document(openapi.Operation{
+		Method:   fiber.MethodPost,
+		Path:     "/user/api-keys",
+		Summary:  "Create an API key - the key is only returned once",
+		Tags:     []string{"api-keys"},
+		Request:  types.CreateAPIKeyRequest{},
+		Response: types.CreateAPIKeyResponse{},
+		Status:   fiber.StatusCreated,
+		Security: userSecurity,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusBadRequest, Code: "INVALID_REQUEST"},
+			{Status: fiber.StatusBadRequest, Code: "INVALID_SCOPE"},
+			{Status: fiber.StatusForbidden, Code: "FORBIDDEN"},
+			{Status: fiber.StatusInternalServerError, Code: "CREATE_API_KEY_FAILED"},
+		},
+	})
+	apiServer.document(openapi.Operation{
+		Method:   fiber.MethodDelete,
+		Path:     "/user/api-keys/:id",
+		Summary:  "Revoke an API key",
+		Tags:     []string{"api-keys"},
+		Response: MessageResponse{},
+		Security: userSecurity,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+			{Status: fiber.StatusInternalServerError, Code: "REVOKE_API_KEY_FAILED"},
+		},
+	})
+}
+
+// ListAPIKeys returns the user's active API keys - the secrets themselves are never returned
+func (apiServer *StackAPIServer) ListAPIKeys(c fiber.Ctx) error {
+	userID, _ := GetUserIDFromContext(c)
+
+	keys, err := apiServer.store.APIKeys().LoadForUser(userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to load api keys")
+		return apierror.Internal(types.ErrorCodeLoadAPIKeysFailed, "Failed to load API keys")
+	}
+	if keys == nil {
+		keys = []types.APIKey{}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(keys)
+}
+
+// CreateAPIKey creates an API key with a subset of the user's roles
+// The key is only returned in this response
+func (apiServer *StackAPIServer) CreateAPIKey(c fiber.Ctx) error {
+	// An API key can't be used to mint more keys
+	if _, ok := GetAPIKeyIDFromContext(c); ok {
+		return apierror.Forbidden("API keys cannot create API keys, log in instead")
+	}
+
+	req, err := getRequestData[types.CreateAPIKeyRequest](c)
+	if err != nil || req.Name == "" {
+		return apierror.BadRequest(types.ErrorCodeInvalidRequest, "name is required")
+	}
+
+	userID, _ := GetUserIDFromContext(c)
+	userRoles, _ := GetUserRolesFromContext(c)
+
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = userRoles
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(userRoles, scope) {
+			return apierror.BadRequest(types.ErrorCodeInvalidScope, fmt.Sprintf("You do not have the %q role", scope))
+		}
+	}
+
+	key, keyHash, err := newAPIKey()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to generate api key")
+		return apierror.Internal(types.ErrorCodeCreateAPIKeyFailed, "Failed to create API key")
+	}
+
+	apiKey := &types.APIKey{
+		ID:      uuid.New().String(),
+		UserID:  userID,
+		Name:    req.Name,
+		Prefix:  key[:len(config.API_KEY_PREFIX)+8],
+		KeyHash: keyHash,
+		Scopes:  scopes,
+	}
+
+	if err := apiServer.store.APIKeys().Create(apiKey); err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to save api key")
+		return apierror.Internal(types.ErrorCodeCreateAPIKeyFailed, "Failed to create API key")
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Str("api_key_id", apiKey.ID).
+		Strs("scopes", scopes).
+		Msg("Created API key")
+
+	return c.Status(fiber.StatusCreated).JSON(types.CreateAPIKeyResponse{
+		Key:    key,
+		APIKey: *apiKey,
+	})
+}
+
+// RevokeAPIKey revokes one of the user's API keys, it stops working immediately
+func (apiServer *StackAPIServer) RevokeAPIKey(c fiber.Ctx) error {
+	userID, _ := GetUserIDFromContext(c)
+	keyID := c.Params("id")
+
+	err := apiServer.store.APIKeys().Revoke(keyID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apierror.NotFound("API key not found")
+	}
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to revoke api key")
+		return apierror.Internal(types.ErrorCodeRevokeAPIKeyFailed, "Failed to revoke API key")
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Str("api_key_id", keyID).
+		Msg("Revoked API key")
+
+	return c.Status(fiber.StatusOK).JSON(MessageResponse{
+		Message: "API key revoked",
+		ID:      keyID,
+	})
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// AuditChangeContextKey holds the auditChange a handler attached to the request
+const AuditChangeContextKey ContextKey = "auditChange"
+
+// auditMethods are the request methods recorded in the audit log
+var auditMethods = map[string]bool{
+	fiber.MethodPost:   true,
+	fiber.MethodPut:    true,
+	fiber.MethodPatch:  true,
+	fiber.MethodDelete: true,
+}
+
+// auditChange describes what a request changed for its audit event
+type auditChange struct {
+	Action     types.AuditAction
+	Resource   string
+	ResourceID string
+	// ActorID is only needed when the request itself authenticates the user (e.g. login)
+	ActorID string
+	Before  json.RawMessage
+	After   json.RawMessage
+}
+
+// setAuditChange attaches what the request changed to its audit event
+// a later call replaces the change set by an earlier one
+func setAuditChange(c fiber.Ctx, change auditChange) {
+	c.Locals(string(AuditChangeContextKey), &change)
+}
+
+// auditRequests records every mutating API call in the audit log once it has been handled
+// Handlers describe what the call changed with setAuditChange, calls that don't are
+// recorded as a request with just the actor, route and status
+func (apiServer *StackAPIServer) auditRequests(c fiber.Ctx) error {
+	if !auditMethods[c.Method()] {
+		return c.Next()
+	}
+
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	if err != nil {
+		// the error handler hasn't written the response yet
+		status = apierror.Status(err)
+	}
+	apiServer.recordAuditEvent(c, status)
+
+	return err
+}
+
+// recordAuditEvent stores the audit event of a handled request
+// the request has already been handled so a failure is only logged
+func (apiServer *StackAPIServer) recordAuditEvent(c fiber.Ctx, status int) {
+	event := &types.AuditEvent{
+		ID:        uuid.New().String(),
+		IP:        c.IP(),
+		Method:    c.Method(),
+		Route:     c.Route().Path,
+		Status:    status,
+		Action:    types.AuditActionRequest,
+		RequestID: requestid.FromContext(c),
+	}
+	if actorID, ok := GetUserIDFromContext(c); ok {
+		event.ActorID = actorID
+	}
+	if change, ok := c.Locals(string(AuditChangeContextKey)).(*auditChange); ok {
+		event.Action = change.Action
+		event.Resource = change.Resource
+		event.ResourceID = change.ResourceID
+		event.Before = change.Before
+		event.After = change.After
+		if change.ActorID != "" {
+			event.ActorID = change.ActorID
+		}
+	}
+
+	if err := apiServer.store.AuditEvents().Create(event); err != nil {
+		log.Error().Err(err).
+			Str("route", event.Route).
+			Str("action", string(event.Action)).
+			Msg("Failed to record audit event")
+	}
+}
+
+// auditListConfig is how the audit log can be paged, sorted and filtered
+func auditListConfig() *ResourceListConfig {
+	return &ResourceListConfig{
+		DefaultLimit: 50,
+		MaxLimit:     200,
+		DefaultSort:  "-created_at",
+		SortFields: map[string]string{
+			"id":         "id",
+			"created_at": "created_at",
+		},
+		FilterFields: map[string]string{
+			"actor_id":    "actor_id",
+			"resource":    "resource",
+			"resource_id": "resource_id",
+			"action":      "action",
+		},
+		RangeParams: map[string]ResourceRangeParam{
+			"from": {Column: "created_at", Op: ">=", Description: "Only events at or after this unix time"},
+			"to":   {Column: "created_at", Op: "<=", Description: "Only events at or before this unix time"},
+		},
+	}
+}
+
+// RegisterAuditRoutes registers the route for querying the audit log
+// it needs the audit:read permission which by default only admins have
+func (apiServer *StackAPIServer) RegisterAuditRoutes() {
+	apiServer.router.Get("/audit-events", apiServer.RequireAuth, apiServer.RequirePermission(config.PERMISSION_AUDIT_READ), apiServer.ListAuditEvents)
+
+	apiServer.document(openapi.Operation{
+		Method:     fiber.MethodGet,
+		Path:       "/audit-events",
+		Summary:    "Query the audit log, newest first",
+		Tags:       []string{"audit"},
+		Query:      listQueryDocs(auditListConfig()),
+		Response:   ListResponse[types.AuditEvent]{},
+		Security:   userSecurity,
+		Permission: config.PERMISSION_AUDIT_READ,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusBadRequest, Code: "INVALID_QUERY"},
+			{Status: fiber.StatusInternalServerError, Code: "LIST_FAILED"},
+		},
+	})
+}
+
+// ListAuditEvents returns a page of the audit log
+// It can be filtered by actor, resource, action and a created_at time range
+func (apiServer *StackAPIServer) ListAuditEvents(c fiber.Ctx) error {
+	query, err := parseListQuery(c, auditListConfig())
+	if err != nil {
+		return apierror.BadRequest(types.ErrorCodeInvalidQuery, err.Error())
+	}
+
+	result, err := apiServer.store.AuditEvents().List(query)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list audit events")
+		return apierror.Internal(types.ErrorCodeListFailed, "Failed to retrieve audit events")
+	}
+
+	return respondList(c, query, result)
+}
@@ -5,8 +5,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
 	"github.com/gofiber/fiber/v3"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
@@ -16,25 +20,34 @@ type ContextKey string
 const (
 	JWTUserIDContextKey    ContextKey = "jwtUserID"
 	JWTUserRolesContextKey ContextKey = "jwtUserRoles"
+	JWTClaimsContextKey    ContextKey = "jwtClaims"
 )
 
 // JWTClaims represents the claims stored in the JWT token
+// SessionID is the refresh token family the access token was issued for
 type JWTClaims struct {
-	UserID string   `json:"user_id"`
-	Roles  []string `json:"roles"`
+	UserID    string   `json:"user_id"`
+	Roles     []string `json:"roles"`
+	SessionID string   `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// generateJWT creates a JWT token for the given user information
-func (apiServer *StackAPIServer) generateJWT(userID string, roles []string) (string, error) {
+// generateJWT creates a short lived access token for the given user information
+// Each token has a unique jti so it can be revoked on logout
+func (apiServer *StackAPIServer) generateJWT(userID string, roles []string, sessionID string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(apiServer.cfg.WebServer.AccessTokenTTL)
+
 	// Create the claims
 	claims := JWTClaims{
-		UserID: userID,
-		Roles:  roles,
+		UserID:    userID,
+		Roles:     roles,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Token expires in 24 hours
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    "badcode-api",
 			Subject:   userID,
 		},
@@ -46,10 +59,10 @@ func (apiServer *StackAPIServer) generateJWT(userID string, roles []string) (str
 	// Sign token with secret
 	tokenString, err := token.SignedString([]byte(apiServer.cfg.WebServer.JWTSecret))
 	if err != nil {
-		return "", fmt.Errorf("failed to sign JWT token: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to sign JWT token: %w", err)
 	}
 
-	return tokenString, nil
+	return tokenString, expiresAt, nil
 }
 
 // validateJWT validates and parses a JWT token string, returning the user information
@@ -81,8 +94,19 @@ func (apiServer *StackAPIServer) validateJWT(tokenString string) (*JWTClaims, er
 	return claims, nil
 }
 
-// RequireAuth is a middleware that validates authentication using JWT token or session ID
+// RequireAuth is a middleware that validates authentication using a JWT or an API key
 func (apiServer *StackAPIServer) RequireAuth(c fiber.Ctx) error {
+	if err := apiServer.authenticate(c); err != nil {
+		return err
+	}
+	return c.Next()
+}
+
+// authenticate validates the credentials on the request and stores the user in the context
+// API keys are read from the X-API-Key header or an Authorization Bearer value with the key prefix,
+// anything else is treated as a JWT (Authorization header or token query parameter)
+// Errors are *apierror.Error values carrying the status to respond with
+func (apiServer *StackAPIServer) authenticate(c fiber.Ctx) error {
 	// Log the incoming request
 	log.Debug().
 		Str("method", c.Method()).
@@ -90,13 +114,19 @@ func (apiServer *StackAPIServer) RequireAuth(c fiber.Ctx) error {
 		Str("ip", c.IP()).
 		Msg("Auth middleware: Processing request")
 
-	// Try JWT authentication first (Authorization header or token query parameter)
 	authHeader := c.Get("Authorization")
 	var tokenString string
 	if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
 		tokenString = strings.TrimPrefix(authHeader, "Bearer ")
 	}
 
+	if apiKey := c.Get("X-API-Key"); apiKey != "" {
+		return apiServer.authenticateAPIKey(c, apiKey)
+	}
+	if strings.HasPrefix(tokenString, config.API_KEY_PREFIX) {
+		return apiServer.authenticateAPIKey(c, tokenString)
+	}
+
 	if tokenString == "" {
 		tokenString = c.Query("token")
 	}
@@ -105,37 +135,52 @@ func (apiServer *StackAPIServer) RequireAuth(c fiber.Ctx) error {
 		tokenString = c.Query("access_token")
 	}
 
-	if tokenString != "" {
-		log.Debug().
+	if tokenString == "" {
+		return apierror.Unauthorized("Authentication required")
+	}
+
+	log.Debug().
+		Str("path", c.Path()).
+		Msg("Auth middleware: Attempting JWT authentication")
+
+	// Validate JWT token
+	jwtUser, err := apiServer.validateJWT(tokenString)
+	if err != nil {
+		log.Warn().
+			Err(err).
 			Str("path", c.Path()).
-			Msg("Auth middleware: Attempting JWT authentication")
+			Msg("Auth middleware: Invalid JWT token")
+		return apierror.Unauthorized("Invalid or expired JWT token")
+	}
 
-		// Validate JWT token
-		jwtUser, err := apiServer.validateJWT(tokenString)
+	// Tokens are blacklisted by jti when their session logs out, checked against the in-process cache
+	if jwtUser.ID != "" {
+		revoked, err := apiServer.revokedTokens.IsRevoked(jwtUser.ID)
 		if err != nil {
+			log.Error().Err(err).Msg("Auth middleware: Failed to check token revocation")
+			return apierror.Internal(types.ErrorCodeInternalError, "Failed to check authentication")
+		}
+		if revoked {
 			log.Warn().
-				Err(err).
+				Str("userID", jwtUser.UserID).
 				Str("path", c.Path()).
-				Msg("Auth middleware: Invalid JWT token")
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid or expired JWT token",
-			})
+				Msg("Auth middleware: Revoked JWT token")
+			return apierror.Unauthorized("Invalid or expired JWT token")
 		}
+	}
 
-		log.Debug().
-			Str("userID", jwtUser.UserID).
-			Strs("roles", jwtUser.Roles).
-			Str("path", c.Path()).
-			Msg("Auth middleware: JWT authentication successful")
-
-		// Store JWT user in context
-		c.Locals(string(JWTUserIDContextKey), jwtUser.UserID)
-		c.Locals(string(JWTUserRolesContextKey), jwtUser.Roles)
+	log.Debug().
+		Str("userID", jwtUser.UserID).
+		Strs("roles", jwtUser.Roles).
+		Str("path", c.Path()).
+		Msg("Auth middleware: JWT authentication successful")
 
-		return c.Next()
-	}
+	// Store JWT user in context
+	c.Locals(string(JWTUserIDContextKey), jwtUser.UserID)
+	c.Locals(string(JWTUserRolesContextKey), jwtUser.Roles)
+	c.Locals(string(JWTClaimsContextKey), jwtUser)
 
-	return fmt.Errorf("authentication not found")
+	return nil
 }
 
 // GetJWTUserFromContext retrieves the JWT user from the request context
@@ -148,3 +193,23 @@ func GetUserRolesFromContext(c fiber.Ctx) ([]string, bool) {
 	jwtUserRoles, ok := c.Locals(string(JWTUserRolesContextKey)).([]string)
 	return jwtUserRoles, ok
 }
+
+// GetClaimsFromContext retrieves the validated JWT claims from the request context
+func GetClaimsFromContext(c fiber.Ctx) (*JWTClaims, bool) {
+	claims, ok := c.Locals(string(JWTClaimsContextKey)).(*JWTClaims)
+	return claims, ok
+}
+
+// HasRole reports whether the authenticated user has the given role
+func HasRole(c fiber.Ctx, role string) bool {
+	roles, ok := GetUserRolesFromContext(c)
+	if !ok {
+		return false
+	}
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
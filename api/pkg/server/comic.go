@@ -1,40 +1,23 @@
 package server
 
 import (
-	"fmt"
-
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/jobqueue"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
 	"github.com/binocarlos/kai-stack/api/pkg/store"
 	"github.com/binocarlos/kai-stack/api/pkg/types"
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 )
 
-// ComicCreateRequest is the request body for creating a new comic
-// ID, UserID, CreatedAt, and UpdatedAt are not included as they're set by the system
-type ComicCreateRequest struct {
-	Config *types.ComicConfig `json:"config"`
-}
-
-// ComicUpdateRequest is the request body for updating a comic
-// ID, UserID, CreatedAt, and UpdatedAt are not included as they're managed by the system
-type ComicUpdateRequest struct {
-	Config *types.ComicConfig `json:"config"`
-}
-
 // ComicMapper handles mapping between Comic request DTOs and the Comic entity
 type ComicMapper struct{}
 
 // CreateToEntity converts a ComicCreateRequest to a Comic entity
-func (m *ComicMapper) CreateToEntity(req *ComicCreateRequest) (*types.Comic, error) {
-	if req.Config == nil {
-		return nil, fmt.Errorf("config is required")
-	}
-
-	// Basic validation
-	if req.Config.Name == "" {
-		return nil, fmt.Errorf("comic name is required")
-	}
-
+// The request has been validated against its validate tags
+func (m *ComicMapper) CreateToEntity(req *types.ComicCreateRequest) (*types.Comic, error) {
 	return &types.Comic{
 		// ID and UserID will be set in BeforeCreate hook
 		// CreatedAt and UpdatedAt are auto-managed by GORM
@@ -43,70 +26,78 @@ func (m *ComicMapper) CreateToEntity(req *ComicCreateRequest) (*types.Comic, err
 }
 
 // UpdateToEntity applies a ComicUpdateRequest to an existing Comic entity
-func (m *ComicMapper) UpdateToEntity(existing *types.Comic, req *ComicUpdateRequest) error {
-	if req.Config == nil {
-		return fmt.Errorf("config is required")
-	}
-
-	// Basic validation
-	if req.Config.Name == "" {
-		return fmt.Errorf("comic name is required")
-	}
-
+func (m *ComicMapper) UpdateToEntity(existing *types.Comic, req *types.ComicUpdateRequest) error {
 	// Update only the config field, preserving ID, UserID, and timestamps
 	existing.Config = req.Config
 
 	return nil
 }
 
+// comicAuthConfig is the auth config shared by comics and the pages and panels nested in them
+func comicAuthConfig() ResourceAuthConfig {
+	return PermissionAuthConfig(
+		config.PERMISSION_COMICS_READ,
+		config.PERMISSION_COMICS_WRITE,
+		config.PERMISSION_COMICS_DELETE,
+	)
+}
+
 // ComicRouter provides CRUD operations for comics with custom logic
 type ComicRouter struct {
-	*ResourceRouter[types.Comic, ComicCreateRequest, ComicUpdateRequest]
+	*ResourceRouter[types.Comic, types.ComicCreateRequest, types.ComicUpdateRequest]
 	repo *store.ComicRepository
 }
 
 // NewComicRouter creates a new comic router with all necessary configuration
 func NewComicRouter(apiServer *StackAPIServer, repo *store.ComicRepository) *ComicRouter {
 	// Define hooks for custom comic behavior
-	hooks := &ResourceHooks[types.Comic, ComicCreateRequest, ComicUpdateRequest]{
+	hooks := &ResourceHooks[types.Comic, types.ComicCreateRequest, types.ComicUpdateRequest]{
 		BeforeCreate: func(c fiber.Ctx, comic *types.Comic) error {
 			// Generate a new UUID for the comic
+			// The UserID has already been set by the ownership config
 			comic.ID = uuid.New().String()
-
-			// Set the UserID from the authenticated user context
-			userID, ok := GetUserIDFromContext(c)
-			if !ok || userID == "" {
-				return fmt.Errorf("user ID is required to create a comic")
-			}
-			comic.UserID = userID
-
 			return nil
 		},
+		// The lifecycle events are sent to the owner's webhook subscriptions
 		AfterCreate: func(c fiber.Ctx, comic *types.Comic) error {
-			// Could add logging, analytics, or trigger other services here
+			apiServer.dispatchWebhook(c, comic.UserID, types.WebhookEventComicCreated, comic)
 			return nil
 		},
-		BeforeUpdate: func(c fiber.Ctx, comic *types.Comic) error {
-			// Could add validation or permission checks here
-			// For example, ensure the user owns the comic they're updating
-			userID, ok := GetUserIDFromContext(c)
-			if !ok || userID == "" {
-				return fmt.Errorf("user ID is required to update a comic")
-			}
-
-			if comic.UserID != userID {
-				return fmt.Errorf("you can only update your own comics")
-			}
-
+		AfterUpdate: func(c fiber.Ctx, comic *types.Comic) error {
+			apiServer.dispatchWebhook(c, comic.UserID, types.WebhookEventComicUpdated, comic)
+			return nil
+		},
+		AfterDelete: func(c fiber.Ctx, id string, comic *types.Comic) error {
+			apiServer.dispatchWebhook(c, comic.UserID, types.WebhookEventComicDeleted, comic)
 			return nil
 		},
+		AfterRestore: func(c fiber.Ctx, comic *types.Comic) error {
+			apiServer.dispatchWebhook(c, comic.UserID, types.WebhookEventComicRestored, comic)
+			return nil
+		},
+	}
+
+	// Comics can be sorted by their timestamps and filtered by owner or any config field
+	listConfig := DefaultListConfig()
+	listConfig.DefaultSort = "-created_at"
+	listConfig.SortFields = map[string]string{
+		"id":         "id",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	}
+	listConfig.FilterFields = map[string]string{
+		"user_id": "user_id",
 	}
+	listConfig.JSONFields = []string{"config"}
 
 	// Configure the resource router
-	config := &ResourceConfig[types.Comic, ComicCreateRequest, ComicUpdateRequest]{
+	config := &ResourceConfig[types.Comic, types.ComicCreateRequest, types.ComicUpdateRequest]{
 		Hooks:      hooks,
-		AuthConfig: DefaultAuthConfig(), // All operations require authentication
+		AuthConfig: comicAuthConfig(), // All operations require authentication and a comics permission
 		Mapper:     &ComicMapper{},
+		ListConfig: &listConfig,
+		Ownership:  DefaultOwnershipConfig(), // Users only see their own comics, admins see all
+		Revisions:  true,                     // Every update keeps the previous comic as a revision
 	}
 
 	resourceRouter := NewResourceRouter(apiServer, repo.Repository, config)
@@ -124,7 +115,71 @@ func (cr *ComicRouter) RegisterRoutes(router fiber.Router) {
 
 	// Add custom route for getting comics by user
 	// This demonstrates how to extend the base ResourceRouter with custom endpoints
-	router.Get("/comics/user/:userId", cr.withAuth(cr.GetUserComics))
+	router.Get("/comics/user/:userId", cr.withAuth(cr.withPermission(config.PERMISSION_COMICS_READ, cr.GetUserComics)))
+
+	// Generating the script runs as a background job
+	router.Post("/comics/:id/generate", cr.withAuth(cr.withPermission(config.PERMISSION_COMICS_WRITE, cr.GenerateScript)))
+
+	// Publishing and the public read-only routes
+	cr.RegisterPublishRoutes(router)
+
+	cr.apiServer.document(openapi.Operation{
+		Method:     fiber.MethodGet,
+		Path:       "/comics/user/:userId",
+		Summary:    "List the comics of a user",
+		Tags:       []string{"comics"},
+		Response:   []types.Comic{},
+		Security:   userSecurity,
+		Permission: config.PERMISSION_COMICS_READ,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusBadRequest, Code: "MISSING_USER_ID"},
+			{Status: fiber.StatusInternalServerError, Code: "LOAD_USER_COMICS_FAILED"},
+		},
+	})
+	cr.apiServer.document(openapi.Operation{
+		Method:     fiber.MethodPost,
+		Path:       "/comics/:id/generate",
+		Summary:    "Queue a job that writes the comic's script",
+		Tags:       []string{"comics"},
+		Response:   types.Job{},
+		Status:     fiber.StatusAccepted,
+		Security:   userSecurity,
+		Permission: config.PERMISSION_COMICS_WRITE,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusBadRequest, Code: "INVALID_COMIC"},
+			{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+			{Status: fiber.StatusInternalServerError, Code: "ENQUEUE_FAILED"},
+		},
+	})
+}
+
+// authorizeComic checks the comic exists and the authenticated user can access it
+// It is used by nested resources such as pages and panels
+func (cr *ComicRouter) authorizeComic(c fiber.Ctx, comicID string) error {
+	_, err := cr.findComic(c, comicID)
+	return err
+}
+
+// findComic loads a comic the authenticated user can access
+func (cr *ComicRouter) findComic(c fiber.Ctx, comicID string) (*types.Comic, error) {
+	ownerFilters, err := cr.ownerFilters(c)
+	if err != nil {
+		return nil, err
+	}
+	var comic types.Comic
+	if err := cr.repo.FindByID(comicID, &comic, ownerFilters...); err != nil {
+		return nil, err
+	}
+	return &comic, nil
+}
+
+// comicOwner returns the user that owns the comic in the URL of a nested page or panel route
+func (cr *ComicRouter) comicOwner(c fiber.Ctx) (string, error) {
+	comic, err := cr.findComic(c, c.Params("id"))
+	if err != nil {
+		return "", err
+	}
+	return comic.UserID, nil
 }
 
 // GetUserComics returns all comics for a specific user
@@ -132,28 +187,47 @@ func (cr *ComicRouter) RegisterRoutes(router fiber.Router) {
 func (cr *ComicRouter) GetUserComics(c fiber.Ctx) error {
 	userID := c.Params("userId")
 	if userID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "User ID parameter is required",
-			"code":  "MISSING_USER_ID",
-		})
+		return apierror.BadRequest(types.ErrorCodeMissingUserID, "User ID parameter is required")
 	}
 
-	// Optional: ensure users can only fetch their own comics
+	// Ensure users can only fetch their own comics unless they are an admin
 	authenticatedUserID, ok := GetUserIDFromContext(c)
-	if !ok || authenticatedUserID != userID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "You can only view your own comics",
-			"code":  "FORBIDDEN",
-		})
+	if !HasRole(c, config.ADMIN_ROLE) && (!ok || authenticatedUserID != userID) {
+		return apierror.Forbidden("You can only view your own comics")
 	}
 
 	comics, err := cr.repo.LoadForUser(userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to load comics for user",
-			"code":  "LOAD_USER_COMICS_FAILED",
-		})
+		return apierror.Internal(types.ErrorCodeLoadUserComicsFailed, "Failed to load comics for user")
 	}
 
 	return c.Status(fiber.StatusOK).JSON(comics)
 }
+
+// GenerateScript enqueues a generate_comic job that writes the comic's script using the LLM
+// It responds with the queued job straight away - the script appears on the comic once the job completes
+func (cr *ComicRouter) GenerateScript(c fiber.Ctx) error {
+	id := c.Params("id")
+
+	ownerFilters, err := cr.ownerFilters(c)
+	if err != nil {
+		return errOwnershipRequired
+	}
+
+	var comic types.Comic
+	if err := cr.repo.FindByID(id, &comic, ownerFilters...); err != nil {
+		return apierror.NotFound("Comic not found")
+	}
+
+	if comic.Config == nil || comic.Config.Name == "" {
+		return apierror.BadRequest(types.ErrorCodeInvalidComic, "comic name is required to generate a script")
+	}
+
+	job, err := cr.apiServer.jobqueue.EnqueueGenerateComic(c.Context(), comic.ID, comic.UserID)
+	if err != nil {
+		log.Error().Err(err).Str("comic_id", comic.ID).Msg("Failed to enqueue generate comic job")
+		return apierror.Internal(types.ErrorCodeEnqueueFailed, "Failed to start script generation")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(jobqueue.ToJob(job))
+}
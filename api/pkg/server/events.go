@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/events"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	// eventStreamContentType is the media type of the /events stream
+	eventStreamContentType = "text/event-stream"
+
+	// lastEventIDHeader is sent by EventSource when it reconnects
+	lastEventIDHeader = "Last-Event-ID"
+
+	// eventStreamRetry is how long clients wait before reconnecting, in milliseconds
+	eventStreamRetry = 3000
+)
+
+// newChangeEventBroker creates the broker that feeds /events from every replica's change events
+func newChangeEventBroker(cfg *config.Config, postgresStore *store.PostgresStore) *events.Broker {
+	return events.NewBroker(postgresStore.DSN(), store.CHANGE_EVENTS_CHANNEL, cfg.WebServer.EventsBuffer)
+}
+
+// RegisterEventRoutes registers the Server-Sent Events stream of the user's changes
+func (apiServer *StackAPIServer) RegisterEventRoutes() {
+	apiServer.router.Get("/events", apiServer.RequireAuth, apiServer.StreamEvents)
+
+	apiServer.document(openapi.Operation{
+		Method:  fiber.MethodGet,
+		Path:    "/events",
+		Summary: "Stream changes to the user's resources and jobs as Server-Sent Events",
+		Tags:    []string{"events"},
+		Query: []openapi.Parameter{
+			{Name: "last_event_id", Type: "integer", Description: "Resume after this event, for clients that can't send Last-Event-ID"},
+		},
+		Headers: []openapi.Parameter{
+			{Name: lastEventIDHeader, Description: "Resume after this event - the recent events after it are sent first"},
+		},
+		Response:     types.ChangeEvent{},
+		ResponseType: eventStreamContentType,
+		Security:     userSecurity,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusBadRequest, Code: "INVALID_EVENT_ID"},
+		},
+	})
+}
+
+// StreamEvents streams the change events of the authenticated user until the client disconnects
+// Each event is sent with its ID, its kind as the event name and its JSON as the data
+// A client resuming with Last-Event-ID first gets the recent events it missed
+func (apiServer *StackAPIServer) StreamEvents(c fiber.Ctx) error {
+	userID, ok := GetUserIDFromContext(c)
+	if !ok {
+		return errOwnershipRequired
+	}
+
+	lastEventID := c.Get(lastEventIDHeader, c.Query("last_event_id"))
+	var resumeFrom int64
+	if lastEventID != "" {
+		var err error
+		if resumeFrom, err = strconv.ParseInt(lastEventID, 10, 64); err != nil || resumeFrom < 0 {
+			return apierror.BadRequest(types.ErrorCodeInvalidEventID, "Last-Event-ID must be an event ID")
+		}
+	}
+
+	broker := apiServer.events
+	subscription, replay := broker.Subscribe(userID, resumeFrom)
+	heartbeat := apiServer.cfg.WebServer.EventsHeartbeat
+	credentials := newStreamCredentials(c)
+
+	c.Set(fiber.HeaderContentType, eventStreamContentType)
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	// stop proxies such as nginx from buffering the stream
+	c.Set("X-Accel-Buffering", "no")
+
+	// the stream is written after the handler returns so c must not be used inside it
+	return c.SendStreamWriter(func(w *bufio.Writer) {
+		defer broker.Unsubscribe(subscription)
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		// the stream ends when its access token expires, EventSource reconnects after the
+		// retry delay and the client authenticates again with a fresh token
+		var expired <-chan time.Time
+		if !credentials.expiresAt.IsZero() {
+			timer := time.NewTimer(time.Until(credentials.expiresAt))
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry)
+		for _, event := range replay {
+			writeChangeEvent(w, event)
+		}
+
+		for {
+			// a failed flush means the client has gone
+			if err := w.Flush(); err != nil {
+				return
+			}
+			select {
+			case event, ok := <-subscription.Events:
+				if !ok {
+					return
+				}
+				writeChangeEvent(w, event)
+			case <-expired:
+				fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry)
+				w.Flush()
+				return
+			case <-ticker.C:
+				// logging out or revoking the API key ends the stream by the next heartbeat
+				if !apiServer.streamCredentialsActive(credentials) {
+					return
+				}
+				w.WriteString(": ping\n\n")
+			}
+		}
+	})
+}
+
+// streamCredentials are how an /events stream was authenticated
+// they are checked again while the stream is open as RequireAuth only ran when it started
+type streamCredentials struct {
+	// expiresAt and jti are set for access tokens
+	expiresAt time.Time
+	jti       string
+	// apiKeyID is set for API keys
+	apiKeyID string
+}
+
+// newStreamCredentials reads the credentials of the request from its context
+func newStreamCredentials(c fiber.Ctx) streamCredentials {
+	if apiKeyID, ok := GetAPIKeyIDFromContext(c); ok {
+		return streamCredentials{apiKeyID: apiKeyID}
+	}
+	var credentials streamCredentials
+	if claims, ok := GetClaimsFromContext(c); ok {
+		credentials.jti = claims.ID
+		if claims.ExpiresAt != nil {
+			credentials.expiresAt = claims.ExpiresAt.Time
+		}
+	}
+	return credentials
+}
+
+// streamCredentialsActive reports whether the access token has not been revoked by a logout
+// or the API key is still active - lookup failures keep the stream open
+func (apiServer *StackAPIServer) streamCredentialsActive(credentials streamCredentials) bool {
+	switch {
+	case credentials.apiKeyID != "":
+		var apiKey types.APIKey
+		err := apiServer.store.APIKeys().FindByID(credentials.apiKeyID, &apiKey)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("api_key_id", credentials.apiKeyID).Msg("Failed to check the API key of an events stream")
+			return true
+		}
+		return apiKey.RevokedAt == 0
+	case credentials.jti != "":
+		revoked, err := apiServer.revokedTokens.IsRevoked(credentials.jti)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to check the access token of an events stream")
+			return true
+		}
+		return !revoked
+	default:
+		return true
+	}
+}
+
+// writeChangeEvent writes a change event in the Server-Sent Events format
+func writeChangeEvent(w *bufio.Writer, event types.ChangeEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Error().Err(err).Int64("event_id", event.ID).Msg("Failed to encode change event")
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Kind, data)
+}
+
+// publishChangeEvent sends a change event to the /events streams of its user on every replica
+// the change has already been saved so a failure is only logged
+func (apiServer *StackAPIServer) publishChangeEvent(ctx context.Context, event *types.ChangeEvent) {
+	event.CreatedAt = time.Now().Unix()
+	if err := apiServer.store.PublishChangeEvent(ctx, event); err != nil {
+		log.Error().Err(err).
+			Str("user_id", event.UserID).
+			Str("resource", event.Resource).
+			Str("resource_id", event.ResourceID).
+			Msg("Failed to publish change event")
+	}
+}
+
+// publishChange streams a change to an entity of the resource to the clients of its owner
+// Entities without an owner (no ownership or parent owner config) are not streamed
+func (rr *ResourceRouter[T, TCreate, TUpdate]) publishChange(c fiber.Ctx, action types.AuditAction, id string, entity *T) {
+	userID, err := rr.owner(c, entity)
+	if err != nil {
+		log.Warn().Err(err).Str("id", id).Msg("Failed to find the owner of the changed entity")
+		return
+	}
+	if userID == "" {
+		return
+	}
+
+	resource, err := rr.repo.Table()
+	if err != nil {
+		log.Warn().Err(err).Str("id", id).Msg("Failed to find the changed resource")
+		return
+	}
+
+	event := &types.ChangeEvent{
+		Kind:       types.ChangeEventKindResource,
+		UserID:     userID,
+		Resource:   resource,
+		ResourceID: id,
+		Action:     action,
+	}
+	if rr.config.Parent != nil {
+		event.ParentID = c.Params(rr.config.Parent.Param)
+	}
+	rr.apiServer.publishChangeEvent(c.Context(), event)
+}
+
+// owner returns the user that owns an entity - from its owner column for owned resources
+// or from the parent for nested ones, empty if the resource has neither
+func (rr *ResourceRouter[T, TCreate, TUpdate]) owner(c fiber.Ctx, entity *T) (string, error) {
+	if rr.config.Ownership != nil {
+		return rr.repo.FieldString(entity, rr.config.Ownership.Column)
+	}
+	if rr.config.Parent != nil && rr.config.Parent.Owner != nil {
+		return rr.config.Parent.Owner(c)
+	}
+	return "", nil
+}
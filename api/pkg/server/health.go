@@ -0,0 +1,30 @@
+package server
+
+import (
+	"github.com/binocarlos/kai-stack/api/pkg/health"
+	"github.com/gofiber/fiber/v3"
+)
+
+// RegisterHealthRoutes registers the liveness and readiness probes
+func (apiServer *StackAPIServer) RegisterHealthRoutes() {
+	checker := health.NewChecker(apiServer.cfg.Health.Timeout)
+	checker.Add("postgres", apiServer.store.Ping)
+	checker.Add("pgx_pool", apiServer.jobqueue.Ping)
+	checker.Add("river", apiServer.jobqueue.Status)
+	apiServer.health = checker
+
+	apiServer.app.Get("/healthz", apiServer.Healthz)
+	apiServer.app.Get("/readyz", apiServer.Readyz)
+}
+
+// Healthz reports that the process is alive and serving requests
+func (apiServer *StackAPIServer) Healthz(c fiber.Ctx) error {
+	report := health.Live()
+	return c.Status(report.HTTPStatus()).JSON(report)
+}
+
+// Readyz reports whether every dependency the api needs is reachable
+func (apiServer *StackAPIServer) Readyz(c fiber.Ctx) error {
+	report := apiServer.health.Run(c.Context())
+	return c.Status(report.HTTPStatus()).JSON(report)
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/jobqueue"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultJobListLimit = 20
+	maxJobListLimit     = 100
+)
+
+// RegisterJobRoutes registers the routes for inspecting and managing background jobs
+// they need the jobs:manage permission which by default only admins have
+func (apiServer *StackAPIServer) RegisterJobRoutes() {
+	jobRouter := apiServer.router.Group("/jobs", apiServer.RequireAuth, apiServer.RequirePermission(config.PERMISSION_JOBS_MANAGE))
+	jobRouter.Get("/", apiServer.ListJobs)
+	jobRouter.Get("/:id", apiServer.GetJob)
+	jobRouter.Get("/:id/results", apiServer.GetJobResults)
+	jobRouter.Post("/:id/cancel", apiServer.CancelJob)
+	jobRouter.Post("/:id/retry", apiServer.RetryJob)
+	jobRouter.Delete("/:id", apiServer.DeleteJob)
+
+	apiServer.document(openapi.Operation{
+		Method:  fiber.MethodGet,
+		Path:    "/jobs",
+		Summary: "List jobs, newest first",
+		Tags:    []string{"jobs"},
+		Query: []openapi.Parameter{
+			{Name: "kind", Description: "Comma separated job kinds"},
+			{Name: "state", Description: "Comma separated job states"},
+			{Name: "queue", Description: "Comma separated queues"},
+			{Name: "limit", Type: "integer", Description: fmt.Sprintf("Page size, default %d and at most %d", defaultJobListLimit, maxJobListLimit)},
+			{Name: "cursor", Description: "next_cursor from the previous page"},
+		},
+		Response:   types.JobListResponse{},
+		Security:   userSecurity,
+		Permission: config.PERMISSION_JOBS_MANAGE,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusBadRequest, Code: "INVALID_QUERY"},
+			{Status: fiber.StatusInternalServerError, Code: "LIST_JOBS_FAILED"},
+		},
+	})
+	apiServer.document(openapi.Operation{
+		Method:     fiber.MethodGet,
+		Path:       "/jobs/:id/results",
+		Summary:    "List the results posted for a job",
+		Tags:       []string{"jobs"},
+		Response:   []types.JobResult{},
+		Security:   userSecurity,
+		Permission: config.PERMISSION_JOBS_MANAGE,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusBadRequest, Code: "INVALID_ID"},
+			{Status: fiber.StatusInternalServerError, Code: "LOAD_JOB_RESULTS_FAILED"},
+		},
+	})
+	jobActions := []struct {
+		method  string
+		path    string
+		action  string
+		summary string
+	}{
+		{fiber.MethodGet, "/jobs/:id", "get", "Get a job"},
+		{fiber.MethodPost, "/jobs/:id/cancel", "cancel", "Cancel a job that has not finished"},
+		{fiber.MethodPost, "/jobs/:id/retry", "retry", "Run a job again immediately"},
+		{fiber.MethodDelete, "/jobs/:id", "delete", "Delete a job that is not running"},
+	}
+	for _, jobAction := range jobActions {
+		apiServer.document(openapi.Operation{
+			Method:     jobAction.method,
+			Path:       jobAction.path,
+			Summary:    jobAction.summary,
+			Tags:       []string{"jobs"},
+			Response:   types.Job{},
+			Security:   userSecurity,
+			Permission: config.PERMISSION_JOBS_MANAGE,
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "INVALID_ID"},
+				{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+				{Status: fiber.StatusConflict, Code: "JOB_RUNNING"},
+				{Status: fiber.StatusInternalServerError, Code: string(jobActionErrorCodes[jobAction.action])},
+			},
+		})
+	}
+}
+
+// ListJobs returns a page of jobs, newest first
+//
+//	?kind=test,generate_comic  - only jobs of these kinds
+//	?state=retryable,discarded - only jobs in these states
+//	?queue=default             - only jobs in these queues
+//	?limit=20&cursor=<cursor>  - paging using next_cursor from the previous page
+func (apiServer *StackAPIServer) ListJobs(c fiber.Ctx) error {
+	filter := jobqueue.JobListFilter{
+		Kinds:  splitQueryList(c.Query("kind")),
+		Queues: splitQueryList(c.Query("queue")),
+		Limit:  defaultJobListLimit,
+		Cursor: c.Query("cursor"),
+	}
+
+	if limitParam := c.Query("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 {
+			return apierror.BadRequest(types.ErrorCodeInvalidQuery, "limit must be a positive integer")
+		}
+		filter.Limit = min(limit, maxJobListLimit)
+	}
+
+	validStates := map[rivertype.JobState]bool{}
+	for _, state := range rivertype.JobStates() {
+		validStates[state] = true
+	}
+	for _, state := range splitQueryList(c.Query("state")) {
+		if !validStates[rivertype.JobState(state)] {
+			return apierror.BadRequest(types.ErrorCodeInvalidQuery, "Invalid job state: "+state)
+		}
+		filter.States = append(filter.States, rivertype.JobState(state))
+	}
+
+	rows, nextCursor, err := apiServer.jobqueue.ListJobs(c.Context(), filter)
+	if err != nil {
+		if errors.Is(err, jobqueue.ErrInvalidCursor) {
+			return apierror.BadRequest(types.ErrorCodeInvalidQuery, "Invalid cursor")
+		}
+		log.Error().Err(err).Msg("Failed to list jobs")
+		return apierror.Internal(types.ErrorCodeListJobsFailed, "Failed to list jobs")
+	}
+
+	response := types.JobListResponse{
+		Jobs:       make([]types.Job, 0, len(rows)),
+		NextCursor: nextCursor,
+	}
+	for _, row := range rows {
+		response.Jobs = append(response.Jobs, jobqueue.ToJob(row))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// GetJob returns a single job
+func (apiServer *StackAPIServer) GetJob(c fiber.Ctx) error {
+	return apiServer.handleJobAction(c, "get", apiServer.jobqueue.GetJob)
+}
+
+// CancelJob cancels a job that has not finished yet
+func (apiServer *StackAPIServer) CancelJob(c fiber.Ctx) error {
+	return apiServer.handleJobAction(c, "cancel", apiServer.jobqueue.CancelJob)
+}
+
+// RetryJob makes a job available to run again immediately
+func (apiServer *StackAPIServer) RetryJob(c fiber.Ctx) error {
+	return apiServer.handleJobAction(c, "retry", apiServer.jobqueue.RetryJob)
+}
+
+// DeleteJob deletes a job that is not currently running
+func (apiServer *StackAPIServer) DeleteJob(c fiber.Ctx) error {
+	return apiServer.handleJobAction(c, "delete", apiServer.jobqueue.DeleteJob)
+}
+
+// jobActionErrorCodes are the codes returned when a job action fails
+var jobActionErrorCodes = map[string]types.ErrorCode{
+	"get":    types.ErrorCodeJobGetFailed,
+	"cancel": types.ErrorCodeJobCancelFailed,
+	"retry":  types.ErrorCodeJobRetryFailed,
+	"delete": types.ErrorCodeJobDeleteFailed,
+}
+
+// handleJobAction parses the job ID, runs the action and maps River errors onto responses
+func (apiServer *StackAPIServer) handleJobAction(
+	c fiber.Ctx,
+	action string,
+	fn func(ctx context.Context, id int64) (*rivertype.JobRow, error),
+) error {
+	jobID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return apierror.BadRequest(types.ErrorCodeInvalidID, "Invalid job ID")
+	}
+
+	row, err := fn(c.Context(), jobID)
+	if errors.Is(err, rivertype.ErrNotFound) {
+		return apierror.NotFound("Job not found")
+	}
+	if errors.Is(err, rivertype.ErrJobRunning) {
+		return apierror.Conflict(types.ErrorCodeJobRunning, "Job is running")
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("job_id", jobID).Str("action", action).Msg("Failed to manage job")
+		return apierror.Internal(jobActionErrorCodes[action], "Failed to "+action+" job")
+	}
+
+	if action != "get" {
+		userID, _ := GetUserIDFromContext(c)
+		log.Info().
+			Int64("job_id", jobID).
+			Str("action", action).
+			Str("user_id", userID).
+			Msg("Managed job")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(jobqueue.ToJob(row))
+}
+
+// splitQueryList splits a comma separated query parameter, ignoring empty entries
+func splitQueryList(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// GetJobResults returns every result posted for a job
+func (apiServer *StackAPIServer) GetJobResults(c fiber.Ctx) error {
+	jobID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return apierror.BadRequest(types.ErrorCodeInvalidID, "Invalid job ID")
+	}
+
+	results, err := apiServer.store.JobResults().LoadForJob(jobID)
+	if err != nil {
+		log.Error().Err(err).Int64("job_id", jobID).Msg("Failed to load job results")
+		return apierror.Internal(types.ErrorCodeLoadJobResultsFailed, "Failed to load job results")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(results)
+}
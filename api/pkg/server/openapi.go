@@ -0,0 +1,97 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+)
+
+// security schemes of the OpenAPI document
+const (
+	securityBearer = "bearerAuth"
+	securityAPIKey = "apiKeyAuth"
+	securityWorker = "workerSecret"
+)
+
+// userSecurity is accepted by RequireAuth - a JWT or an API key
+var userSecurity = []string{securityBearer, securityAPIKey}
+
+// MessageResponse is the body of responses that only confirm an action
+// ID is the resource the action was applied to, if any
+type MessageResponse struct {
+	Message string `json:"message"`
+	ID      string `json:"id,omitempty"`
+}
+
+// newOpenAPIRegistry creates the registry the routes describe themselves in
+func newOpenAPIRegistry(cfg *config.Config) *openapi.Registry {
+	return openapi.NewRegistry(
+		openapi.Info{
+			Title:   "kai-stack API",
+			Version: config.API_VERSION,
+		},
+		cfg.WebServer.APIPath,
+		types.Problem{},
+		map[string]openapi.SecurityScheme{
+			securityBearer: {
+				Type:         "http",
+				Scheme:       "bearer",
+				BearerFormat: "JWT",
+				Description:  "An access token from /user/login or /user/refresh, or an API key starting with " + config.API_KEY_PREFIX,
+			},
+			securityAPIKey: {
+				Type:        "apiKey",
+				In:          "header",
+				Name:        "X-API-Key",
+				Description: "A personal API key from /user/api-keys",
+			},
+			securityWorker: {
+				Type:        "http",
+				Scheme:      "bearer",
+				Description: "The shared worker secret (WORKER_SECRET)",
+			},
+		},
+	)
+}
+
+// document registers the metadata of a route in the OpenAPI document
+// routes with security or a permission get the errors RequireAuth and RequirePermission return
+func (apiServer *StackAPIServer) document(operation openapi.Operation) {
+	if len(operation.Security) > 0 {
+		operation.Errors = append(operation.Errors, openapi.ErrorResponse{Status: fiber.StatusUnauthorized, Code: "UNAUTHORIZED"})
+	}
+	if operation.Permission != "" {
+		operation.Errors = append(operation.Errors,
+			openapi.ErrorResponse{Status: fiber.StatusForbidden, Code: "FORBIDDEN"},
+			openapi.ErrorResponse{Status: fiber.StatusInternalServerError, Code: "PERMISSION_CHECK_FAILED"},
+		)
+	}
+	apiServer.openapi.Add(operation)
+}
+
+// OpenAPI returns the OpenAPI document describing every registered route
+func (apiServer *StackAPIServer) OpenAPI() *openapi.Document {
+	return apiServer.openapiDocument()
+}
+
+// RegisterOpenAPIRoutes serves the OpenAPI document
+// It is generated on the first request, once every route has been registered
+func (apiServer *StackAPIServer) RegisterOpenAPIRoutes() {
+	apiServer.openapiDocument = sync.OnceValue(apiServer.openapi.Document)
+	apiServer.router.Get("/openapi.json", apiServer.GetOpenAPI)
+
+	apiServer.document(openapi.Operation{
+		Method:  fiber.MethodGet,
+		Path:    "/openapi.json",
+		Summary: "Get this OpenAPI document",
+		Tags:    []string{"meta"},
+	})
+}
+
+// GetOpenAPI returns the OpenAPI document
+func (apiServer *StackAPIServer) GetOpenAPI(c fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(apiServer.OpenAPI())
+}
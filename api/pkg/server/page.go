@@ -0,0 +1,325 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// PageMapper handles mapping between Page request DTOs and the Page entity
+type PageMapper struct{}
+
+// CreateToEntity converts a PageCreateRequest to a Page entity
+func (m *PageMapper) CreateToEntity(req *types.PageCreateRequest) (*types.Page, error) {
+	page := &types.Page{
+		// ID is set in the BeforeCreate hook and ComicID from the URL
+		Position: -1,
+		Layout:   req.Layout,
+	}
+	if req.Position != nil {
+		page.Position = *req.Position
+	}
+	return page, nil
+}
+
+// UpdateToEntity applies a PageUpdateRequest to an existing Page entity
+func (m *PageMapper) UpdateToEntity(existing *types.Page, req *types.PageUpdateRequest) error {
+	existing.Layout = req.Layout
+	return nil
+}
+
+// PanelMapper handles mapping between Panel request DTOs and the Panel entity
+type PanelMapper struct{}
+
+// CreateToEntity converts a PanelCreateRequest to a Panel entity
+func (m *PanelMapper) CreateToEntity(req *types.PanelCreateRequest) (*types.Panel, error) {
+	dialogue, err := validatePanelContent(req.Layout, req.Dialogue)
+	if err != nil {
+		return nil, err
+	}
+	panel := &types.Panel{
+		// ID is set in the BeforeCreate hook and PageID from the URL
+		Position: -1,
+		Layout:   req.Layout,
+		Caption:  req.Caption,
+		Dialogue: dialogue,
+		ImageURL: req.ImageURL,
+	}
+	if req.Position != nil {
+		panel.Position = *req.Position
+	}
+	return panel, nil
+}
+
+// UpdateToEntity applies a PanelUpdateRequest to an existing Panel entity
+func (m *PanelMapper) UpdateToEntity(existing *types.Panel, req *types.PanelUpdateRequest) error {
+	dialogue, err := validatePanelContent(req.Layout, req.Dialogue)
+	if err != nil {
+		return err
+	}
+	existing.Layout = req.Layout
+	existing.Caption = req.Caption
+	existing.Dialogue = dialogue
+	existing.ImageURL = req.ImageURL
+	return nil
+}
+
+// validatePanelContent checks the layout fits on the page - the field ranges are
+// covered by the validate tags - and defaults balloons without a type to speech
+func validatePanelContent(layout *types.PanelLayout, dialogue []types.DialogueBalloon) ([]types.DialogueBalloon, error) {
+	if layout != nil && (layout.X+layout.Width > 1 || layout.Y+layout.Height > 1) {
+		return nil, fmt.Errorf("layout must fit within the page")
+	}
+
+	balloons := make([]types.DialogueBalloon, 0, len(dialogue))
+	for _, balloon := range dialogue {
+		if balloon.Type == "" {
+			balloon.Type = types.BalloonTypeSpeech
+		}
+		balloons = append(balloons, balloon)
+	}
+	return balloons, nil
+}
+
+// PageRouter provides CRUD operations for the pages of a comic
+// under /comics/:id/pages/:pageId
+type PageRouter struct {
+	*ResourceRouter[types.Page, types.PageCreateRequest, types.PageUpdateRequest]
+	repo *store.PageRepository
+}
+
+// NewPageRouter creates a page router - access to the pages follows access to the comic
+func NewPageRouter(apiServer *StackAPIServer, repo *store.PageRepository, comicRouter *ComicRouter) *PageRouter {
+	hooks := &ResourceHooks[types.Page, types.PageCreateRequest, types.PageUpdateRequest]{
+		BeforeCreate: func(c fiber.Ctx, page *types.Page) error {
+			page.ID = uuid.New().String()
+			return nil
+		},
+	}
+
+	listConfig := DefaultListConfig()
+	listConfig.DefaultSort = "position"
+	listConfig.SortFields = map[string]string{
+		"id":         "id",
+		"position":   "position",
+		"created_at": "created_at",
+	}
+
+	config := &ResourceConfig[types.Page, types.PageCreateRequest, types.PageUpdateRequest]{
+		Hooks:      hooks,
+		AuthConfig: comicAuthConfig(),
+		Mapper:     &PageMapper{},
+		ListConfig: &listConfig,
+		Parent: &ResourceParentConfig{
+			Param:     "id",
+			Column:    "comic_id",
+			Authorize: comicRouter.authorizeComic,
+			Owner:     comicRouter.comicOwner,
+		},
+		IDParam: "pageId",
+	}
+
+	return &PageRouter{
+		ResourceRouter: NewResourceRouter(apiServer, repo.Repository, config),
+		repo:           repo,
+	}
+}
+
+// RegisterRoutes registers the page routes nested under their comic
+func (pr *PageRouter) RegisterRoutes(router fiber.Router) {
+	// Registered before the CRUD routes so "reorder" is not taken as a page ID
+	router.Post("/comics/:id/pages/reorder", pr.withAuth(pr.withPermission(config.PERMISSION_COMICS_WRITE, pr.Reorder)))
+	pr.ResourceRouter.RegisterRoutes(router, "/comics/:id/pages")
+
+	pr.apiServer.document(openapi.Operation{
+		Method:     fiber.MethodPost,
+		Path:       "/comics/:id/pages/reorder",
+		Summary:    "Set the order of the pages",
+		Tags:       []string{"pages"},
+		Request:    types.ReorderRequest{},
+		Response:   []types.Page{},
+		Security:   userSecurity,
+		Permission: config.PERMISSION_COMICS_WRITE,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusBadRequest, Code: "INVALID_REQUEST"},
+			{Status: fiber.StatusBadRequest, Code: "INVALID_ORDER"},
+			{Status: fiber.StatusNotFound, Code: "PARENT_NOT_FOUND"},
+			{Status: fiber.StatusInternalServerError, Code: "REORDER_FAILED"},
+		},
+	})
+}
+
+// Reorder sets the order of every page in a comic and returns the pages in their new order
+func (pr *PageRouter) Reorder(c fiber.Ctx) error {
+	return reorderChildren(c, pr.ResourceRouter, "comic_id", c.Params("id"), pr.repo.LoadForComic)
+}
+
+// PanelRouter provides CRUD operations for the panels of a page
+// under /comics/:id/pages/:pageId/panels/:panelId
+type PanelRouter struct {
+	*ResourceRouter[types.Panel, types.PanelCreateRequest, types.PanelUpdateRequest]
+	repo *store.PanelRepository
+}
+
+// NewPanelRouter creates a panel router - the page must belong to the comic in the URL
+// and access follows access to the comic
+func NewPanelRouter(apiServer *StackAPIServer, repo *store.PanelRepository, pageRepo *store.PageRepository, comicRouter *ComicRouter) *PanelRouter {
+	hooks := &ResourceHooks[types.Panel, types.PanelCreateRequest, types.PanelUpdateRequest]{
+		BeforeCreate: func(c fiber.Ctx, panel *types.Panel) error {
+			panel.ID = uuid.New().String()
+			return nil
+		},
+	}
+
+	listConfig := DefaultListConfig()
+	listConfig.DefaultSort = "position"
+	listConfig.SortFields = map[string]string{
+		"id":         "id",
+		"position":   "position",
+		"created_at": "created_at",
+	}
+
+	authorizePage := func(c fiber.Ctx, pageID string) error {
+		comicID := c.Params("id")
+		if err := comicRouter.authorizeComic(c, comicID); err != nil {
+			return err
+		}
+		var page types.Page
+		return pageRepo.FindByID(pageID, &page, store.Filter{Expression: "comic_id", Value: comicID})
+	}
+
+	config := &ResourceConfig[types.Panel, types.PanelCreateRequest, types.PanelUpdateRequest]{
+		Hooks:      hooks,
+		AuthConfig: comicAuthConfig(),
+		Mapper:     &PanelMapper{},
+		ListConfig: &listConfig,
+		Parent: &ResourceParentConfig{
+			Param:     "pageId",
+			Column:    "page_id",
+			Authorize: authorizePage,
+			Owner:     comicRouter.comicOwner,
+		},
+		IDParam: "panelId",
+	}
+
+	return &PanelRouter{
+		ResourceRouter: NewResourceRouter(apiServer, repo.Repository, config),
+		repo:           repo,
+	}
+}
+
+// RegisterRoutes registers the panel routes nested under their page
+func (pr *PanelRouter) RegisterRoutes(router fiber.Router) {
+	// Registered before the CRUD routes so "reorder" is not taken as a panel ID
+	router.Post("/comics/:id/pages/:pageId/panels/reorder", pr.withAuth(pr.withPermission(config.PERMISSION_COMICS_WRITE, pr.Reorder)))
+	pr.ResourceRouter.RegisterRoutes(router, "/comics/:id/pages/:pageId/panels")
+
+	pr.apiServer.document(openapi.Operation{
+		Method:     fiber.MethodPost,
+		Path:       "/comics/:id/pages/:pageId/panels/reorder",
+		Summary:    "Set the order of the panels",
+		Tags:       []string{"panels"},
+		Request:    types.ReorderRequest{},
+		Response:   []types.Panel{},
+		Security:   userSecurity,
+		Permission: config.PERMISSION_COMICS_WRITE,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusBadRequest, Code: "INVALID_REQUEST"},
+			{Status: fiber.StatusBadRequest, Code: "INVALID_ORDER"},
+			{Status: fiber.StatusNotFound, Code: "PARENT_NOT_FOUND"},
+			{Status: fiber.StatusInternalServerError, Code: "REORDER_FAILED"},
+		},
+	})
+}
+
+// Reorder sets the order of every panel on a page and returns the panels in their new order
+func (pr *PanelRouter) Reorder(c fiber.Ctx) error {
+	return reorderChildren(c, pr.ResourceRouter, "page_id", c.Params("pageId"), pr.repo.LoadForPage)
+}
+
+// reorderChildren authorizes the parent, applies the order from a ReorderRequest
+// and responds with the children loaded in their new order
+// Every child is streamed as updated and the audit event records the parent's children before and after
+func reorderChildren[T any, TCreate any, TUpdate any](
+	c fiber.Ctx,
+	rr *ResourceRouter[T, TCreate, TUpdate],
+	parentColumn string,
+	parentID string,
+	load func(parentID string) ([]T, error),
+) error {
+	if _, err := rr.scopeFilters(c); err != nil {
+		return scopeError(err)
+	}
+
+	req, err := getRequestData[types.ReorderRequest](c)
+	if err != nil {
+		return apierror.BadRequest(types.ErrorCodeInvalidRequest, "Invalid request body")
+	}
+
+	before, err := load(parentID)
+	if err != nil {
+		log.Error().Err(err).Str("parent_id", parentID).Msg("Failed to load entities to reorder")
+		return apierror.Internal(types.ErrorCodeReorderFailed, "Failed to reorder")
+	}
+
+	if err := rr.repo.Reorder(parentColumn, parentID, req.IDs); err != nil {
+		if errors.Is(err, store.ErrReorderMismatch) {
+			return apierror.BadRequest(types.ErrorCodeInvalidOrder, err.Error())
+		}
+		log.Error().Err(err).Str("parent_id", parentID).Msg("Failed to reorder")
+		return apierror.Internal(types.ErrorCodeReorderFailed, "Failed to reorder")
+	}
+
+	children, err := load(parentID)
+	if err != nil {
+		log.Error().Err(err).Str("parent_id", parentID).Msg("Failed to load reordered entities")
+		return apierror.Internal(types.ErrorCodeReorderFailed, "Failed to reorder")
+	}
+
+	auditReorder(c, rr, parentID, before, children)
+	for i := range children {
+		id, err := rr.repo.ID(&children[i])
+		if err != nil {
+			log.Warn().Err(err).Str("parent_id", parentID).Msg("Failed to find the ID of a reordered entity")
+			continue
+		}
+		rr.publishChange(c, types.AuditActionUpdate, id, &children[i])
+	}
+
+	return c.Status(fiber.StatusOK).JSON(children)
+}
+
+// auditReorder records a reorder as an update to the children of the parent
+func auditReorder[T any, TCreate any, TUpdate any](
+	c fiber.Ctx,
+	rr *ResourceRouter[T, TCreate, TUpdate],
+	parentID string,
+	before []T,
+	after []T,
+) {
+	resource, err := rr.repo.Table()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to find the audit resource")
+	}
+	change := auditChange{
+		Action:     types.AuditActionUpdate,
+		Resource:   resource,
+		ResourceID: parentID,
+	}
+	if change.Before, err = json.Marshal(before); err != nil {
+		log.Warn().Err(err).Str("parent_id", parentID).Msg("Failed to encode entities for the audit log")
+	}
+	if change.After, err = json.Marshal(after); err != nil {
+		log.Warn().Err(err).Str("parent_id", parentID).Msg("Failed to encode entities for the audit log")
+	}
+	setAuditChange(c, change)
+}
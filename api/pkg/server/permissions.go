@@ -0,0 +1,159 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// PermissionResolver maps roles to permissions
+// The table starts from config.DEFAULT_ROLE_PERMISSIONS, roles in AUTH_ROLE_PERMISSIONS replace
+// their defaults and roles with rows in the role_permissions table replace both
+// Database rows are cached for AUTH_PERMISSIONS_CACHE_TTL
+type PermissionResolver struct {
+	configured map[string][]string
+	repo       *store.RolePermissionRepository
+	ttl        time.Duration
+
+	mu       sync.Mutex
+	table    map[string][]string
+	loadedAt time.Time
+}
+
+// NewPermissionResolver builds the configured role table, failing if AUTH_ROLE_PERMISSIONS is invalid
+func NewPermissionResolver(cfg config.Auth, repo *store.RolePermissionRepository) (*PermissionResolver, error) {
+	configured := maps.Clone(config.DEFAULT_ROLE_PERMISSIONS)
+	overrides, err := parseRolePermissions(cfg.RolePermissions)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(configured, overrides)
+
+	return &PermissionResolver{
+		configured: configured,
+		repo:       repo,
+		ttl:        cfg.PermissionsCacheTTL,
+	}, nil
+}
+
+// parseRolePermissions parses "role=perm,perm;role=perm"
+func parseRolePermissions(value string) (map[string][]string, error) {
+	permissions := map[string][]string{}
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		role, list, ok := strings.Cut(entry, "=")
+		role = strings.TrimSpace(role)
+		if !ok || role == "" {
+			return nil, fmt.Errorf("invalid AUTH_ROLE_PERMISSIONS entry %q, expected role=permission,permission", entry)
+		}
+		permissions[role] = splitQueryList(list)
+	}
+	return permissions, nil
+}
+
+// roleTable returns the current role to permission table
+func (r *PermissionResolver) roleTable() (map[string][]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.table != nil && time.Since(r.loadedAt) < r.ttl {
+		return r.table, nil
+	}
+
+	stored, err := r.repo.LoadAll()
+	if err != nil {
+		// keep serving the last table we loaded rather than locking everyone out
+		if r.table != nil {
+			log.Warn().Err(err).Msg("Failed to reload role permissions, using cached table")
+			return r.table, nil
+		}
+		return nil, err
+	}
+
+	table := maps.Clone(r.configured)
+	maps.Copy(table, stored)
+
+	r.table = table
+	r.loadedAt = time.Now()
+	return table, nil
+}
+
+// HasPermission reports whether any of the roles grants the permission
+func (r *PermissionResolver) HasPermission(roles []string, permission string) (bool, error) {
+	table, err := r.roleTable()
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		granted := table[role]
+		if slices.Contains(granted, config.PERMISSION_ALL) || slices.Contains(granted, permission) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkPermission checks the authenticated user has the permission
+// Errors are *apierror.Error values carrying the status to respond with
+func (apiServer *StackAPIServer) checkPermission(c fiber.Ctx, permission string) error {
+	roles, _ := GetUserRolesFromContext(c)
+	allowed, err := apiServer.permissions.HasPermission(roles, permission)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load role permissions")
+		return apierror.Internal(types.ErrorCodePermissionCheckFailed, "Failed to check permissions")
+	}
+	if !allowed {
+		userID, _ := GetUserIDFromContext(c)
+		log.Warn().
+			Str("userID", userID).
+			Strs("roles", roles).
+			Str("permission", permission).
+			Str("path", c.Path()).
+			Msg("Permission middleware: Permission denied")
+		return apierror.Forbidden(fmt.Sprintf("The %s permission is required", permission))
+	}
+	return nil
+}
+
+// RequirePermission is a middleware that only allows users whose roles grant the permission
+// It must run after RequireAuth
+func (apiServer *StackAPIServer) RequirePermission(permission string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if err := apiServer.checkPermission(c, permission); err != nil {
+			return err
+		}
+		return c.Next()
+	}
+}
+
+// RequireRole is a middleware that only allows users with at least one of the roles
+// It must run after RequireAuth
+func (apiServer *StackAPIServer) RequireRole(roles ...string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		for _, role := range roles {
+			if HasRole(c, role) {
+				return c.Next()
+			}
+		}
+		userID, _ := GetUserIDFromContext(c)
+		log.Warn().
+			Str("userID", userID).
+			Strs("required", roles).
+			Str("path", c.Path()).
+			Msg("Role middleware: Role denied")
+		return apierror.Forbidden(fmt.Sprintf("One of the roles %s is required", strings.Join(roles, ", ")))
+	}
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// publishedListConfig controls the paging of the public comic list
+var publishedListConfig = ResourceListConfig{
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	DefaultSort:  "-published_at",
+	SortFields: map[string]string{
+		"published_at": "comic_versions.created_at",
+	},
+}
+
+// RegisterPublishRoutes registers the owner routes that publish a comic and
+// the public read-only routes that serve published comics without a JWT
+func (cr *ComicRouter) RegisterPublishRoutes(router fiber.Router) {
+	router.Post("/comics/:id/publish", cr.withAuth(cr.withPermission(config.PERMISSION_COMICS_PUBLISH, cr.Publish)))
+	router.Post("/comics/:id/unpublish", cr.withAuth(cr.withPermission(config.PERMISSION_COMICS_PUBLISH, cr.Unpublish)))
+	router.Get("/comics/:id/versions", cr.withAuth(cr.withPermission(config.PERMISSION_COMICS_READ, cr.GetVersions)))
+
+	router.Get("/public/comics", cr.ListPublished)
+	router.Get("/public/comics/:id", cr.GetPublished)
+
+	cr.apiServer.document(openapi.Operation{
+		Method:     fiber.MethodPost,
+		Path:       "/comics/:id/publish",
+		Summary:    "Publish the comic as a new version",
+		Tags:       []string{"comics"},
+		Response:   types.ComicVersion{},
+		Status:     fiber.StatusCreated,
+		Security:   userSecurity,
+		Permission: config.PERMISSION_COMICS_PUBLISH,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+			{Status: fiber.StatusInternalServerError, Code: "PUBLISH_FAILED"},
+		},
+	})
+	cr.apiServer.document(openapi.Operation{
+		Method:     fiber.MethodPost,
+		Path:       "/comics/:id/unpublish",
+		Summary:    "Take the comic out of the public list",
+		Tags:       []string{"comics"},
+		Response:   types.Comic{},
+		Security:   userSecurity,
+		Permission: config.PERMISSION_COMICS_PUBLISH,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+			{Status: fiber.StatusConflict, Code: "NOT_PUBLISHED"},
+			{Status: fiber.StatusInternalServerError, Code: "UNPUBLISH_FAILED"},
+		},
+	})
+	cr.apiServer.document(openapi.Operation{
+		Method:     fiber.MethodGet,
+		Path:       "/comics/:id/versions",
+		Summary:    "List the published versions of the comic",
+		Tags:       []string{"comics"},
+		Response:   []types.ComicVersion{},
+		Security:   userSecurity,
+		Permission: config.PERMISSION_COMICS_READ,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+			{Status: fiber.StatusInternalServerError, Code: "LOAD_VERSIONS_FAILED"},
+		},
+	})
+	cr.apiServer.document(openapi.Operation{
+		Method:  fiber.MethodGet,
+		Path:    "/public/comics",
+		Summary: "List published comics",
+		Tags:    []string{"public"},
+		Query: []openapi.Parameter{
+			{Name: "limit", Type: "integer", Description: "Page size"},
+			{Name: "offset", Type: "integer", Description: "Number of comics to skip"},
+			{Name: "sort", Description: "published_at or -published_at (default)"},
+		},
+		Response: ListResponse[types.ComicVersion]{},
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusBadRequest, Code: "INVALID_QUERY"},
+			{Status: fiber.StatusInternalServerError, Code: "LIST_FAILED"},
+		},
+	})
+	cr.apiServer.document(openapi.Operation{
+		Method:   fiber.MethodGet,
+		Path:     "/public/comics/:id",
+		Summary:  "Get the latest published version of a comic",
+		Tags:     []string{"public"},
+		Response: types.ComicVersion{},
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+			{Status: fiber.StatusInternalServerError, Code: "LOAD_FAILED"},
+		},
+	})
+}
+
+// Publish snapshots the comic, its pages and panels into a new immutable version
+// and makes it publicly readable
+func (cr *ComicRouter) Publish(c fiber.Ctx) error {
+	comicID := c.Params("id")
+	comic, err := cr.findComic(c, comicID)
+	if err != nil {
+		return apierror.NotFound("Comic not found")
+	}
+
+	version, err := cr.apiServer.store.ComicVersions().Publish(comicID)
+	if err != nil {
+		log.Error().Err(err).Str("comic_id", comicID).Msg("Failed to publish comic")
+		return apierror.Internal(types.ErrorCodePublishFailed, "Failed to publish comic")
+	}
+
+	log.Info().
+		Str("comic_id", comicID).
+		Int("version", version.Version).
+		Msg("Published comic")
+
+	cr.publishChange(c, types.AuditActionUpdate, comicID, comic)
+	cr.apiServer.dispatchWebhook(c, comic.UserID, types.WebhookEventComicPublished, version)
+
+	return c.Status(fiber.StatusCreated).JSON(version)
+}
+
+// Unpublish returns the comic to a private preview, published versions are kept
+// Comics that are not published respond 409 and are left unchanged
+func (cr *ComicRouter) Unpublish(c fiber.Ctx) error {
+	comicID := c.Params("id")
+	if err := cr.authorizeComic(c, comicID); err != nil {
+		return apierror.NotFound("Comic not found")
+	}
+
+	if err := cr.apiServer.store.ComicVersions().Unpublish(comicID); err != nil {
+		if errors.Is(err, store.ErrNotPublished) {
+			return apierror.Conflict(types.ErrorCodeNotPublished, "Comic is not published")
+		}
+		log.Error().Err(err).Str("comic_id", comicID).Msg("Failed to unpublish comic")
+		return apierror.Internal(types.ErrorCodeUnpublishFailed, "Failed to unpublish comic")
+	}
+
+	var comic types.Comic
+	if err := cr.repo.FindByID(comicID, &comic); err != nil {
+		return apierror.NotFound("Comic not found")
+	}
+
+	cr.publishChange(c, types.AuditActionUpdate, comicID, &comic)
+	cr.apiServer.dispatchWebhook(c, comic.UserID, types.WebhookEventComicUnpublished, comic)
+
+	return c.Status(fiber.StatusOK).JSON(comic)
+}
+
+// GetVersions returns every published version of a comic, newest first
+func (cr *ComicRouter) GetVersions(c fiber.Ctx) error {
+	comicID := c.Params("id")
+	if err := cr.authorizeComic(c, comicID); err != nil {
+		return apierror.NotFound("Comic not found")
+	}
+
+	versions, err := cr.apiServer.store.ComicVersions().LoadForComic(comicID)
+	if err != nil {
+		log.Error().Err(err).Str("comic_id", comicID).Msg("Failed to load comic versions")
+		return apierror.Internal(types.ErrorCodeLoadVersionsFailed, "Failed to load comic versions")
+	}
+	if versions == nil {
+		versions = []types.ComicVersion{}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(versions)
+}
+
+// ListPublished returns a page of published comics - no authentication required
+func (cr *ComicRouter) ListPublished(c fiber.Ctx) error {
+	query, err := parseListQuery(c, &publishedListConfig)
+	if err == nil && query.Cursor != nil {
+		err = errors.New("cursor pagination is not supported, use offset")
+	}
+	if err != nil {
+		return apierror.BadRequest(types.ErrorCodeInvalidQuery, err.Error())
+	}
+
+	result, err := cr.apiServer.store.ComicVersions().ListPublished(query)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list published comics")
+		return apierror.Internal(types.ErrorCodeListFailed, "Failed to list published comics")
+	}
+
+	items := result.Items
+	if items == nil {
+		items = []types.ComicVersion{}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ListResponse[types.ComicVersion]{
+		Items:  items,
+		Total:  result.Total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	})
+}
+
+// GetPublished returns the published version of a comic - no authentication required
+// Comics that are only previews respond with 404
+func (cr *ComicRouter) GetPublished(c fiber.Ctx) error {
+	version, err := cr.apiServer.store.ComicVersions().FindPublished(c.Params("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apierror.NotFound("Comic not found")
+	}
+	if err != nil {
+		log.Error().Err(err).Str("comic_id", c.Params("id")).Msg("Failed to load published comic")
+		return apierror.Internal(types.ErrorCodeLoadFailed, "Failed to load published comic")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(version)
+}
@@ -1,11 +1,20 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
 	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
 	"github.com/gofiber/fiber/v3"
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
 // ResourceRouter provides generic CRUD operations for a repository
@@ -35,6 +44,13 @@ func NewResourceRouter[T any, TCreate any, TUpdate any](
 			Mapper:     nil, // Will need to be provided if TCreate/TUpdate differ from T
 		}
 	}
+	if config.IDParam == "" {
+		config.IDParam = "id"
+	}
+	if config.ListConfig == nil {
+		listConfig := DefaultListConfig()
+		config.ListConfig = &listConfig
+	}
 	return &ResourceRouter[T, TCreate, TUpdate]{
 		repo:      repo,
 		config:    *config,
@@ -43,91 +59,426 @@ func NewResourceRouter[T any, TCreate any, TUpdate any](
 }
 
 // RegisterRoutes registers all CRUD routes for this resource
-// path should be the base path for the resource (e.g., "/comics")
-// This will create the following routes:
-// - GET    {path}      -> List
+// path should be the base path for the resource (e.g., "/comics" or "/comics/:id/pages")
+// This will create the following routes (:id is the configured IDParam):
+// - GET    {path}      -> List (paginated, see parseListQuery)
 // - POST   {path}      -> Create
 // - GET    {path}/:id  -> Get
 // - PUT    {path}/:id  -> Update
+// - PATCH  {path}/:id  -> Patch (merge patch or JSON patch, see patchRequest)
 // - DELETE {path}/:id  -> Delete
+// Resources with a soft deleting repository also get (see resource_trash.go):
+// - GET    {path}/trash        -> Trash
+// - POST   {path}/:id/restore  -> Restore
+// Resources with Revisions enabled also get (see resource_revision.go):
+// - GET    {path}/:id/revisions                     -> ListRevisions
+// - GET    {path}/:id/revisions/diff?from=1&to=2    -> DiffRevisions
+// - GET    {path}/:id/revisions/:revision           -> GetRevision
+// - POST   {path}/:id/revisions/:revision/revert    -> RevertRevision
 func (rr *ResourceRouter[T, TCreate, TUpdate]) RegisterRoutes(router fiber.Router, path string) {
-	// Apply auth middleware conditionally based on config
-	listHandler := rr.List
-	if rr.config.AuthConfig.RequireAuthForList {
-		listHandler = rr.withAuth(listHandler)
+	// Apply auth and permission middleware conditionally based on config
+	authConfig := rr.config.AuthConfig
+	listHandler := rr.guard(ResourceOperationList, authConfig.RequireAuthForList, rr.List)
+	createHandler := rr.guard(ResourceOperationCreate, authConfig.RequireAuthForCreate, rr.Create)
+	getHandler := rr.guard(ResourceOperationGet, authConfig.RequireAuthForGet, rr.Get)
+	updateHandler := rr.guard(ResourceOperationUpdate, authConfig.RequireAuthForUpdate, rr.Update)
+	patchHandler := rr.guard(ResourceOperationUpdate, authConfig.RequireAuthForUpdate, rr.Patch)
+	deleteHandler := rr.guard(ResourceOperationDelete, authConfig.RequireAuthForDelete, rr.Delete)
+
+	router.Get(path, listHandler)
+	router.Post(path, createHandler)
+	itemPath := fmt.Sprintf("%s/:%s", path, rr.config.IDParam)
+	if rr.repo.SoftDeletes() {
+		// Registered before the item routes so "trash" is not taken as an ID
+		router.Get(path+"/trash", rr.guard(ResourceOperationList, authConfig.RequireAuthForList, rr.Trash))
+		router.Post(itemPath+"/restore", rr.guard(ResourceOperationDelete, authConfig.RequireAuthForDelete, rr.Restore))
+	}
+	router.Get(itemPath, getHandler)
+	router.Put(itemPath, updateHandler)
+	router.Patch(itemPath, patchHandler)
+	router.Delete(itemPath, deleteHandler)
+	if rr.config.Revisions {
+		rr.registerRevisionRoutes(router, itemPath)
 	}
 
-	createHandler := rr.Create
-	if rr.config.AuthConfig.RequireAuthForCreate {
-		createHandler = rr.withAuth(createHandler)
+	rr.documentRoutes(path, itemPath)
+	if rr.repo.SoftDeletes() {
+		rr.documentTrashRoutes(path, itemPath)
+	}
+	if rr.config.Revisions {
+		rr.documentRevisionRoutes(path, itemPath)
 	}
+}
+
+// documentRoutes registers the CRUD operations in the OpenAPI document
+func (rr *ResourceRouter[T, TCreate, TUpdate]) documentRoutes(path string, itemPath string) {
+	name, singular := resourceName(path)
 
-	getHandler := rr.Get
-	if rr.config.AuthConfig.RequireAuthForGet {
-		getHandler = rr.withAuth(getHandler)
+	var (
+		entity    T
+		createReq TCreate
+		updateReq TUpdate
+	)
+
+	query := listQueryDocs(rr.config.ListConfig)
+
+	// versioned resources send ETags and accept conditional requests
+	var ifNoneMatch, ifMatch []openapi.Parameter
+	var preconditionErrors []openapi.ErrorResponse
+	if rr.repo.Versioned() {
+		ifNoneMatch = []openapi.Parameter{
+			{Name: fiber.HeaderIfNoneMatch, Description: "ETags of cached copies, responds 304 Not Modified if one is current"},
+		}
+		ifMatch = []openapi.Parameter{
+			{Name: fiber.HeaderIfMatch, Description: "Only apply the change if the " + singular + " still has one of these ETags"},
+		}
+		preconditionErrors = []openapi.ErrorResponse{
+			{Status: fiber.StatusPreconditionFailed, Code: "PRECONDITION_FAILED"},
+		}
 	}
 
-	updateHandler := rr.Update
-	if rr.config.AuthConfig.RequireAuthForUpdate {
-		updateHandler = rr.withAuth(updateHandler)
+	deleteSummary := "Delete a " + singular
+	if rr.repo.SoftDeletes() {
+		deleteSummary = "Move a " + singular + " to the trash"
 	}
 
-	deleteHandler := rr.Delete
-	if rr.config.AuthConfig.RequireAuthForDelete {
-		deleteHandler = rr.withAuth(deleteHandler)
+	operations := []resourceOperationDoc{
+		{ResourceOperationList, rr.config.AuthConfig.RequireAuthForList, openapi.Operation{
+			Method:   fiber.MethodGet,
+			Path:     path,
+			Summary:  "List " + name,
+			Query:    query,
+			Response: ListResponse[T]{},
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "INVALID_QUERY"},
+				{Status: fiber.StatusInternalServerError, Code: "LIST_FAILED"},
+			},
+		}},
+		{ResourceOperationCreate, rr.config.AuthConfig.RequireAuthForCreate, openapi.Operation{
+			Method:   fiber.MethodPost,
+			Path:     path,
+			Summary:  "Create a " + singular,
+			Request:  createReq,
+			Response: entity,
+			Status:   fiber.StatusCreated,
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "INVALID_REQUEST"},
+				{Status: fiber.StatusBadRequest, Code: "MAPPING_FAILED"},
+				{Status: fiber.StatusBadRequest, Code: "BEFORE_CREATE_FAILED"},
+				{Status: fiber.StatusBadRequest, Code: "VALIDATION_FAILED"},
+				{Status: fiber.StatusInternalServerError, Code: "VALIDATION_ERROR"},
+				{Status: fiber.StatusInternalServerError, Code: "CREATE_FAILED"},
+			},
+		}},
+		{ResourceOperationGet, rr.config.AuthConfig.RequireAuthForGet, openapi.Operation{
+			Method:   fiber.MethodGet,
+			Path:     itemPath,
+			Summary:  "Get a " + singular,
+			Headers:  ifNoneMatch,
+			Response: entity,
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "MISSING_ID"},
+				{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+			},
+		}},
+		{ResourceOperationUpdate, rr.config.AuthConfig.RequireAuthForUpdate, openapi.Operation{
+			Method:   fiber.MethodPut,
+			Path:     itemPath,
+			Summary:  "Update a " + singular,
+			Headers:  ifMatch,
+			Request:  updateReq,
+			Response: entity,
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusConflict, Code: "VERSION_CONFLICT"},
+				{Status: fiber.StatusBadRequest, Code: "MISSING_ID"},
+				{Status: fiber.StatusBadRequest, Code: "INVALID_REQUEST"},
+				{Status: fiber.StatusBadRequest, Code: "MAPPING_FAILED"},
+				{Status: fiber.StatusBadRequest, Code: "BEFORE_UPDATE_FAILED"},
+				{Status: fiber.StatusBadRequest, Code: "VALIDATION_FAILED"},
+				{Status: fiber.StatusInternalServerError, Code: "VALIDATION_ERROR"},
+				{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+				{Status: fiber.StatusInternalServerError, Code: "UPDATE_FAILED"},
+			},
+		}},
+		{ResourceOperationUpdate, rr.config.AuthConfig.RequireAuthForUpdate, openapi.Operation{
+			Method:  fiber.MethodPatch,
+			Path:    itemPath,
+			Summary: "Patch a " + singular,
+			Headers: ifMatch,
+			// a merge patch has the shape of the update request with every field optional
+			RequestBodies: map[string]any{
+				mergePatchContentType: updateReq,
+				jsonPatchContentType:  []types.JSONPatchOperation{},
+			},
+			Response: entity,
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "MISSING_ID"},
+				{Status: fiber.StatusBadRequest, Code: "INVALID_PATCH"},
+				{Status: fiber.StatusBadRequest, Code: "READ_ONLY_FIELD"},
+				{Status: fiber.StatusBadRequest, Code: "MAPPING_FAILED"},
+				{Status: fiber.StatusBadRequest, Code: "BEFORE_UPDATE_FAILED"},
+				{Status: fiber.StatusBadRequest, Code: "VALIDATION_FAILED"},
+				{Status: fiber.StatusInternalServerError, Code: "VALIDATION_ERROR"},
+				{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+				{Status: fiber.StatusConflict, Code: "PATCH_TEST_FAILED"},
+				{Status: fiber.StatusConflict, Code: "VERSION_CONFLICT"},
+				{Status: fiber.StatusUnsupportedMediaType, Code: "UNSUPPORTED_MEDIA_TYPE"},
+				{Status: fiber.StatusInternalServerError, Code: "UPDATE_FAILED"},
+			},
+		}},
+		{ResourceOperationDelete, rr.config.AuthConfig.RequireAuthForDelete, openapi.Operation{
+			Method:   fiber.MethodDelete,
+			Path:     itemPath,
+			Summary:  deleteSummary,
+			Headers:  ifMatch,
+			Response: MessageResponse{},
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "MISSING_ID"},
+				{Status: fiber.StatusBadRequest, Code: "BEFORE_DELETE_FAILED"},
+				{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+				{Status: fiber.StatusConflict, Code: "VERSION_CONFLICT"},
+				{Status: fiber.StatusInternalServerError, Code: "DELETE_FAILED"},
+			},
+		}},
 	}
 
-	router.Get(path, listHandler)
-	router.Post(path, createHandler)
-	router.Get(fmt.Sprintf("%s/:id", path), getHandler)
-	router.Put(fmt.Sprintf("%s/:id", path), updateHandler)
-	router.Delete(fmt.Sprintf("%s/:id", path), deleteHandler)
+	for i, op := range operations {
+		if len(op.spec.Headers) > 0 && op.spec.Method != fiber.MethodGet {
+			operations[i].spec.Errors = append(op.spec.Errors, preconditionErrors...)
+		}
+	}
+	rr.documentOperations(name, operations)
+}
+
+// resourceOperationDoc is a route of a resource router for the OpenAPI document
+// along with the operation it is guarded as
+type resourceOperationDoc struct {
+	operation   ResourceOperation
+	requireAuth bool
+	spec        openapi.Operation
+}
+
+// documentOperations registers routes in the OpenAPI document tagged with the resource name
+// adding the scope errors and the security of the operation each route is guarded as
+func (rr *ResourceRouter[T, TCreate, TUpdate]) documentOperations(name string, operations []resourceOperationDoc) {
+	scopeErrors := rr.scopeErrorDocs()
+	for _, op := range operations {
+		spec := op.spec
+		spec.Tags = []string{name}
+		spec.Errors = append(spec.Errors, scopeErrors...)
+		spec.Permission = rr.config.AuthConfig.Permissions[op.operation]
+		if spec.Permission != "" || op.requireAuth {
+			spec.Security = userSecurity
+		}
+		rr.apiServer.document(spec)
+	}
+}
+
+// auditEntity attaches a change to an entity of the resource to the request's audit event
+// before is the entity's JSON before the change and after the changed entity, nil if there is none
+func (rr *ResourceRouter[T, TCreate, TUpdate]) auditEntity(c fiber.Ctx, action types.AuditAction, id string, before []byte, after *T) {
+	resource, err := rr.repo.Table()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to find the audit resource")
+	}
+	change := auditChange{
+		Action:     action,
+		Resource:   resource,
+		ResourceID: id,
+		Before:     before,
+	}
+	if after != nil {
+		if change.After, err = json.Marshal(after); err != nil {
+			log.Warn().Err(err).Str("id", id).Msg("Failed to encode entity for the audit log")
+		}
+	}
+	setAuditChange(c, change)
+}
+
+// resourceName returns the plural and singular resource names used for tags and summaries
+// the plural is the last static segment of path
+func resourceName(path string) (string, string) {
+	var name string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" && !strings.HasPrefix(segment, ":") {
+			name = segment
+		}
+	}
+	return name, strings.TrimSuffix(name, "s")
+}
+
+// scopeErrorDocs returns the errors every operation can return because of the ownership and parent scopes
+func (rr *ResourceRouter[T, TCreate, TUpdate]) scopeErrorDocs() []openapi.ErrorResponse {
+	var scopeErrors []openapi.ErrorResponse
+	if rr.config.Ownership != nil {
+		scopeErrors = append(scopeErrors, openapi.ErrorResponse{Status: fiber.StatusUnauthorized, Code: "UNAUTHORIZED"})
+	}
+	if rr.config.Parent != nil {
+		scopeErrors = append(scopeErrors, openapi.ErrorResponse{Status: fiber.StatusNotFound, Code: "PARENT_NOT_FOUND"})
+	}
+	return scopeErrors
+}
+
+// listQueryDocs returns the query string parameters parseListQuery accepts for a list config
+func listQueryDocs(listConfig *ResourceListConfig) []openapi.Parameter {
+	query := []openapi.Parameter{
+		{Name: "limit", Type: "integer", Description: fmt.Sprintf("Page size, default %d and at most %d", listConfig.DefaultLimit, listConfig.MaxLimit)},
+		{Name: "offset", Type: "integer", Description: "Number of items to skip"},
+		{Name: "cursor", Description: "next_cursor from the previous page, replaces offset"},
+		{Name: "sort", Description: fmt.Sprintf("Comma separated fields, prefix with - to sort descending (default %s)", listConfig.DefaultSort)},
+	}
+	for _, filter := range slices.Sorted(maps.Keys(listConfig.FilterFields)) {
+		query = append(query, openapi.Parameter{Name: filter, Description: "Only items with this value"})
+	}
+	for _, name := range slices.Sorted(maps.Keys(listConfig.RangeParams)) {
+		query = append(query, openapi.Parameter{Name: name, Type: "integer", Description: listConfig.RangeParams[name].Description})
+	}
+	for _, field := range listConfig.JSONFields {
+		query = append(query, openapi.Parameter{Name: field + ".<path>", Description: "Only items whose JSON value at the path matches"})
+	}
+	return query
 }
 
 // withAuth wraps a handler with authentication middleware
 func (rr *ResourceRouter[T, TCreate, TUpdate]) withAuth(handler fiber.Handler) fiber.Handler {
 	return func(c fiber.Ctx) error {
-		if err := rr.apiServer.RequireAuth(c); err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Authentication required",
-				"code":  "UNAUTHORIZED",
-			})
+		if err := rr.apiServer.authenticate(c); err != nil {
+			return err
 		}
 		return handler(c)
 	}
 }
 
-// List returns all entities
+// withPermission wraps an authenticated handler with a permission check
+func (rr *ResourceRouter[T, TCreate, TUpdate]) withPermission(permission string, handler fiber.Handler) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if err := rr.apiServer.checkPermission(c, permission); err != nil {
+			return err
+		}
+		return handler(c)
+	}
+}
+
+// guard applies the auth and permission checks configured for an operation
+func (rr *ResourceRouter[T, TCreate, TUpdate]) guard(operation ResourceOperation, requireAuth bool, handler fiber.Handler) fiber.Handler {
+	if permission := rr.config.AuthConfig.Permissions[operation]; permission != "" {
+		return rr.withAuth(rr.withPermission(permission, handler))
+	}
+	if requireAuth {
+		return rr.withAuth(handler)
+	}
+	return handler
+}
+
+// ownerFilters returns the filters that scope a query to the authenticated user
+// No filters are returned if ownership is disabled or the user has the admin role
+func (rr *ResourceRouter[T, TCreate, TUpdate]) ownerFilters(c fiber.Ctx) ([]store.Filter, error) {
+	ownership := rr.config.Ownership
+	if ownership == nil {
+		return nil, nil
+	}
+	if ownership.AdminRole != "" && HasRole(c, ownership.AdminRole) {
+		return nil, nil
+	}
+	userID, ok := GetUserIDFromContext(c)
+	if !ok || userID == "" {
+		return nil, fmt.Errorf("user ID is required for an owned resource")
+	}
+	return []store.Filter{{Expression: ownership.Column, Value: userID}}, nil
+}
+
+// errOwnershipRequired is returned when an owned resource is accessed without a user
+var errOwnershipRequired = apierror.Unauthorized("Authentication required")
+
+// errParentNotFound is returned by scopeFilters when the parent can't be accessed
+var errParentNotFound = errors.New("parent not found")
+
+// scopeFilters returns the owner filters plus the filter scoping rows to the parent in the URL
+// The parent is authorized before any filter is returned
+func (rr *ResourceRouter[T, TCreate, TUpdate]) scopeFilters(c fiber.Ctx) ([]store.Filter, error) {
+	filters, err := rr.ownerFilters(c)
+	if err != nil {
+		return nil, err
+	}
+	parent := rr.config.Parent
+	if parent == nil {
+		return filters, nil
+	}
+	parentID := c.Params(parent.Param)
+	if parentID == "" {
+		return nil, errParentNotFound
+	}
+	if parent.Authorize != nil {
+		if err := parent.Authorize(c, parentID); err != nil {
+			log.Debug().Err(err).Str("parent_id", parentID).Msg("Parent authorization failed")
+			return nil, errParentNotFound
+		}
+	}
+	return append(filters, store.Filter{Expression: parent.Column, Value: parentID}), nil
+}
+
+// scopeError is the error returned when scopeFilters fails
+func scopeError(err error) error {
+	if errors.Is(err, errParentNotFound) {
+		return apierror.New(fiber.StatusNotFound, types.ErrorCodeParentNotFound, "Parent not found")
+	}
+	return errOwnershipRequired
+}
+
+// List returns a page of entities
+// The query string can set limit/offset or cursor pagination, sort and filters
+// as allowed by the ListConfig, the response is a ListResponse envelope
 func (rr *ResourceRouter[T, TCreate, TUpdate]) List(c fiber.Ctx) error {
-	var entities []T
-	if err := rr.repo.FindAll(&entities); err != nil {
+	return rr.list(c, rr.config.ListConfig, rr.repo.List)
+}
+
+// list responds with the page of entities load returns for the request's list query
+func (rr *ResourceRouter[T, TCreate, TUpdate]) list(
+	c fiber.Ctx,
+	listConfig *ResourceListConfig,
+	load func(query *store.ListQuery) (*store.ListResult[T], error),
+) error {
+	query, err := parseListQuery(c, listConfig)
+	if err != nil {
+		return apierror.BadRequest(types.ErrorCodeInvalidQuery, err.Error())
+	}
+
+	scopeFilters, err := rr.scopeFilters(c)
+	if err != nil {
+		return scopeError(err)
+	}
+	query.Filters = append(query.Filters, scopeFilters...)
+
+	result, err := load(query)
+	if err != nil {
 		log.Error().Err(err).Msg("Failed to list entities")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to retrieve entities",
-			"code":  "LIST_FAILED",
-		})
+		return apierror.Internal(types.ErrorCodeListFailed, "Failed to retrieve entities")
 	}
 
-	return c.Status(fiber.StatusOK).JSON(entities)
+	return respondList(c, query, result)
 }
 
 // Get returns a single entity by ID
 func (rr *ResourceRouter[T, TCreate, TUpdate]) Get(c fiber.Ctx) error {
-	id := c.Params("id")
+	id := c.Params(rr.config.IDParam)
 	if id == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "ID parameter is required",
-			"code":  "MISSING_ID",
-		})
+		return apierror.BadRequest(types.ErrorCodeMissingID, "ID parameter is required")
+	}
+
+	scopeFilters, err := rr.scopeFilters(c)
+	if err != nil {
+		return scopeError(err)
 	}
 
 	var entity T
-	if err := rr.repo.FindByID(id, &entity); err != nil {
+	if err := rr.repo.FindByID(id, &entity, scopeFilters...); err != nil {
 		log.Error().Err(err).Str("id", id).Msg("Failed to find entity")
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Entity not found",
-			"code":  "NOT_FOUND",
-		})
+		return apierror.NotFound("Entity not found")
+	}
+
+	rr.setETag(c, &entity)
+	if rr.notModified(c, &entity) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(entity)
@@ -138,40 +489,61 @@ func (rr *ResourceRouter[T, TCreate, TUpdate]) Create(c fiber.Ctx) error {
 	// Parse request body
 	req, err := getRequestData[TCreate](c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-			"code":  "INVALID_REQUEST",
-		})
+		return apierror.BadRequest(types.ErrorCodeInvalidRequest, "Invalid request body")
+	}
+
+	// Check the request against its validate tags before it reaches the mapper
+	fields, err := validateRequest(req)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to validate request")
+		return apierror.Internal(types.ErrorCodeValidationError, "Failed to validate request")
+	}
+	if len(fields) > 0 {
+		return apierror.Validation(fields)
 	}
 
 	// Map request to entity
 	entity, err := rr.config.Mapper.CreateToEntity(req)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to map create request to entity")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request data",
-			"code":  "MAPPING_FAILED",
-		})
+		log.Debug().Err(err).Msg("Failed to map create request to entity")
+		return apierror.BadRequest(types.ErrorCodeMappingFailed, err.Error())
+	}
+
+	// Owned resources always belong to the user creating them
+	if rr.config.Ownership != nil {
+		userID, ok := GetUserIDFromContext(c)
+		if !ok || userID == "" {
+			return errOwnershipRequired
+		}
+		if err := rr.repo.SetField(entity, rr.config.Ownership.Column, userID); err != nil {
+			log.Error().Err(err).Msg("Failed to set owner on entity")
+			return apierror.Internal(types.ErrorCodeCreateFailed, "Failed to create entity")
+		}
+	}
+
+	// Nested resources always belong to the parent in the URL
+	if rr.config.Parent != nil {
+		if _, err := rr.scopeFilters(c); err != nil {
+			return scopeError(err)
+		}
+		if err := rr.repo.SetField(entity, rr.config.Parent.Column, c.Params(rr.config.Parent.Param)); err != nil {
+			log.Error().Err(err).Msg("Failed to set parent on entity")
+			return apierror.Internal(types.ErrorCodeCreateFailed, "Failed to create entity")
+		}
 	}
 
 	// Call BeforeCreate hook if provided
 	if rr.config.Hooks != nil && rr.config.Hooks.BeforeCreate != nil {
 		if err := rr.config.Hooks.BeforeCreate(c, entity); err != nil {
 			log.Error().Err(err).Msg("BeforeCreate hook failed")
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": err.Error(),
-				"code":  "BEFORE_CREATE_FAILED",
-			})
+			return apierror.BadRequest(types.ErrorCodeBeforeCreateFailed, err.Error())
 		}
 	}
 
 	// Create entity in database
 	if err := rr.repo.Create(entity); err != nil {
 		log.Error().Err(err).Msg("Failed to create entity")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create entity",
-			"code":  "CREATE_FAILED",
-		})
+		return apierror.Internal(types.ErrorCodeCreateFailed, "Failed to create entity")
 	}
 
 	// Call AfterCreate hook if provided
@@ -182,67 +554,118 @@ func (rr *ResourceRouter[T, TCreate, TUpdate]) Create(c fiber.Ctx) error {
 		}
 	}
 
+	id, err := rr.repo.ID(entity)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to read the ID of the created entity")
+	}
+	rr.auditEntity(c, types.AuditActionCreate, id, nil, entity)
+	rr.publishChange(c, types.AuditActionCreate, id, entity)
+
+	rr.setETag(c, entity)
 	return c.Status(fiber.StatusCreated).JSON(entity)
 }
 
-// Update updates an existing entity
+// Update replaces the updatable fields of an existing entity with the request body
 func (rr *ResourceRouter[T, TCreate, TUpdate]) Update(c fiber.Ctx) error {
+	// Parse request body
+	req, err := getRequestData[TUpdate](c)
+	if err != nil {
+		return apierror.BadRequest(types.ErrorCodeInvalidRequest, "Invalid request body")
+	}
+
+	return rr.update(c, func(*T) (*TUpdate, error) {
+		return req, nil
+	})
+}
+
+// Patch applies a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to an existing entity
+// The patch is applied to the entity's JSON form and the result is handled like an Update body,
+// so only the fields of the update request can change
+func (rr *ResourceRouter[T, TCreate, TUpdate]) Patch(c fiber.Ctx) error {
+	return rr.update(c, func(entity *T) (*TUpdate, error) {
+		return patchRequest[T, TUpdate](c, entity)
+	})
+}
+
+// update loads the entity in the URL, builds the update request for it and then
+// validates, maps and saves it with the update hooks
+func (rr *ResourceRouter[T, TCreate, TUpdate]) update(c fiber.Ctx, buildRequest func(entity *T) (*TUpdate, error)) error {
 	// Get ID from URL
-	id := c.Params("id")
+	id := c.Params(rr.config.IDParam)
 	if id == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "ID parameter is required",
-			"code":  "MISSING_ID",
-		})
+		return apierror.BadRequest(types.ErrorCodeMissingID, "ID parameter is required")
 	}
 
-	// Parse request body
-	req, err := getRequestData[TUpdate](c)
+	scopeFilters, err := rr.scopeFilters(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-			"code":  "INVALID_REQUEST",
-		})
+		return scopeError(err)
 	}
 
 	// Fetch existing entity
 	var entity T
-	if err := rr.repo.FindByID(id, &entity); err != nil {
+	if err := rr.repo.FindByID(id, &entity, scopeFilters...); err != nil {
 		log.Error().Err(err).Str("id", id).Msg("Failed to find entity for update")
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Entity not found",
-			"code":  "NOT_FOUND",
-		})
+		return apierror.NotFound("Entity not found")
+	}
+
+	// If-Match makes the update conditional on the version the client last saw
+	if err := rr.checkIfMatch(c, &entity); err != nil {
+		return err
+	}
+
+	// The entity as it is before the update is kept for the audit log and as a revision
+	previous, err := json.Marshal(entity)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to encode entity before update")
+		return apierror.Internal(types.ErrorCodeUpdateFailed, "Failed to update entity")
+	}
+
+	req, err := buildRequest(&entity)
+	if err != nil {
+		return err
+	}
+
+	// Check the request against its validate tags before it reaches the mapper
+	fields, err := validateRequest(req)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to validate request")
+		return apierror.Internal(types.ErrorCodeValidationError, "Failed to validate request")
+	}
+	if len(fields) > 0 {
+		return apierror.Validation(fields)
 	}
 
 	// Map update request to entity
 	if err := rr.config.Mapper.UpdateToEntity(&entity, req); err != nil {
-		log.Error().Err(err).Msg("Failed to map update request to entity")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request data",
-			"code":  "MAPPING_FAILED",
-		})
+		log.Debug().Err(err).Msg("Failed to map update request to entity")
+		return apierror.BadRequest(types.ErrorCodeMappingFailed, err.Error())
 	}
 
 	// Call BeforeUpdate hook if provided
 	if rr.config.Hooks != nil && rr.config.Hooks.BeforeUpdate != nil {
 		if err := rr.config.Hooks.BeforeUpdate(c, &entity); err != nil {
 			log.Error().Err(err).Msg("BeforeUpdate hook failed")
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": err.Error(),
-				"code":  "BEFORE_UPDATE_FAILED",
-			})
+			return apierror.BadRequest(types.ErrorCodeBeforeUpdateFailed, err.Error())
 		}
 	}
 
 	// Update entity in database
 	if err := rr.repo.Update(&entity); err != nil {
+		if errors.Is(err, store.ErrVersionConflict) {
+			return versionConflict(c)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apierror.NotFound("Entity not found")
+		}
 		log.Error().Err(err).Msg("Failed to update entity")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update entity",
-			"code":  "UPDATE_FAILED",
-		})
+		return apierror.Internal(types.ErrorCodeUpdateFailed, "Failed to update entity")
+	}
+
+	if rr.config.Revisions {
+		rr.recordRevision(c, id, previous)
 	}
+	rr.auditEntity(c, types.AuditActionUpdate, id, previous, &entity)
+	rr.publishChange(c, types.AuditActionUpdate, id, &entity)
 
 	// Call AfterUpdate hook if provided
 	if rr.config.Hooks != nil && rr.config.Hooks.AfterUpdate != nil {
@@ -252,63 +675,84 @@ func (rr *ResourceRouter[T, TCreate, TUpdate]) Update(c fiber.Ctx) error {
 		}
 	}
 
+	rr.setETag(c, &entity)
 	return c.Status(fiber.StatusOK).JSON(entity)
 }
 
 // Delete deletes an entity by ID
 func (rr *ResourceRouter[T, TCreate, TUpdate]) Delete(c fiber.Ctx) error {
 	// Get ID from URL
-	id := c.Params("id")
+	id := c.Params(rr.config.IDParam)
 	if id == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "ID parameter is required",
-			"code":  "MISSING_ID",
-		})
+		return apierror.BadRequest(types.ErrorCodeMissingID, "ID parameter is required")
+	}
+
+	scopeFilters, err := rr.scopeFilters(c)
+	if err != nil {
+		return scopeError(err)
 	}
 
 	// Fetch existing entity for BeforeDelete hook
 	var entity T
-	fetchErr := rr.repo.FindByID(id, &entity)
+	fetchErr := rr.repo.FindByID(id, &entity, scopeFilters...)
 
 	// Call BeforeDelete hook if provided (even if fetch failed, pass the id)
 	if rr.config.Hooks != nil && rr.config.Hooks.BeforeDelete != nil {
 		if err := rr.config.Hooks.BeforeDelete(c, id, &entity); err != nil {
 			log.Error().Err(err).Msg("BeforeDelete hook failed")
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": err.Error(),
-				"code":  "BEFORE_DELETE_FAILED",
-			})
+			return apierror.BadRequest(types.ErrorCodeBeforeDeleteFailed, err.Error())
 		}
 	}
 
 	// If we couldn't fetch the entity and no hook prevented deletion, return not found
 	if fetchErr != nil {
 		log.Error().Err(fetchErr).Str("id", id).Msg("Failed to find entity for deletion")
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Entity not found",
-			"code":  "NOT_FOUND",
-		})
+		return apierror.NotFound("Entity not found")
+	}
+
+	if err := rr.checkIfMatch(c, &entity); err != nil {
+		return err
+	}
+
+	// The deleted entity is kept in the audit log
+	previous, err := json.Marshal(entity)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to encode entity before delete")
+		return apierror.Internal(types.ErrorCodeDeleteFailed, "Failed to delete entity")
+	}
+
+	// Versioned entities are only deleted if they are still the version that was checked
+	deleteFilters := scopeFilters
+	if rr.repo.Versioned() {
+		version, err := rr.repo.Version(&entity)
+		if err != nil {
+			log.Error().Err(err).Str("id", id).Msg("Failed to read entity version")
+			return apierror.Internal(types.ErrorCodeDeleteFailed, "Failed to delete entity")
+		}
+		deleteFilters = append(slices.Clone(scopeFilters), store.Filter{Expression: store.VERSION_COLUMN, Value: version})
 	}
 
 	// Delete entity from database
-	if err := rr.repo.Delete(id); err != nil {
+	if err := rr.repo.Delete(id, deleteFilters...); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return rr.deleteConflict(c, id, scopeFilters)
+		}
 		log.Error().Err(err).Msg("Failed to delete entity")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete entity",
-			"code":  "DELETE_FAILED",
-		})
+		return apierror.Internal(types.ErrorCodeDeleteFailed, "Failed to delete entity")
 	}
+	rr.auditEntity(c, types.AuditActionDelete, id, previous, nil)
+	rr.publishChange(c, types.AuditActionDelete, id, &entity)
 
 	// Call AfterDelete hook if provided
 	if rr.config.Hooks != nil && rr.config.Hooks.AfterDelete != nil {
-		if err := rr.config.Hooks.AfterDelete(c, id); err != nil {
+		if err := rr.config.Hooks.AfterDelete(c, id, &entity); err != nil {
 			log.Warn().Err(err).Msg("AfterDelete hook failed (entity already deleted)")
 			// Don't return error since entity is already deleted
 		}
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Entity deleted successfully",
-		"id":      id,
+	return c.Status(fiber.StatusOK).JSON(MessageResponse{
+		Message: "Entity deleted successfully",
+		ID:      id,
 	})
 }
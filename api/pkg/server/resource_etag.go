@@ -0,0 +1,118 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// errPreconditionFailed is returned when If-Match doesn't list the entity's ETag
+var errPreconditionFailed = apierror.New(fiber.StatusPreconditionFailed, types.ErrorCodePreconditionFailed,
+	"The entity has changed, load it again to get the current ETag")
+
+// etag returns the strong ETag of an entity - its version in quotes
+// entities of unversioned repositories have no ETag
+func (rr *ResourceRouter[T, TCreate, TUpdate]) etag(entity *T) (string, error) {
+	if !rr.repo.Versioned() {
+		return "", nil
+	}
+	version, err := rr.repo.Version(entity)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`"%d"`, version), nil
+}
+
+// setETag sends the ETag of the entity with the response
+// the entity has already been saved so a missing version is only logged
+func (rr *ResourceRouter[T, TCreate, TUpdate]) setETag(c fiber.Ctx, entity *T) {
+	etag, err := rr.etag(entity)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read entity version")
+		return
+	}
+	if etag != "" {
+		c.Set(fiber.HeaderETag, etag)
+	}
+}
+
+// checkIfMatch enforces the If-Match header of a request that changes the entity
+// the change is only allowed if the entity still has one of the listed ETags
+func (rr *ResourceRouter[T, TCreate, TUpdate]) checkIfMatch(c fiber.Ctx, entity *T) error {
+	header := c.Get(fiber.HeaderIfMatch)
+	if header == "" {
+		return nil
+	}
+	etag, err := rr.etag(entity)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read entity version")
+		return apierror.Internal(types.ErrorCodeInternalError, "Failed to check If-Match")
+	}
+	// If-Match uses the strong comparison so weak ETags never match
+	if !etagMatches(header, etag, false) {
+		return errPreconditionFailed
+	}
+	return nil
+}
+
+// notModified reports whether the If-None-Match header of a read lists the entity's ETag
+func (rr *ResourceRouter[T, TCreate, TUpdate]) notModified(c fiber.Ctx, entity *T) bool {
+	header := c.Get(fiber.HeaderIfNoneMatch)
+	if header == "" {
+		return false
+	}
+	etag, err := rr.etag(entity)
+	if err != nil || etag == "" {
+		return false
+	}
+	// If-None-Match uses the weak comparison
+	return etagMatches(header, etag, true)
+}
+
+// versionConflict is the error for an update that lost a race with another writer
+// clients that sent If-Match get 412 like any other failed precondition
+func versionConflict(c fiber.Ctx) error {
+	if c.Get(fiber.HeaderIfMatch) != "" {
+		return errPreconditionFailed
+	}
+	return apierror.Conflict(types.ErrorCodeVersionConflict, "The entity was changed by another request")
+}
+
+// deleteConflict is the response to a delete that matched no row after the entity was loaded
+// it was either deleted by another request or changed since its version was checked
+func (rr *ResourceRouter[T, TCreate, TUpdate]) deleteConflict(c fiber.Ctx, id string, scopeFilters []store.Filter) error {
+	var current T
+	if err := rr.repo.FindByID(id, &current, scopeFilters...); err != nil {
+		return apierror.NotFound("Entity not found")
+	}
+	return versionConflict(c)
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header lists the ETag
+// "*" matches any current entity, weak ETags (W/"1") only match when weak is true
+func etagMatches(header string, etag string, weak bool) bool {
+	if etag == "" {
+		return strings.TrimSpace(header) == "*"
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// query parameters used by List that are never treated as filters
+var reservedListParams = map[string]bool{
+	"limit":        true,
+	"offset":       true,
+	"cursor":       true,
+	"sort":         true,
+	"token":        true,
+	"access_token": true,
+	"nolog":        true,
+}
+
+// jsonPathSegment is the allowed format of each key in a JSONB filter path
+var jsonPathSegment = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// ListResponse is the envelope returned by ResourceRouter.List
+type ListResponse[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// parseListQuery builds a store.ListQuery from the request query string
+//
+//	?limit=20&offset=40          - offset pagination
+//	?limit=20&cursor=<cursor>    - cursor pagination using next_cursor from the previous page
+//	?sort=-created_at,id         - sort by whitelisted fields, "-" for descending
+//	?user_id=abc                 - equality filter on a whitelisted field
+//	?config.name=foo             - equality filter on a path inside a whitelisted JSONB column
+//	?from=1700000000             - comparison with a configured range parameter
+func parseListQuery(c fiber.Ctx, listConfig *ResourceListConfig) (*store.ListQuery, error) {
+	query := &store.ListQuery{
+		Limit: listConfig.DefaultLimit,
+	}
+
+	if limitParam := c.Query("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("limit must be a positive integer")
+		}
+		query.Limit = limit
+	}
+	if listConfig.MaxLimit > 0 && query.Limit > listConfig.MaxLimit {
+		query.Limit = listConfig.MaxLimit
+	}
+
+	if offsetParam := c.Query("offset"); offsetParam != "" {
+		offset, err := strconv.Atoi(offsetParam)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("offset must be a non-negative integer")
+		}
+		query.Offset = offset
+	}
+
+	sortParam := c.Query("sort", listConfig.DefaultSort)
+	if sortParam != "" {
+		for _, name := range strings.Split(sortParam, ",") {
+			name = strings.TrimSpace(name)
+			desc := strings.HasPrefix(name, "-")
+			name = strings.TrimPrefix(name, "-")
+			column, ok := listConfig.SortFields[name]
+			if !ok {
+				return nil, fmt.Errorf("cannot sort by %q", name)
+			}
+			query.Sort = append(query.Sort, store.SortField{Column: column, Desc: desc})
+		}
+	}
+
+	if cursorParam := c.Query("cursor"); cursorParam != "" {
+		if len(query.Sort) > 1 {
+			return nil, fmt.Errorf("cursor cannot be combined with more than one sort field")
+		}
+		cursor, err := decodeCursor(cursorParam)
+		if err != nil {
+			return nil, err
+		}
+		query.Cursor = cursor
+	}
+
+	for key, value := range c.Queries() {
+		if reservedListParams[key] {
+			continue
+		}
+		if rangeParam, ok := listConfig.RangeParams[key]; ok {
+			bound, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an integer", key)
+			}
+			query.Filters = append(query.Filters, store.Filter{Expression: rangeParam.Column, Op: rangeParam.Op, Value: bound})
+			continue
+		}
+		expression, err := filterExpression(key, listConfig)
+		if err != nil {
+			return nil, err
+		}
+		query.Filters = append(query.Filters, store.Filter{Expression: expression, Value: value})
+	}
+
+	return query, nil
+}
+
+// filterExpression returns the SQL expression for a filter parameter
+// it must either be a whitelisted field or a path into a whitelisted JSONB column
+func filterExpression(key string, listConfig *ResourceListConfig) (string, error) {
+	if column, ok := listConfig.FilterFields[key]; ok {
+		return column, nil
+	}
+
+	parts := strings.Split(key, ".")
+	if len(parts) > 1 {
+		for _, jsonField := range listConfig.JSONFields {
+			if parts[0] != jsonField {
+				continue
+			}
+			for _, segment := range parts[1:] {
+				if !jsonPathSegment.MatchString(segment) {
+					return "", fmt.Errorf("invalid filter path %q", key)
+				}
+			}
+			return fmt.Sprintf("%s #>> '{%s}'", jsonField, strings.Join(parts[1:], ",")), nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot filter by %q", key)
+}
+
+// respondList sends a page of a list query as a ListResponse
+func respondList[T any](c fiber.Ctx, query *store.ListQuery, result *store.ListResult[T]) error {
+	nextCursor, err := encodeCursor(result.NextCursor)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode list cursor")
+		return apierror.Internal(types.ErrorCodeListFailed, "Failed to retrieve entities")
+	}
+
+	items := result.Items
+	if items == nil {
+		items = []T{}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ListResponse[T]{
+		Items:      items,
+		Total:      result.Total,
+		Limit:      query.Limit,
+		Offset:     query.Offset,
+		NextCursor: nextCursor,
+	})
+}
+
+func encodeCursor(cursor *store.Cursor) (string, error) {
+	if cursor == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(encoded string) (*store.Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var cursor store.Cursor
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cursor); err != nil || cursor.ID == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	// keep integers as integers so they compare correctly against bigint columns
+	if number, ok := cursor.Value.(json.Number); ok {
+		if intValue, err := number.Int64(); err == nil {
+			cursor.Value = intValue
+		} else if floatValue, err := number.Float64(); err == nil {
+			cursor.Value = floatValue
+		}
+	}
+	return &cursor, nil
+}
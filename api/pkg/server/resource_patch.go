@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gofiber/fiber/v3"
+)
+
+// media types accepted by ResourceRouter.Patch
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// patchContentTypes lists the media types a PATCH body can be sent as
+var patchContentTypes = []string{mergePatchContentType, jsonPatchContentType}
+
+// patchRequest applies the PATCH body to the JSON form of the entity
+// and decodes the result as an update request
+// Fields of the entity that are not in the update request are read only -
+// a patch that changes them is rejected rather than silently ignored
+func patchRequest[T any, TUpdate any](c fiber.Ctx, entity *T) (*TUpdate, error) {
+	contentType, _, _ := strings.Cut(c.Get(fiber.HeaderContentType), ";")
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if !slices.Contains(patchContentTypes, contentType) {
+		return nil, apierror.New(fiber.StatusUnsupportedMediaType, types.ErrorCodeUnsupportedMediaType,
+			fmt.Sprintf("PATCH bodies must be %s", strings.Join(patchContentTypes, " or ")))
+	}
+
+	original, err := json.Marshal(entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode entity: %w", err)
+	}
+
+	var patched []byte
+	switch contentType {
+	case mergePatchContentType:
+		patched, err = jsonpatch.MergePatch(original, c.Body())
+	case jsonPatchContentType:
+		var patch jsonpatch.Patch
+		patch, err = jsonpatch.DecodePatch(c.Body())
+		if err == nil {
+			patched, err = patch.Apply(original)
+		}
+	}
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return nil, apierror.Conflict(types.ErrorCodePatchTestFailed, err.Error())
+	}
+	if err != nil {
+		return nil, apierror.BadRequest(types.ErrorCodeInvalidPatch, err.Error())
+	}
+
+	var update TUpdate
+	readOnly, err := readOnlyChanges(original, patched, reflect.TypeOf(update))
+	if err != nil {
+		return nil, apierror.BadRequest(types.ErrorCodeInvalidPatch, err.Error())
+	}
+	if len(readOnly) > 0 {
+		return nil, apierror.BadRequest(types.ErrorCodeReadOnlyField,
+			fmt.Sprintf("Read only fields cannot be patched: %s", strings.Join(readOnly, ", ")))
+	}
+
+	if err := json.Unmarshal(patched, &update); err != nil {
+		return nil, apierror.BadRequest(types.ErrorCodeInvalidPatch, err.Error())
+	}
+	return &update, nil
+}
+
+// readOnlyChanges returns the top level fields the patch changed
+// that are not fields of the update request, sorted by name
+func readOnlyChanges(original []byte, patched []byte, updateType reflect.Type) ([]string, error) {
+	var before, after map[string]any
+	if err := json.Unmarshal(original, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return nil, fmt.Errorf("the patched document must be an object: %w", err)
+	}
+
+	writable := jsonFieldNames(updateType)
+	var changed []string
+	for key, value := range after {
+		if !writable[key] && !reflect.DeepEqual(before[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok && !writable[key] {
+			changed = append(changed, key)
+		}
+	}
+	slices.Sort(changed)
+	return changed, nil
+}
+
+// jsonFieldNames returns the names a struct's fields are encoded with
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return names
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// embedded structs without a json name are flattened into the parent
+		if field.Anonymous && name == "" {
+			for embedded := range jsonFieldNames(field.Type) {
+				names[embedded] = true
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
@@ -0,0 +1,281 @@
+package server
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+)
+
+// patchTestEntity has id and owner as read only fields as they are not in patchTestUpdate
+type patchTestEntity struct {
+	ID     string            `json:"id"`
+	Owner  string            `json:"owner"`
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Tags   []string          `json:"tags,omitempty"`
+}
+
+type patchTestUpdate struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Tags   []string          `json:"tags,omitempty"`
+}
+
+func newPatchTestEntity() *patchTestEntity {
+	return &patchTestEntity{
+		ID:     "entity-1",
+		Owner:  "user-1",
+		Name:   "The Cat",
+		Labels: map[string]string{"a/b": "slash", "c~d": "tilde", "mood": "happy"},
+		Tags:   []string{"cat", "hat"},
+	}
+}
+
+// runPatchRequest sends body as a PATCH with the content type through patchRequest
+func runPatchRequest(t *testing.T, contentType string, body string) (*patchTestUpdate, error) {
+	t.Helper()
+	var update *patchTestUpdate
+	var patchErr error
+	app := fiber.New()
+	app.Patch("/", func(c fiber.Ctx) error {
+		update, patchErr = patchRequest[patchTestEntity, patchTestUpdate](c, newPatchTestEntity())
+		return nil
+	})
+
+	req := httptest.NewRequest(fiber.MethodPatch, "/", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, contentType)
+	if _, err := app.Test(req); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	return update, patchErr
+}
+
+func TestPatchRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        *patchTestUpdate
+		wantStatus  int
+		wantCode    types.ErrorCode
+		wantDetail  string
+	}{
+		{
+			name:        "merge patch sets a field",
+			contentType: mergePatchContentType,
+			body:        `{"name":"The Dog"}`,
+			want: &patchTestUpdate{
+				Name:   "The Dog",
+				Labels: map[string]string{"a/b": "slash", "c~d": "tilde", "mood": "happy"},
+				Tags:   []string{"cat", "hat"},
+			},
+		},
+		{
+			name:        "merge patch null removes a key",
+			contentType: mergePatchContentType,
+			body:        `{"labels":{"mood":null,"size":"small"}}`,
+			want: &patchTestUpdate{
+				Name:   "The Cat",
+				Labels: map[string]string{"a/b": "slash", "c~d": "tilde", "size": "small"},
+				Tags:   []string{"cat", "hat"},
+			},
+		},
+		{
+			name:        "merge patch null removes a field",
+			contentType: mergePatchContentType + "; charset=utf-8",
+			body:        `{"tags":null}`,
+			want: &patchTestUpdate{
+				Name:   "The Cat",
+				Labels: map[string]string{"a/b": "slash", "c~d": "tilde", "mood": "happy"},
+			},
+		},
+		{
+			name:        "json patch path with an escaped slash",
+			contentType: jsonPatchContentType,
+			body:        `[{"op":"replace","path":"/labels/a~1b","value":"escaped"}]`,
+			want: &patchTestUpdate{
+				Name:   "The Cat",
+				Labels: map[string]string{"a/b": "escaped", "c~d": "tilde", "mood": "happy"},
+				Tags:   []string{"cat", "hat"},
+			},
+		},
+		{
+			name:        "json patch path with an escaped tilde",
+			contentType: jsonPatchContentType,
+			body:        `[{"op":"remove","path":"/labels/c~0d"}]`,
+			want: &patchTestUpdate{
+				Name:   "The Cat",
+				Labels: map[string]string{"a/b": "slash", "mood": "happy"},
+				Tags:   []string{"cat", "hat"},
+			},
+		},
+		{
+			name:        "json patch test passes",
+			contentType: jsonPatchContentType,
+			body:        `[{"op":"test","path":"/name","value":"The Cat"},{"op":"add","path":"/tags/-","value":"mat"}]`,
+			want: &patchTestUpdate{
+				Name:   "The Cat",
+				Labels: map[string]string{"a/b": "slash", "c~d": "tilde", "mood": "happy"},
+				Tags:   []string{"cat", "hat", "mat"},
+			},
+		},
+		{
+			name:        "json patch test fails",
+			contentType: jsonPatchContentType,
+			body:        `[{"op":"test","path":"/name","value":"The Dog"},{"op":"replace","path":"/name","value":"The Bird"}]`,
+			wantStatus:  fiber.StatusConflict,
+			wantCode:    types.ErrorCodePatchTestFailed,
+		},
+		{
+			name:        "json patch test of an escaped path fails",
+			contentType: jsonPatchContentType,
+			body:        `[{"op":"test","path":"/labels/a~1b","value":"tilde"}]`,
+			wantStatus:  fiber.StatusConflict,
+			wantCode:    types.ErrorCodePatchTestFailed,
+		},
+		{
+			name:        "merge patch of a read only field",
+			contentType: mergePatchContentType,
+			body:        `{"owner":"user-2","name":"The Dog"}`,
+			wantStatus:  fiber.StatusBadRequest,
+			wantCode:    types.ErrorCodeReadOnlyField,
+			wantDetail:  "owner",
+		},
+		{
+			name:        "merge patch setting a read only field to its value",
+			contentType: mergePatchContentType,
+			body:        `{"owner":"user-1","name":"The Dog"}`,
+			want: &patchTestUpdate{
+				Name:   "The Dog",
+				Labels: map[string]string{"a/b": "slash", "c~d": "tilde", "mood": "happy"},
+				Tags:   []string{"cat", "hat"},
+			},
+		},
+		{
+			name:        "json patch removing read only fields",
+			contentType: jsonPatchContentType,
+			body:        `[{"op":"remove","path":"/owner"},{"op":"remove","path":"/id"}]`,
+			wantStatus:  fiber.StatusBadRequest,
+			wantCode:    types.ErrorCodeReadOnlyField,
+			wantDetail:  "id, owner",
+		},
+		{
+			name:        "json patch of a missing path",
+			contentType: jsonPatchContentType,
+			body:        `[{"op":"remove","path":"/missing"}]`,
+			wantStatus:  fiber.StatusBadRequest,
+			wantCode:    types.ErrorCodeInvalidPatch,
+		},
+		{
+			name:        "json patch replacing the document with an array",
+			contentType: jsonPatchContentType,
+			body:        `[{"op":"replace","path":"","value":[]}]`,
+			wantStatus:  fiber.StatusBadRequest,
+			wantCode:    types.ErrorCodeInvalidPatch,
+		},
+		{
+			name:        "plain JSON body",
+			contentType: fiber.MIMEApplicationJSON,
+			body:        `{"name":"The Dog"}`,
+			wantStatus:  fiber.StatusUnsupportedMediaType,
+			wantCode:    types.ErrorCodeUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, err := runPatchRequest(t, tt.contentType, tt.body)
+			if tt.wantCode != "" {
+				var apiErr *apierror.Error
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("expected an API error, got %v", err)
+				}
+				if apiErr.Status != tt.wantStatus || apiErr.Code != tt.wantCode {
+					t.Fatalf("expected %d %s, got %d %s: %s", tt.wantStatus, tt.wantCode, apiErr.Status, apiErr.Code, apiErr.Detail)
+				}
+				if !strings.Contains(apiErr.Detail, tt.wantDetail) {
+					t.Errorf("expected detail containing %q, got %q", tt.wantDetail, apiErr.Detail)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(update, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, update)
+			}
+		})
+	}
+}
+
+func TestReadOnlyChanges(t *testing.T) {
+	updateType := reflect.TypeOf(patchTestUpdate{})
+	tests := []struct {
+		name     string
+		original string
+		patched  string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "writable fields changed",
+			original: `{"id":"1","name":"a","tags":["x"]}`,
+			patched:  `{"id":"1","name":"b","tags":["x","y"],"labels":{"k":"v"}}`,
+		},
+		{
+			name:     "read only field changed",
+			original: `{"id":"1","owner":"u1","name":"a"}`,
+			patched:  `{"id":"1","owner":"u2","name":"a"}`,
+			want:     []string{"owner"},
+		},
+		{
+			name:     "read only fields added and removed are sorted",
+			original: `{"owner":"u1","name":"a"}`,
+			patched:  `{"name":"a","id":"2","extra":true}`,
+			want:     []string{"extra", "id", "owner"},
+		},
+		{
+			name:     "nested read only value changed",
+			original: `{"owner":{"id":"u1","roles":["admin"]}}`,
+			patched:  `{"owner":{"id":"u1","roles":["user"]}}`,
+			want:     []string{"owner"},
+		},
+		{
+			name:     "read only field set to null",
+			original: `{"owner":"u1"}`,
+			patched:  `{"owner":null}`,
+			want:     []string{"owner"},
+		},
+		{
+			name:     "patched document is not an object",
+			original: `{"owner":"u1"}`,
+			patched:  `["owner"]`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, err := readOnlyChanges([]byte(tt.original), []byte(tt.patched), updateType)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(changed, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, changed)
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// revisionParam is the route parameter holding a revision number
+const revisionParam = "revision"
+
+// revisionListConfig is how the revisions of an entity can be paged and sorted
+func revisionListConfig() *ResourceListConfig {
+	return &ResourceListConfig{
+		DefaultLimit: 20,
+		MaxLimit:     100,
+		DefaultSort:  "-number",
+		SortFields: map[string]string{
+			"number":     "number",
+			"created_at": "created_at",
+		},
+	}
+}
+
+// registerRevisionRoutes registers the routes that read and revert the revisions of an entity
+func (rr *ResourceRouter[T, TCreate, TUpdate]) registerRevisionRoutes(router fiber.Router, itemPath string) {
+	authConfig := rr.config.AuthConfig
+	revisionsPath := itemPath + "/revisions"
+	revisionPath := fmt.Sprintf("%s/:%s", revisionsPath, revisionParam)
+
+	router.Get(revisionsPath, rr.guard(ResourceOperationGet, authConfig.RequireAuthForGet, rr.ListRevisions))
+	// Registered before the revision route so "diff" is not taken as a revision number
+	router.Get(revisionsPath+"/diff", rr.guard(ResourceOperationGet, authConfig.RequireAuthForGet, rr.DiffRevisions))
+	router.Get(revisionPath, rr.guard(ResourceOperationGet, authConfig.RequireAuthForGet, rr.GetRevision))
+	router.Post(revisionPath+"/revert", rr.guard(ResourceOperationUpdate, authConfig.RequireAuthForUpdate, rr.RevertRevision))
+}
+
+// recordRevision stores the JSON of the entity from before an update
+// the entity has already been saved so a failure is only logged
+func (rr *ResourceRouter[T, TCreate, TUpdate]) recordRevision(c fiber.Ctx, id string, previous []byte) {
+	resource, err := rr.repo.Table()
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to record revision (entity already updated)")
+		return
+	}
+	userID, _ := GetUserIDFromContext(c)
+	revision := &types.Revision{
+		ID:       uuid.New().String(),
+		Resource: resource,
+		EntityID: id,
+		UserID:   userID,
+		Snapshot: previous,
+	}
+	if err := rr.apiServer.store.Revisions().Record(revision); err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to record revision (entity already updated)")
+	}
+}
+
+// findEntity loads the entity in the URL within the scope of the request
+func (rr *ResourceRouter[T, TCreate, TUpdate]) findEntity(c fiber.Ctx) (string, *T, error) {
+	id := c.Params(rr.config.IDParam)
+	if id == "" {
+		return "", nil, apierror.BadRequest(types.ErrorCodeMissingID, "ID parameter is required")
+	}
+
+	scopeFilters, err := rr.scopeFilters(c)
+	if err != nil {
+		return "", nil, scopeError(err)
+	}
+
+	var entity T
+	if err := rr.repo.FindByID(id, &entity, scopeFilters...); err != nil {
+		log.Debug().Err(err).Str("id", id).Msg("Failed to find entity")
+		return "", nil, apierror.NotFound("Entity not found")
+	}
+	return id, &entity, nil
+}
+
+// findRevision loads a revision of the entity by its number
+func (rr *ResourceRouter[T, TCreate, TUpdate]) findRevision(id string, numberParam string) (*types.Revision, error) {
+	number, err := strconv.Atoi(numberParam)
+	if err != nil || number < 1 {
+		return nil, apierror.BadRequest(types.ErrorCodeInvalidRevision, "revision must be a positive integer")
+	}
+
+	resource, err := rr.repo.Table()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to find the revision resource")
+		return nil, apierror.Internal(types.ErrorCodeRevisionsFailed, "Failed to load revision")
+	}
+
+	revision, err := rr.apiServer.store.Revisions().FindByNumber(resource, id, number)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apierror.NotFound("Revision not found")
+	}
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Int("revision", number).Msg("Failed to load revision")
+		return nil, apierror.Internal(types.ErrorCodeRevisionsFailed, "Failed to load revision")
+	}
+	return revision, nil
+}
+
+// ListRevisions returns a page of the revisions of an entity, newest first
+func (rr *ResourceRouter[T, TCreate, TUpdate]) ListRevisions(c fiber.Ctx) error {
+	id, _, err := rr.findEntity(c)
+	if err != nil {
+		return err
+	}
+
+	query, err := parseListQuery(c, revisionListConfig())
+	if err != nil {
+		return apierror.BadRequest(types.ErrorCodeInvalidQuery, err.Error())
+	}
+
+	resource, err := rr.repo.Table()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to find the revision resource")
+		return apierror.Internal(types.ErrorCodeRevisionsFailed, "Failed to load revisions")
+	}
+	query.Filters = append(query.Filters,
+		store.Filter{Expression: "resource", Value: resource},
+		store.Filter{Expression: "entity_id", Value: id},
+	)
+
+	result, err := rr.apiServer.store.Revisions().List(query)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to list revisions")
+		return apierror.Internal(types.ErrorCodeRevisionsFailed, "Failed to load revisions")
+	}
+
+	return respondList(c, query, result)
+}
+
+// GetRevision returns a single revision of an entity
+func (rr *ResourceRouter[T, TCreate, TUpdate]) GetRevision(c fiber.Ctx) error {
+	id, _, err := rr.findEntity(c)
+	if err != nil {
+		return err
+	}
+
+	revision, err := rr.findRevision(id, c.Params(revisionParam))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(revision)
+}
+
+// DiffRevisions returns the JSON Patch that turns revision ?from= into revision ?to=
+// The current entity is used when ?to= is not given
+func (rr *ResourceRouter[T, TCreate, TUpdate]) DiffRevisions(c fiber.Ctx) error {
+	id, entity, err := rr.findEntity(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Query("from") == "" {
+		return apierror.BadRequest(types.ErrorCodeInvalidRevision, "from is required")
+	}
+	from, err := rr.findRevision(id, c.Query("from"))
+	if err != nil {
+		return err
+	}
+
+	var to []byte
+	if toParam := c.Query("to"); toParam != "" {
+		revision, err := rr.findRevision(id, toParam)
+		if err != nil {
+			return err
+		}
+		to = revision.Snapshot
+	} else if to, err = json.Marshal(entity); err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to encode entity")
+		return apierror.Internal(types.ErrorCodeRevisionsFailed, "Failed to diff revisions")
+	}
+
+	patch, err := diffJSON(from.Snapshot, to)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to diff revisions")
+		return apierror.Internal(types.ErrorCodeRevisionsFailed, "Failed to diff revisions")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(patch)
+}
+
+// RevertRevision updates the entity back to the content of a revision
+// The revision is decoded as an update request so the update is validated, mapped
+// and saved with the update hooks like any other - and is itself recorded as a revision
+func (rr *ResourceRouter[T, TCreate, TUpdate]) RevertRevision(c fiber.Ctx) error {
+	return rr.update(c, func(entity *T) (*TUpdate, error) {
+		revision, err := rr.findRevision(c.Params(rr.config.IDParam), c.Params(revisionParam))
+		if err != nil {
+			return nil, err
+		}
+		var req TUpdate
+		if err := json.Unmarshal(revision.Snapshot, &req); err != nil {
+			log.Error().Err(err).Str("revision", revision.ID).Msg("Failed to decode revision")
+			return nil, apierror.Internal(types.ErrorCodeRevisionsFailed, "Failed to read revision")
+		}
+		return &req, nil
+	})
+}
+
+// diffJSON returns the JSON Patch operations that turn the from document into the to document
+// Objects are compared key by key and arrays of the same length item by item,
+// anything else that differs is replaced whole
+func diffJSON(from []byte, to []byte) ([]types.JSONPatchOperation, error) {
+	var fromValue, toValue any
+	if err := json.Unmarshal(from, &fromValue); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(to, &toValue); err != nil {
+		return nil, err
+	}
+	patch := []types.JSONPatchOperation{}
+	if err := diffValues("", fromValue, toValue, &patch); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// diffValues appends the operations that turn from into to at the JSON pointer path
+func diffValues(path string, from any, to any, patch *[]types.JSONPatchOperation) error {
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+
+	fromObject, fromIsObject := from.(map[string]any)
+	toObject, toIsObject := to.(map[string]any)
+	if fromIsObject && toIsObject {
+		for _, key := range slices.Sorted(maps.Keys(fromObject)) {
+			if _, ok := toObject[key]; !ok {
+				*patch = append(*patch, types.JSONPatchOperation{Op: "remove", Path: pointer(path, key)})
+			}
+		}
+		for _, key := range slices.Sorted(maps.Keys(toObject)) {
+			fromValue, ok := fromObject[key]
+			if !ok {
+				if err := appendValueOperation(patch, "add", pointer(path, key), toObject[key]); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := diffValues(pointer(path, key), fromValue, toObject[key], patch); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fromArray, fromIsArray := from.([]any)
+	toArray, toIsArray := to.([]any)
+	if fromIsArray && toIsArray && len(fromArray) == len(toArray) {
+		for i := range fromArray {
+			if err := diffValues(pointer(path, strconv.Itoa(i)), fromArray[i], toArray[i], patch); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return appendValueOperation(patch, "replace", path, to)
+}
+
+// appendValueOperation appends an add or replace operation with the encoded value
+func appendValueOperation(patch *[]types.JSONPatchOperation, op string, path string, value any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	*patch = append(*patch, types.JSONPatchOperation{Op: op, Path: path, Value: encoded})
+	return nil
+}
+
+// pointer appends a key to a JSON pointer escaping it as RFC 6901 requires
+func pointer(path string, key string) string {
+	return path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// documentRevisionRoutes registers the revision operations in the OpenAPI document
+func (rr *ResourceRouter[T, TCreate, TUpdate]) documentRevisionRoutes(path string, itemPath string) {
+	name, singular := resourceName(path)
+	revisionsPath := itemPath + "/revisions"
+	revisionPath := fmt.Sprintf("%s/:%s", revisionsPath, revisionParam)
+	var entity T
+
+	rr.documentOperations(name, []resourceOperationDoc{
+		{ResourceOperationGet, rr.config.AuthConfig.RequireAuthForGet, openapi.Operation{
+			Method:   fiber.MethodGet,
+			Path:     revisionsPath,
+			Summary:  "List the revisions of a " + singular,
+			Query:    listQueryDocs(revisionListConfig()),
+			Response: ListResponse[types.Revision]{},
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "MISSING_ID"},
+				{Status: fiber.StatusBadRequest, Code: "INVALID_QUERY"},
+				{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+				{Status: fiber.StatusInternalServerError, Code: "REVISIONS_FAILED"},
+			},
+		}},
+		{ResourceOperationGet, rr.config.AuthConfig.RequireAuthForGet, openapi.Operation{
+			Method:  fiber.MethodGet,
+			Path:    revisionsPath + "/diff",
+			Summary: "Diff two revisions of a " + singular + " as a JSON Patch",
+			Query: []openapi.Parameter{
+				{Name: "from", Type: "integer", Required: true, Description: "The revision the patch applies to"},
+				{Name: "to", Type: "integer", Description: "The revision the patch produces, defaults to the current " + singular},
+			},
+			Response: []types.JSONPatchOperation{},
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "MISSING_ID"},
+				{Status: fiber.StatusBadRequest, Code: "INVALID_REVISION"},
+				{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+				{Status: fiber.StatusInternalServerError, Code: "REVISIONS_FAILED"},
+			},
+		}},
+		{ResourceOperationGet, rr.config.AuthConfig.RequireAuthForGet, openapi.Operation{
+			Method:   fiber.MethodGet,
+			Path:     revisionPath,
+			Summary:  "Get a revision of a " + singular,
+			Response: types.Revision{},
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "MISSING_ID"},
+				{Status: fiber.StatusBadRequest, Code: "INVALID_REVISION"},
+				{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+				{Status: fiber.StatusInternalServerError, Code: "REVISIONS_FAILED"},
+			},
+		}},
+		{ResourceOperationUpdate, rr.config.AuthConfig.RequireAuthForUpdate, openapi.Operation{
+			Method:   fiber.MethodPost,
+			Path:     revisionPath + "/revert",
+			Summary:  "Revert a " + singular + " to a revision",
+			Response: entity,
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "MISSING_ID"},
+				{Status: fiber.StatusBadRequest, Code: "INVALID_REVISION"},
+				{Status: fiber.StatusBadRequest, Code: "MAPPING_FAILED"},
+				{Status: fiber.StatusBadRequest, Code: "BEFORE_UPDATE_FAILED"},
+				{Status: fiber.StatusBadRequest, Code: "VALIDATION_FAILED"},
+				{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+				{Status: fiber.StatusConflict, Code: "VERSION_CONFLICT"},
+				{Status: fiber.StatusInternalServerError, Code: "REVISIONS_FAILED"},
+				{Status: fiber.StatusInternalServerError, Code: "UPDATE_FAILED"},
+			},
+		}},
+	})
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+func TestDiffJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []types.JSONPatchOperation
+	}{
+		{
+			name: "equal documents",
+			from: `{"name":"a","tags":["x"],"config":{"size":1}}`,
+			to:   `{"config":{"size":1},"tags":["x"],"name":"a"}`,
+			want: []types.JSONPatchOperation{},
+		},
+		{
+			name: "changed field",
+			from: `{"name":"a","version":1}`,
+			to:   `{"name":"b","version":1}`,
+			want: []types.JSONPatchOperation{
+				{Op: "replace", Path: "/name", Value: json.RawMessage(`"b"`)},
+			},
+		},
+		{
+			name: "removed keys come before added keys",
+			from: `{"b":1,"a":2,"keep":true}`,
+			to:   `{"keep":true,"d":3,"c":null}`,
+			want: []types.JSONPatchOperation{
+				{Op: "remove", Path: "/a"},
+				{Op: "remove", Path: "/b"},
+				{Op: "add", Path: "/c", Value: json.RawMessage(`null`)},
+				{Op: "add", Path: "/d", Value: json.RawMessage(`3`)},
+			},
+		},
+		{
+			name: "nested object",
+			from: `{"config":{"name":"a","description":"b"}}`,
+			to:   `{"config":{"name":"c","description":"b"}}`,
+			want: []types.JSONPatchOperation{
+				{Op: "replace", Path: "/config/name", Value: json.RawMessage(`"c"`)},
+			},
+		},
+		{
+			name: "keys with a slash and a tilde are escaped",
+			from: `{"a/b":1,"c~d":2,"~/":3}`,
+			to:   `{"a/b":4,"~/":3}`,
+			want: []types.JSONPatchOperation{
+				{Op: "remove", Path: "/c~0d"},
+				{Op: "replace", Path: "/a~1b", Value: json.RawMessage(`4`)},
+			},
+		},
+		{
+			name: "escaped keys in a nested path",
+			from: `{"labels":{"x/y":{"~":1}}}`,
+			to:   `{"labels":{"x/y":{"~":2}}}`,
+			want: []types.JSONPatchOperation{
+				{Op: "replace", Path: "/labels/x~1y/~0", Value: json.RawMessage(`2`)},
+			},
+		},
+		{
+			name: "arrays of the same length are compared item by item",
+			from: `{"panels":[{"caption":"a"},{"caption":"b"}]}`,
+			to:   `{"panels":[{"caption":"a"},{"caption":"c"}]}`,
+			want: []types.JSONPatchOperation{
+				{Op: "replace", Path: "/panels/1/caption", Value: json.RawMessage(`"c"`)},
+			},
+		},
+		{
+			name: "longer array is replaced whole",
+			from: `{"tags":["x"]}`,
+			to:   `{"tags":["x","y"]}`,
+			want: []types.JSONPatchOperation{
+				{Op: "replace", Path: "/tags", Value: json.RawMessage(`["x","y"]`)},
+			},
+		},
+		{
+			name: "shorter array is replaced whole",
+			from: `{"tags":["x","y","z"]}`,
+			to:   `{"tags":[]}`,
+			want: []types.JSONPatchOperation{
+				{Op: "replace", Path: "/tags", Value: json.RawMessage(`[]`)},
+			},
+		},
+		{
+			name: "changed type is replaced",
+			from: `{"script":{"title":"a"}}`,
+			to:   `{"script":null}`,
+			want: []types.JSONPatchOperation{
+				{Op: "replace", Path: "/script", Value: json.RawMessage(`null`)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := diffJSON([]byte(tt.from), []byte(tt.to))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := json.Marshal(patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := json.Marshal(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+
+			// applying the diff to from must give to
+			decoded, err := jsonpatch.DecodePatch(got)
+			if err != nil {
+				t.Fatalf("failed to decode the diff: %v", err)
+			}
+			applied, err := decoded.Apply([]byte(tt.from))
+			if err != nil {
+				t.Fatalf("failed to apply the diff: %v", err)
+			}
+			if !jsonpatch.Equal(applied, []byte(tt.to)) {
+				t.Errorf("expected the diff to give %s, got %s", tt.to, applied)
+			}
+		})
+	}
+}
+
+func TestDiffJSONInvalidDocument(t *testing.T) {
+	if _, err := diffJSON([]byte(`{"name":`), []byte(`{}`)); err == nil {
+		t.Error("expected an error for an invalid from document")
+	}
+	if _, err := diffJSON([]byte(`{}`), []byte(`nope`)); err == nil {
+		t.Error("expected an error for an invalid to document")
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"errors"
+	"maps"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// trashListConfig is the list config of the trash - the resource's list config
+// that can also sort by deleted_at and shows the most recently deleted first
+func (rr *ResourceRouter[T, TCreate, TUpdate]) trashListConfig() *ResourceListConfig {
+	listConfig := *rr.config.ListConfig
+	listConfig.SortFields = maps.Clone(listConfig.SortFields)
+	if listConfig.SortFields == nil {
+		listConfig.SortFields = map[string]string{}
+	}
+	listConfig.SortFields[store.DELETED_AT_COLUMN] = store.DELETED_AT_COLUMN
+	listConfig.DefaultSort = "-" + store.DELETED_AT_COLUMN
+	return &listConfig
+}
+
+// Trash returns a page of the entities in the trash
+// It takes the same query string as List
+func (rr *ResourceRouter[T, TCreate, TUpdate]) Trash(c fiber.Ctx) error {
+	return rr.list(c, rr.trashListConfig(), rr.repo.ListTrash)
+}
+
+// Restore takes an entity out of the trash and returns it
+func (rr *ResourceRouter[T, TCreate, TUpdate]) Restore(c fiber.Ctx) error {
+	id := c.Params(rr.config.IDParam)
+	if id == "" {
+		return apierror.BadRequest(types.ErrorCodeMissingID, "ID parameter is required")
+	}
+
+	scopeFilters, err := rr.scopeFilters(c)
+	if err != nil {
+		return scopeError(err)
+	}
+
+	if err := rr.repo.Restore(id, scopeFilters...); err != nil {
+		if errors.Is(err, store.ErrNotInTrash) {
+			return apierror.NotFound("Entity not found in the trash")
+		}
+		log.Error().Err(err).Str("id", id).Msg("Failed to restore entity")
+		return apierror.Internal(types.ErrorCodeRestoreFailed, "Failed to restore entity")
+	}
+
+	var entity T
+	if err := rr.repo.FindByID(id, &entity, scopeFilters...); err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to load restored entity")
+		return apierror.Internal(types.ErrorCodeRestoreFailed, "Failed to restore entity")
+	}
+
+	rr.auditEntity(c, types.AuditActionRestore, id, nil, &entity)
+	rr.publishChange(c, types.AuditActionRestore, id, &entity)
+
+	// Call AfterRestore hook if provided
+	if rr.config.Hooks != nil && rr.config.Hooks.AfterRestore != nil {
+		if err := rr.config.Hooks.AfterRestore(c, &entity); err != nil {
+			log.Warn().Err(err).Msg("AfterRestore hook failed (entity already restored)")
+			// Don't return error since entity is already restored
+		}
+	}
+
+	rr.setETag(c, &entity)
+	return c.Status(fiber.StatusOK).JSON(entity)
+}
+
+// documentTrashRoutes registers the trash and restore operations in the OpenAPI document
+func (rr *ResourceRouter[T, TCreate, TUpdate]) documentTrashRoutes(path string, itemPath string) {
+	name, singular := resourceName(path)
+	var entity T
+
+	rr.documentOperations(name, []resourceOperationDoc{
+		{ResourceOperationList, rr.config.AuthConfig.RequireAuthForList, openapi.Operation{
+			Method:   fiber.MethodGet,
+			Path:     path + "/trash",
+			Summary:  "List the " + name + " in the trash",
+			Query:    listQueryDocs(rr.trashListConfig()),
+			Response: ListResponse[T]{},
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "INVALID_QUERY"},
+				{Status: fiber.StatusInternalServerError, Code: "LIST_FAILED"},
+			},
+		}},
+		{ResourceOperationDelete, rr.config.AuthConfig.RequireAuthForDelete, openapi.Operation{
+			Method:   fiber.MethodPost,
+			Path:     itemPath + "/restore",
+			Summary:  "Restore a " + singular + " from the trash",
+			Response: entity,
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "MISSING_ID"},
+				{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+				{Status: fiber.StatusInternalServerError, Code: "RESTORE_FAILED"},
+			},
+		}},
+	})
+}
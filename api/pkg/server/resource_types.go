@@ -1,6 +1,9 @@
 package server
 
-import "github.com/gofiber/fiber/v3"
+import (
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/gofiber/fiber/v3"
+)
 
 // ResourceHooks defines optional lifecycle hooks for resource operations
 // All hooks are optional (nil-safe) - they will only be called if provided
@@ -26,17 +29,35 @@ type ResourceHooks[T any, TCreate any, TUpdate any] struct {
 	BeforeDelete func(c fiber.Ctx, id string, entity *T) error
 
 	// AfterDelete is called after successfully deleting from database
-	AfterDelete func(c fiber.Ctx, id string) error
+	// entity is the entity as it was before it was deleted
+	AfterDelete func(c fiber.Ctx, id string, entity *T) error
+
+	// AfterRestore is called after an entity has been taken out of the trash
+	AfterRestore func(c fiber.Ctx, entity *T) error
 }
 
+// ResourceOperation names a CRUD operation of a resource router
+type ResourceOperation string
+
+const (
+	ResourceOperationList   ResourceOperation = "list"
+	ResourceOperationGet    ResourceOperation = "get"
+	ResourceOperationCreate ResourceOperation = "create"
+	ResourceOperationUpdate ResourceOperation = "update"
+	ResourceOperationDelete ResourceOperation = "delete"
+)
+
 // ResourceAuthConfig defines which operations require authentication
 // By default, all operations require authentication
+// Permissions maps operations to the permission the user's roles must grant,
+// an operation with a permission always requires authentication
 type ResourceAuthConfig struct {
 	RequireAuthForList   bool
 	RequireAuthForGet    bool
 	RequireAuthForCreate bool
 	RequireAuthForUpdate bool
 	RequireAuthForDelete bool
+	Permissions          map[ResourceOperation]string
 }
 
 // DefaultAuthConfig returns a config where all operations require authentication
@@ -50,6 +71,20 @@ func DefaultAuthConfig() ResourceAuthConfig {
 	}
 }
 
+// PermissionAuthConfig returns a config where all operations require authentication
+// and reading, writing and deleting each need their own permission
+func PermissionAuthConfig(read string, write string, delete string) ResourceAuthConfig {
+	authConfig := DefaultAuthConfig()
+	authConfig.Permissions = map[ResourceOperation]string{
+		ResourceOperationList:   read,
+		ResourceOperationGet:    read,
+		ResourceOperationCreate: write,
+		ResourceOperationUpdate: write,
+		ResourceOperationDelete: delete,
+	}
+	return authConfig
+}
+
 // NoAuthConfig returns a config where no operations require authentication
 func NoAuthConfig() ResourceAuthConfig {
 	return ResourceAuthConfig{
@@ -61,11 +96,99 @@ func NoAuthConfig() ResourceAuthConfig {
 	}
 }
 
+// ResourceOwnershipConfig scopes a resource to the user that created it
+// List, Get, Update and Delete only see rows whose Column matches the authenticated user ID
+// and Create sets Column to the authenticated user ID
+type ResourceOwnershipConfig struct {
+	// Column is the database column holding the owner's user ID (e.g. "user_id")
+	Column string
+	// AdminRole is a JWT role that bypasses the ownership scope - empty means no bypass
+	AdminRole string
+}
+
+// DefaultOwnershipConfig scopes by the user_id column with an admin bypass
+func DefaultOwnershipConfig() *ResourceOwnershipConfig {
+	return &ResourceOwnershipConfig{
+		Column:    "user_id",
+		AdminRole: config.ADMIN_ROLE,
+	}
+}
+
+// ResourceParentConfig nests a resource under a parent resource
+// e.g. pages under /comics/:id/pages where every page has a comic_id column
+// List, Get, Update and Delete only see rows whose Column matches the parent ID in the URL
+// and Create sets Column to the parent ID
+type ResourceParentConfig struct {
+	// Param is the route parameter holding the parent ID (e.g. "id")
+	Param string
+	// Column is the database column holding the parent ID (e.g. "comic_id")
+	Column string
+	// Authorize checks the parent exists and the user can access it
+	// any error responds with 404 so other users' resources are not revealed
+	Authorize func(c fiber.Ctx, parentID string) error
+	// Owner returns the user that owns the parent in the URL
+	// change events of the nested resource are streamed to them - nil means they are not streamed
+	Owner func(c fiber.Ctx) (string, error)
+}
+
+// ResourceListConfig defines how the List operation can be paginated, sorted and filtered
+// Only the fields listed here can be used from the query string
+type ResourceListConfig struct {
+	// DefaultLimit is the page size used when no limit is given
+	DefaultLimit int
+	// MaxLimit caps the page size a client can ask for
+	MaxLimit int
+	// DefaultSort is used when no sort is given (e.g. "-created_at")
+	DefaultSort string
+	// SortFields maps names accepted by ?sort= to database columns
+	SortFields map[string]string
+	// FilterFields maps query parameter names to database columns
+	FilterFields map[string]string
+	// JSONFields are JSONB columns that can be filtered by path (e.g. ?config.name=foo)
+	JSONFields []string
+	// RangeParams are integer query parameters that bound a column (e.g. ?from=1700000000)
+	RangeParams map[string]ResourceRangeParam
+}
+
+// ResourceRangeParam compares a column with the integer value of a query parameter
+type ResourceRangeParam struct {
+	// Column is the database column to compare (e.g. "created_at")
+	Column string
+	// Op is the comparison, one of <, <=, > or >=
+	Op string
+	// Description documents the parameter in the OpenAPI document
+	Description string
+}
+
+// DefaultListConfig returns a list config that pages by ID with no filters
+func DefaultListConfig() ResourceListConfig {
+	return ResourceListConfig{
+		DefaultLimit: 20,
+		MaxLimit:     100,
+		DefaultSort:  "id",
+		SortFields: map[string]string{
+			"id": "id",
+		},
+	}
+}
+
 // ResourceConfig bundles all configuration for a resource router
 type ResourceConfig[T any, TCreate any, TUpdate any] struct {
 	Hooks      *ResourceHooks[T, TCreate, TUpdate]
 	AuthConfig ResourceAuthConfig
 	Mapper     ResourceMapper[T, TCreate, TUpdate]
+	// ListConfig controls pagination, sorting and filtering - DefaultListConfig is used if nil
+	ListConfig *ResourceListConfig
+	// Ownership scopes operations to the authenticated user - nil means no scoping
+	Ownership *ResourceOwnershipConfig
+	// Parent scopes operations to a parent resource from the URL - nil means top level
+	Parent *ResourceParentConfig
+	// IDParam is the route parameter holding the entity ID - defaults to "id"
+	// nested resources need their own name (e.g. "pageId") as "id" is taken by the parent
+	IDParam string
+	// Revisions stores the previous JSON of the entity on every update
+	// and adds the revision routes (see resource_revision.go)
+	Revisions bool
 }
 
 // DefaultResourceConfig returns a config with authentication enabled and default mapper
@@ -74,5 +197,6 @@ func DefaultResourceConfig[T any]() ResourceConfig[T, T, T] {
 		Hooks:      nil,
 		AuthConfig: DefaultAuthConfig(),
 		Mapper:     NewDefaultMapper[T](),
+		ListConfig: nil,
 	}
 }
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/rs/zerolog/log"
+)
+
+// RevokedTokenCache answers whether an access token has been revoked by a logout without a
+// query per request
+// The revoked jtis are loaded from the database every AUTH_REVOKED_TOKENS_CACHE_TTL,
+// logouts on this replica apply at once and logouts on others within the TTL
+type RevokedTokenCache struct {
+	repo *store.RevokedAccessTokenRepository
+	ttl  time.Duration
+
+	mu       sync.Mutex
+	jtis     map[string]struct{}
+	loadedAt time.Time
+}
+
+func NewRevokedTokenCache(repo *store.RevokedAccessTokenRepository, ttl time.Duration) *RevokedTokenCache {
+	return &RevokedTokenCache{
+		repo: repo,
+		ttl:  ttl,
+	}
+}
+
+// IsRevoked reports whether the access token with the jti has been revoked
+func (r *RevokedTokenCache) IsRevoked(jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.jtis == nil || time.Since(r.loadedAt) >= r.ttl {
+		if err := r.reload(); err != nil {
+			// keep checking against the last set we loaded rather than locking everyone out
+			if r.jtis == nil {
+				return false, err
+			}
+			log.Warn().Err(err).Msg("Failed to reload revoked access tokens, using cached set")
+		}
+	}
+
+	_, revoked := r.jtis[jti]
+	return revoked, nil
+}
+
+// Add marks a token revoked on this replica straight away
+// it must already be saved with the store so other replicas load it
+func (r *RevokedTokenCache) Add(jti string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.jtis != nil {
+		r.jtis[jti] = struct{}{}
+	}
+}
+
+// reload replaces the set with the revoked tokens that have not expired
+func (r *RevokedTokenCache) reload() error {
+	loaded, err := r.repo.LoadActive()
+	if err != nil {
+		return err
+	}
+	jtis := make(map[string]struct{}, len(loaded))
+	for _, jti := range loaded {
+		jtis[jti] = struct{}{}
+	}
+	r.jtis = jtis
+	r.loadedAt = time.Now()
+	return nil
+}
@@ -5,22 +5,47 @@ import (
 	"fmt"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/adaptor"
 	"github.com/gofiber/fiber/v3/middleware/logger"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+	"github.com/rs/zerolog/log"
 
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
 	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/events"
+	"github.com/binocarlos/kai-stack/api/pkg/health"
 	"github.com/binocarlos/kai-stack/api/pkg/jobqueue"
+	"github.com/binocarlos/kai-stack/api/pkg/metrics"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
 	"github.com/binocarlos/kai-stack/api/pkg/store"
 	"github.com/binocarlos/kai-stack/api/pkg/system"
 
 	_ "net/http/pprof" // enable profiling
 )
 
+// internalPaths are served at the root of the app (not under the api path)
+// so orchestrators and scrapers can reach them without knowing SERVER_API_PATH
+// they are not logged or included in the request metrics
+var internalPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
 type StackAPIServer struct {
 	app      *fiber.App
 	router   fiber.Router
 	cfg      *config.Config
 	store    *store.PostgresStore
 	jobqueue *jobqueue.Client
+	health   *health.Checker
+	events   *events.Broker
+
+	permissions   *PermissionResolver
+	revokedTokens *RevokedTokenCache
+
+	openapi         *openapi.Registry
+	openapiDocument func() *openapi.Document
 }
 
 func NewServer(
@@ -36,14 +61,24 @@ func NewServer(
 		return nil, fmt.Errorf("server port is required")
 	}
 
+	permissions, err := NewPermissionResolver(cfg.Auth, store.RolePermissions())
+	if err != nil {
+		return nil, err
+	}
+
 	app := fiber.New(fiber.Config{
 		BodyLimit: 100 * 1024 * 1024 * 1024, // 100GB
+		// every error is rendered as application/problem+json
+		ErrorHandler: apierror.Handler,
 	})
 
+	// the request ID is returned in the X-Request-ID header and in error bodies
+	app.Use(requestid.New())
+
 	app.Use(logger.New(logger.Config{
 		Next: func(c fiber.Ctx) bool {
-			// Check if the 'nolog' query parameter exists
-			if c.Query("nolog") != "" {
+			// Check if the 'nolog' query parameter exists or this is a health probe
+			if c.Query("nolog") != "" || internalPaths[c.Path()] {
 				// Skip logging if 'nolog' is present
 				return true
 			}
@@ -52,23 +87,76 @@ func NewServer(
 		},
 	}))
 
+	app.Use(metrics.FiberMiddleware(internalPaths))
+	app.Get("/metrics", adaptor.HTTPHandler(metrics.Handler()))
+
 	server := &StackAPIServer{
 		app:      app,
 		router:   app.Group(cfg.WebServer.APIPath),
 		cfg:      cfg,
 		store:    store,
 		jobqueue: workerClient,
+		events:   newChangeEventBroker(cfg, store),
+
+		permissions:   permissions,
+		revokedTokens: NewRevokedTokenCache(store.RevokedAccessTokens(), cfg.Auth.RevokedTokensTTL),
+
+		openapi: newOpenAPIRegistry(cfg),
 	}
 
+	// every mutating API call is recorded in the audit log
+	server.router.Use(server.auditRequests)
+
+	server.RegisterHealthRoutes()
 	server.RegisterUserRoutes()
+	server.RegisterAPIKeyRoutes()
 	server.RegisterComicRoutes()
+	server.RegisterWebhookRoutes()
+	server.RegisterWorkerRoutes()
+	server.RegisterJobRoutes()
+	server.RegisterAuditRoutes()
+	server.RegisterEventRoutes()
+	server.RegisterOpenAPIRoutes()
 
 	return server, nil
 }
 
-func (apiServer *StackAPIServer) ListenAndServe(ctx context.Context, _ *system.CleanupManager) error {
+// ListenAndServe serves the api until ctx is cancelled and then drains in-flight
+// requests for up to SERVER_SHUTDOWN_TIMEOUT before returning. The database pools
+// handed to the server are registered with the cleanup manager so they are closed
+// once serving has stopped.
+func (apiServer *StackAPIServer) ListenAndServe(ctx context.Context, cm *system.CleanupManager) error {
+	cm.RegisterCallback(apiServer.jobqueue.Close)
+	cm.RegisterCallback(apiServer.store.Close)
+
+	// the /events streams end once ctx is cancelled so they don't hold up draining
+	go apiServer.events.Run(ctx)
+
 	addr := fmt.Sprintf("%s:%d", apiServer.cfg.WebServer.Host, apiServer.cfg.WebServer.Port)
-	return apiServer.app.Listen(addr)
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- apiServer.app.Listen(addr, fiber.ListenConfig{
+			DisableStartupMessage: true,
+		})
+	}()
+
+	select {
+	case err := <-listenErr:
+		return fmt.Errorf("api server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Info().
+		Dur("timeout", apiServer.cfg.WebServer.ShutdownTimeout).
+		Msg("shutting down api server, draining in-flight requests")
+
+	if err := apiServer.app.ShutdownWithTimeout(apiServer.cfg.WebServer.ShutdownTimeout); err != nil {
+		return fmt.Errorf("failed to shut down api server: %w", err)
+	}
+
+	log.Info().Msg("api server stopped")
+	return nil
 }
 
 func getRequestData[TBodyData any](c fiber.Ctx) (*TBodyData, error) {
@@ -84,4 +172,11 @@ func getRequestData[TBodyData any](c fiber.Ctx) (*TBodyData, error) {
 func (apiServer *StackAPIServer) RegisterComicRoutes() {
 	comicRouter := NewComicRouter(apiServer, apiServer.store.Comics())
 	comicRouter.RegisterRoutes(apiServer.router)
+
+	// Pages and panels are nested under their comic
+	pageRouter := NewPageRouter(apiServer, apiServer.store.Pages(), comicRouter)
+	pageRouter.RegisterRoutes(apiServer.router)
+
+	panelRouter := NewPanelRouter(apiServer, apiServer.store.Panels(), apiServer.store.Pages(), comicRouter)
+	panelRouter.RegisterRoutes(apiServer.router)
 }
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/google/uuid"
+)
+
+// newRefreshToken returns a random refresh token and the hash that is stored for it
+func newRefreshToken() (string, string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(data)
+	return token, hashSecret(token), nil
+}
+
+// hashSecret is the SHA-256 of a refresh token or API key
+// both are long random values so no salt or slow hash is needed
+func hashSecret(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueTokens creates an access token and the refresh token that replaces it for a session
+// The refresh token row is returned unsaved so the caller can store it (or rotate to it)
+func (apiServer *StackAPIServer) issueTokens(userID string, roles []string, familyID string) (*types.LoginResponse, *types.RefreshToken, error) {
+	accessToken, expiresAt, err := apiServer.generateJWT(userID, roles, familyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, refreshHash, err := newRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	row := &types.RefreshToken{
+		ID:        uuid.New().String(),
+		FamilyID:  familyID,
+		UserID:    userID,
+		Roles:     roles,
+		TokenHash: refreshHash,
+		ExpiresAt: time.Now().Add(apiServer.cfg.WebServer.RefreshTokenTTL).Unix(),
+	}
+
+	return &types.LoginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt.Unix(),
+	}, row, nil
+}
+
+// startSession issues the first pair of tokens for a new login
+func (apiServer *StackAPIServer) startSession(userID string, roles []string) (*types.LoginResponse, error) {
+	response, row, err := apiServer.issueTokens(userID, roles, uuid.New().String())
+	if err != nil {
+		return nil, err
+	}
+	if err := apiServer.store.RefreshTokens().Create(row); err != nil {
+		return nil, fmt.Errorf("failed to save refresh token: %w", err)
+	}
+	return response, nil
+}
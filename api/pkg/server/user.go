@@ -1,54 +1,291 @@
 package server
 
 import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
 	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
 	"github.com/binocarlos/kai-stack/api/pkg/types"
 	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
 func (apiServer *StackAPIServer) RegisterUserRoutes() {
+	// Register endpoint - no authentication required (creates an account and generates JWT token)
+	apiServer.router.Post("/user/register", apiServer.Register)
+
 	// Login endpoint - no authentication required (generates JWT token)
 	apiServer.router.Post("/user/login", apiServer.Login)
 
+	// Refresh endpoint - authenticated by the refresh token in the body (rotates the token pair)
+	apiServer.router.Post("/user/refresh", apiServer.Refresh)
+
 	// User status endpoint - requires authentication (returns session summary)
 	apiServer.router.Get("/user/status", apiServer.RequireAuth, apiServer.GetUserStatus)
 
 	// Logout endpoint - requires authentication (terminates session)
 	apiServer.router.Post("/user/logout", apiServer.RequireAuth, apiServer.Logout)
+
+	apiServer.document(openapi.Operation{
+		Method:   fiber.MethodPost,
+		Path:     "/user/register",
+		Summary:  "Create an account and start a session",
+		Tags:     []string{"user"},
+		Request:  types.RegisterRequest{},
+		Response: types.LoginResponse{},
+		Status:   fiber.StatusCreated,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusBadRequest, Code: "INVALID_REQUEST"},
+			{Status: fiber.StatusBadRequest, Code: "MISSING_CREDENTIALS"},
+			{Status: fiber.StatusBadRequest, Code: "PASSWORD_TOO_SHORT"},
+			{Status: fiber.StatusForbidden, Code: "REGISTRATION_DISABLED"},
+			{Status: fiber.StatusConflict, Code: "EMAIL_TAKEN"},
+			{Status: fiber.StatusInternalServerError, Code: "REGISTER_FAILED"},
+			{Status: fiber.StatusInternalServerError, Code: "TOKEN_FAILED"},
+		},
+	})
+	apiServer.document(openapi.Operation{
+		Method:   fiber.MethodPost,
+		Path:     "/user/login",
+		Summary:  "Log in and start a session",
+		Tags:     []string{"user"},
+		Request:  types.LoginRequest{},
+		Response: types.LoginResponse{},
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusBadRequest, Code: "INVALID_REQUEST"},
+			{Status: fiber.StatusBadRequest, Code: "MISSING_CREDENTIALS"},
+			{Status: fiber.StatusForbidden, Code: "INVALID_CREDENTIALS"},
+			{Status: fiber.StatusInternalServerError, Code: "LOGIN_FAILED"},
+			{Status: fiber.StatusInternalServerError, Code: "TOKEN_FAILED"},
+		},
+	})
+	apiServer.document(openapi.Operation{
+		Method:   fiber.MethodPost,
+		Path:     "/user/refresh",
+		Summary:  "Exchange a refresh token for a new token pair",
+		Tags:     []string{"user"},
+		Request:  types.RefreshRequest{},
+		Response: types.LoginResponse{},
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusBadRequest, Code: "INVALID_REQUEST"},
+			{Status: fiber.StatusUnauthorized, Code: "INVALID_REFRESH_TOKEN"},
+			{Status: fiber.StatusInternalServerError, Code: "REFRESH_FAILED"},
+		},
+	})
+	apiServer.document(openapi.Operation{
+		Method:   fiber.MethodGet,
+		Path:     "/user/status",
+		Summary:  "Get the authenticated user",
+		Tags:     []string{"user"},
+		Response: types.UserStatusResponse{},
+		Security: userSecurity,
+	})
+	apiServer.document(openapi.Operation{
+		Method:   fiber.MethodPost,
+		Path:     "/user/logout",
+		Summary:  "End the current session",
+		Tags:     []string{"user"},
+		Response: MessageResponse{},
+		Security: userSecurity,
+		Errors: []openapi.ErrorResponse{
+			{Status: fiber.StatusInternalServerError, Code: "LOGOUT_FAILED"},
+		},
+	})
+}
+
+// Register creates a new user account and returns a JWT token
+func (apiServer *StackAPIServer) Register(c fiber.Ctx) error {
+	if !apiServer.cfg.WebServer.Registration {
+		return apierror.New(fiber.StatusForbidden, types.ErrorCodeRegistrationDisabled, "Registration is disabled")
+	}
+
+	req, err := getRequestData[types.RegisterRequest](c)
+	if err != nil {
+		return apierror.BadRequest(types.ErrorCodeInvalidRequest, "Invalid request")
+	}
+
+	email := store.NormalizeEmail(req.Email)
+	if email == "" || req.Password == "" {
+		return apierror.BadRequest(types.ErrorCodeMissingCredentials, "Email and password are required")
+	}
+
+	if len(req.Password) < config.MIN_PASSWORD_LENGTH {
+		return apierror.BadRequest(types.ErrorCodePasswordTooShort, fmt.Sprintf("Password must be at least %d characters", config.MIN_PASSWORD_LENGTH))
+	}
+
+	_, err = apiServer.store.Users().FindByEmail(email)
+	if err == nil {
+		return apierror.Conflict(types.ErrorCodeEmailTaken, "An account with this email already exists")
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Error().Err(err).Msg("Failed to look up user by email")
+		return apierror.Internal(types.ErrorCodeRegisterFailed, "Failed to create account")
+	}
+
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to hash password")
+		return apierror.Internal(types.ErrorCodeRegisterFailed, "Failed to create account")
+	}
+
+	user := &types.UserAccount{
+		ID:           uuid.New().String(),
+		Email:        email,
+		PasswordHash: passwordHash,
+		Roles:        []string{config.USER_ROLE},
+	}
+
+	if err := apiServer.store.Users().Create(user); err != nil {
+		if errors.Is(err, store.ErrEmailTaken) {
+			return apierror.Conflict(types.ErrorCodeEmailTaken, "An account with this email already exists")
+		}
+		log.Error().Err(err).Msg("Failed to create user")
+		return apierror.Internal(types.ErrorCodeRegisterFailed, "Failed to create account")
+	}
+
+	response, err := apiServer.startSession(user.ID, user.Roles)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to start session")
+		return apierror.Internal(types.ErrorCodeTokenFailed, "Failed to generate authentication token")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response)
 }
 
-// Login authenticates a user with Carbon and returns a JWT token
+// Login authenticates a user by email and password and returns a JWT token
+// While SERVER_BOOTSTRAP_ADMIN is enabled, any email without an account can log in
+// as the admin root user with SERVER_FIXED_PASSWORD (used to bootstrap a new install)
 func (apiServer *StackAPIServer) Login(c fiber.Ctx) error {
+	// failed logins are audited without an actor
+	setAuditChange(c, auditChange{Action: types.AuditActionLogin})
+
 	req, err := getRequestData[types.LoginRequest](c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request",
-		})
+		return apierror.BadRequest(types.ErrorCodeInvalidRequest, "Invalid request")
 	}
 
 	// Validate required fields
 	if req.Email == "" || req.Password == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Email and password are required",
-		})
+		return apierror.BadRequest(types.ErrorCodeMissingCredentials, "Email and password are required")
 	}
 
-	if req.Password != apiServer.cfg.WebServer.FixedPassword {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Incorrect password",
-		})
+	var (
+		userID string
+		roles  []string
+	)
+
+	user, err := apiServer.store.Users().FindByEmail(req.Email)
+	switch {
+	case err == nil:
+		if !checkPassword(user.PasswordHash, req.Password) {
+			return apierror.New(fiber.StatusForbidden, types.ErrorCodeInvalidCredentials, "Incorrect email or password")
+		}
+		userID = user.ID
+		roles = user.Roles
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// pay the bcrypt cost anyway so the response time doesn't reveal which emails are registered
+		checkPassword(dummyPasswordHash(), req.Password)
+		if !apiServer.fixedPasswordEnabled() ||
+			subtle.ConstantTimeCompare([]byte(req.Password), []byte(apiServer.cfg.WebServer.FixedPassword)) != 1 {
+			return apierror.New(fiber.StatusForbidden, types.ErrorCodeInvalidCredentials, "Incorrect email or password")
+		}
+		userID = config.FIXED_USER_ID
+		roles = []string{config.ADMIN_ROLE}
+	default:
+		log.Error().Err(err).Msg("Failed to look up user by email")
+		return apierror.Internal(types.ErrorCodeLoginFailed, "Failed to log in")
 	}
 
-	token, err := apiServer.generateJWT(config.FIXED_USER_ID, []string{"admin"})
+	response, err := apiServer.startSession(userID, roles)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to generate authentication token",
-		})
+		log.Error().Err(err).Msg("Failed to start session")
+		return apierror.Internal(types.ErrorCodeTokenFailed, "Failed to generate authentication token")
 	}
+	setAuditChange(c, auditChange{Action: types.AuditActionLogin, ActorID: userID})
 
-	return c.Status(fiber.StatusOK).JSON(types.LoginResponse{
-		Token: token,
-	})
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// fixedPasswordEnabled reports whether the admin root user can log in with SERVER_FIXED_PASSWORD
+// it must be opted into with SERVER_BOOTSTRAP_ADMIN so upgraded installs don't keep a shared admin password
+func (apiServer *StackAPIServer) fixedPasswordEnabled() bool {
+	return apiServer.cfg.WebServer.BootstrapAdmin && apiServer.cfg.WebServer.FixedPassword != ""
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token
+// Each refresh token can only be used once - presenting a used token is treated as theft
+// and revokes every token in the session so both the attacker and the user must log in again
+func (apiServer *StackAPIServer) Refresh(c fiber.Ctx) error {
+	req, err := getRequestData[types.RefreshRequest](c)
+	if err != nil || req.RefreshToken == "" {
+		return apierror.BadRequest(types.ErrorCodeInvalidRequest, "refresh_token is required")
+	}
+
+	invalidToken := func() error {
+		return apierror.New(fiber.StatusUnauthorized, types.ErrorCodeInvalidRefreshToken, "Invalid or expired refresh token")
+	}
+
+	refreshTokens := apiServer.store.RefreshTokens()
+	existing, err := refreshTokens.FindByHash(hashSecret(req.RefreshToken))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return invalidToken()
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to look up refresh token")
+		return apierror.Internal(types.ErrorCodeRefreshFailed, "Failed to refresh token")
+	}
+
+	if existing.RevokedAt != 0 || existing.ExpiresAt < time.Now().Unix() {
+		return invalidToken()
+	}
+
+	// Roles are reloaded so changes apply on the next refresh
+	// the fixed admin user has no account and keeps the roles it logged in with
+	roles := existing.Roles
+	var account types.UserAccount
+	err = apiServer.store.Users().FindByID(existing.UserID, &account)
+	switch {
+	case err == nil:
+		roles = account.Roles
+	case errors.Is(err, gorm.ErrRecordNotFound) && existing.UserID == config.FIXED_USER_ID && apiServer.fixedPasswordEnabled():
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return invalidToken()
+	default:
+		log.Error().Err(err).Msg("Failed to load user for refresh")
+		return apierror.Internal(types.ErrorCodeRefreshFailed, "Failed to refresh token")
+	}
+
+	response, replacement, err := apiServer.issueTokens(existing.UserID, roles, existing.FamilyID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to issue tokens")
+		return apierror.Internal(types.ErrorCodeRefreshFailed, "Failed to refresh token")
+	}
+
+	err = refreshTokens.Rotate(existing, replacement)
+	if errors.Is(err, store.ErrRefreshTokenUsed) {
+		log.Warn().
+			Str("user_id", existing.UserID).
+			Str("family_id", existing.FamilyID).
+			Str("ip", c.IP()).
+			Msg("Refresh token reused - revoking session")
+		if err := refreshTokens.RevokeFamily(existing.FamilyID); err != nil {
+			log.Error().Err(err).Msg("Failed to revoke refresh token family")
+		}
+		return invalidToken()
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to rotate refresh token")
+		return apierror.Internal(types.ErrorCodeRefreshFailed, "Failed to refresh token")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
 }
 
 // GetUserStatus is an authenticated endpoint that returns the current user's session summary
@@ -56,9 +293,7 @@ func (apiServer *StackAPIServer) Login(c fiber.Ctx) error {
 func (apiServer *StackAPIServer) GetUserStatus(c fiber.Ctx) error {
 	userID, ok := GetUserIDFromContext(c)
 	if !ok || userID == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "user ID is required",
-		})
+		return apierror.Unauthorized("user ID is required")
 	}
 	return c.Status(fiber.StatusOK).JSON(&types.UserStatusResponse{
 		UserID: userID,
@@ -66,14 +301,32 @@ func (apiServer *StackAPIServer) GetUserStatus(c fiber.Ctx) error {
 }
 
 // Logout is an authenticated endpoint that terminates the current user's session
+// The session's refresh tokens are revoked and the access token is blacklisted until it expires
 func (apiServer *StackAPIServer) Logout(c fiber.Ctx) error {
+	setAuditChange(c, auditChange{Action: types.AuditActionLogout})
+
 	userID, ok := GetUserIDFromContext(c)
 	if !ok || userID == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "user ID is required",
-		})
+		return apierror.Unauthorized("user ID is required")
+	}
+
+	claims, ok := GetClaimsFromContext(c)
+	if ok {
+		if claims.SessionID != "" {
+			if err := apiServer.store.RefreshTokens().RevokeFamily(claims.SessionID); err != nil {
+				log.Error().Err(err).Str("user_id", userID).Msg("Failed to revoke refresh tokens")
+				return apierror.Internal(types.ErrorCodeLogoutFailed, "Failed to log out")
+			}
+		}
+		if claims.ID != "" && claims.ExpiresAt != nil {
+			if err := apiServer.store.RevokedAccessTokens().Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+				log.Error().Err(err).Str("user_id", userID).Msg("Failed to revoke access token")
+				return apierror.Internal(types.ErrorCodeLogoutFailed, "Failed to log out")
+			}
+			apiServer.revokedTokens.Add(claims.ID)
+		}
 	}
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Logged out successfully",
+	return c.Status(fiber.StatusOK).JSON(MessageResponse{
+		Message: "Logged out successfully",
 	})
 }
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/rs/zerolog/log"
+)
+
+// requestValidator checks request bodies against their validate struct tags
+// Besides the built in rules it knows:
+//
+//	pattern=<regexp> - the string must match the regular expression
+//	balloon_type     - the value must be one of types.AllBalloonTypes
+//	webhook_event    - the value must be one of types.AllWebhookEvents
+var requestValidator = newRequestValidator()
+
+// patterns caches the compiled regular expressions used by the pattern rule
+var patterns sync.Map
+
+func newRequestValidator() *validator.Validate {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	// report fields by their JSON name so errors match the request body
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	mustRegister := func(tag string, fn validator.Func) {
+		if err := validate.RegisterValidation(tag, fn); err != nil {
+			panic(fmt.Sprintf("failed to register %s validation: %v", tag, err))
+		}
+	}
+
+	mustRegister("pattern", func(fl validator.FieldLevel) bool {
+		pattern, err := compilePattern(fl.Param())
+		if err != nil {
+			log.Error().Err(err).Str("pattern", fl.Param()).Msg("Invalid validation pattern")
+			return false
+		}
+		return pattern.MatchString(fl.Field().String())
+	})
+
+	mustRegister("balloon_type", func(fl validator.FieldLevel) bool {
+		for _, balloonType := range types.AllBalloonTypes {
+			if string(balloonType.Value) == fl.Field().String() {
+				return true
+			}
+		}
+		return false
+	})
+
+	mustRegister("webhook_event", func(fl validator.FieldLevel) bool {
+		for _, event := range types.AllWebhookEvents {
+			if string(event.Value) == fl.Field().String() {
+				return true
+			}
+		}
+		return false
+	})
+
+	return validate
+}
+
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	if cached, ok := patterns.Load(expr); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(expr, pattern)
+	return pattern, nil
+}
+
+// validateRequest checks a request body against its validate tags
+// and returns one FieldError per failed rule
+func validateRequest(req any) ([]types.FieldError, error) {
+	err := requestValidator.Struct(req)
+	if err == nil {
+		return nil, nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		// the request type itself can't be validated (e.g. a bad tag)
+		return nil, err
+	}
+
+	fields := make([]types.FieldError, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		// the namespace starts with the request type name
+		_, field, _ := strings.Cut(fieldErr.Namespace(), ".")
+		fields = append(fields, types.FieldError{
+			Field:   field,
+			Rule:    fieldErr.Tag(),
+			Param:   fieldErr.Param(),
+			Message: fieldErrorMessage(field, fieldErr),
+		})
+	}
+	return fields, nil
+}
+
+// fieldErrorMessage describes a failed rule for the client
+func fieldErrorMessage(field string, fieldErr validator.FieldError) string {
+	// min and max limit the length of strings and slices and the value of numbers
+	var unit string
+	switch fieldErr.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+	switch fieldErr.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "min":
+		if unit != "" {
+			return fmt.Sprintf("%s must have at least %s%s", field, fieldErr.Param(), unit)
+		}
+		return fmt.Sprintf("%s must be at least %s", field, fieldErr.Param())
+	case "max":
+		if unit != "" {
+			return fmt.Sprintf("%s must have at most %s%s", field, fieldErr.Param(), unit)
+		}
+		return fmt.Sprintf("%s must be at most %s", field, fieldErr.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, fieldErr.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", field, strings.ReplaceAll(fieldErr.Param(), " ", ", "))
+	case "url":
+		return fmt.Sprintf("%s must be a URL", field)
+	case "pattern":
+		return fmt.Sprintf("%s must match %s", field, fieldErr.Param())
+	case "balloon_type":
+		return fmt.Sprintf("%s is not a balloon type", field)
+	case "webhook_event":
+		return fmt.Sprintf("%s is not a webhook event", field)
+	default:
+		return fmt.Sprintf("%s failed the %s rule", field, fieldErr.Tag())
+	}
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/config"
+	"github.com/binocarlos/kai-stack/api/pkg/jobqueue"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
+	"github.com/binocarlos/kai-stack/api/pkg/store"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// deliveryParam is the route parameter holding a webhook delivery ID
+const deliveryParam = "delivery"
+
+// WebhookMapper handles mapping between webhook subscription request DTOs and the entity
+type WebhookMapper struct{}
+
+// CreateToEntity converts a WebhookSubscriptionCreateRequest to a WebhookSubscription entity
+func (m *WebhookMapper) CreateToEntity(req *types.WebhookSubscriptionCreateRequest) (*types.WebhookSubscription, error) {
+	return &types.WebhookSubscription{
+		// ID will be set in BeforeCreate hook
+		URL:    req.URL,
+		Secret: req.Secret,
+		Events: req.Events,
+	}, nil
+}
+
+// UpdateToEntity applies a WebhookSubscriptionUpdateRequest to an existing subscription
+// The secret is only replaced when the request has one
+func (m *WebhookMapper) UpdateToEntity(existing *types.WebhookSubscription, req *types.WebhookSubscriptionUpdateRequest) error {
+	existing.URL = req.URL
+	existing.Events = req.Events
+	if req.Secret != "" {
+		existing.Secret = req.Secret
+	}
+	return nil
+}
+
+// WebhookRouter provides CRUD operations for webhook subscriptions and their delivery logs
+type WebhookRouter struct {
+	*ResourceRouter[types.WebhookSubscription, types.WebhookSubscriptionCreateRequest, types.WebhookSubscriptionUpdateRequest]
+}
+
+// NewWebhookRouter creates the router for the user's webhook subscriptions
+func NewWebhookRouter(apiServer *StackAPIServer, repo *store.WebhookSubscriptionRepository) *WebhookRouter {
+	listConfig := DefaultListConfig()
+	listConfig.DefaultSort = "-created_at"
+	listConfig.SortFields = map[string]string{
+		"id":         "id",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	}
+	listConfig.FilterFields = map[string]string{
+		"user_id": "user_id",
+	}
+
+	hooks := &ResourceHooks[types.WebhookSubscription, types.WebhookSubscriptionCreateRequest, types.WebhookSubscriptionUpdateRequest]{
+		BeforeCreate: func(c fiber.Ctx, subscription *types.WebhookSubscription) error {
+			// The UserID has already been set by the ownership config
+			subscription.ID = uuid.New().String()
+			return jobqueue.ValidateWebhookURL(apiServer.cfg, subscription.URL)
+		},
+		BeforeUpdate: func(c fiber.Ctx, subscription *types.WebhookSubscription) error {
+			return jobqueue.ValidateWebhookURL(apiServer.cfg, subscription.URL)
+		},
+	}
+
+	config := &ResourceConfig[types.WebhookSubscription, types.WebhookSubscriptionCreateRequest, types.WebhookSubscriptionUpdateRequest]{
+		Hooks: hooks,
+		AuthConfig: PermissionAuthConfig(
+			config.PERMISSION_WEBHOOKS_MANAGE,
+			config.PERMISSION_WEBHOOKS_MANAGE,
+			config.PERMISSION_WEBHOOKS_MANAGE,
+		),
+		Mapper:     &WebhookMapper{},
+		ListConfig: &listConfig,
+		Ownership:  DefaultOwnershipConfig(), // Users only see their own subscriptions, admins see all
+	}
+
+	return &WebhookRouter{
+		ResourceRouter: NewResourceRouter(apiServer, repo.Repository, config),
+	}
+}
+
+// deliveryListConfig is how the deliveries of a subscription can be paged, sorted and filtered
+func deliveryListConfig() *ResourceListConfig {
+	return &ResourceListConfig{
+		DefaultLimit: 20,
+		MaxLimit:     100,
+		DefaultSort:  "-created_at",
+		SortFields: map[string]string{
+			"id":         "id",
+			"created_at": "created_at",
+		},
+		FilterFields: map[string]string{
+			"event":  "event",
+			"status": "status",
+		},
+	}
+}
+
+// RegisterWebhookRoutes registers the routes for users to manage their webhook subscriptions
+func (apiServer *StackAPIServer) RegisterWebhookRoutes() {
+	webhookRouter := NewWebhookRouter(apiServer, apiServer.store.WebhookSubscriptions())
+	webhookRouter.RegisterRoutes(apiServer.router)
+}
+
+// RegisterRoutes registers the subscription CRUD routes and the delivery log routes
+func (wr *WebhookRouter) RegisterRoutes(router fiber.Router) {
+	wr.ResourceRouter.RegisterRoutes(router, "/webhooks")
+
+	authConfig := wr.config.AuthConfig
+	deliveriesPath := fmt.Sprintf("/webhooks/:%s/deliveries", wr.config.IDParam)
+	deliveryPath := fmt.Sprintf("%s/:%s", deliveriesPath, deliveryParam)
+
+	router.Get(deliveriesPath, wr.guard(ResourceOperationGet, authConfig.RequireAuthForGet, wr.ListDeliveries))
+	router.Get(deliveryPath, wr.guard(ResourceOperationGet, authConfig.RequireAuthForGet, wr.GetDelivery))
+	router.Post(deliveryPath+"/redeliver", wr.guard(ResourceOperationUpdate, authConfig.RequireAuthForUpdate, wr.Redeliver))
+
+	wr.documentOperations("webhooks", []resourceOperationDoc{
+		{ResourceOperationGet, authConfig.RequireAuthForGet, openapi.Operation{
+			Method:   fiber.MethodGet,
+			Path:     deliveriesPath,
+			Summary:  "List the deliveries of a webhook, newest first",
+			Query:    listQueryDocs(deliveryListConfig()),
+			Response: ListResponse[types.WebhookDelivery]{},
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "MISSING_ID"},
+				{Status: fiber.StatusBadRequest, Code: "INVALID_QUERY"},
+				{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+				{Status: fiber.StatusInternalServerError, Code: "LOAD_DELIVERIES_FAILED"},
+			},
+		}},
+		{ResourceOperationGet, authConfig.RequireAuthForGet, openapi.Operation{
+			Method:   fiber.MethodGet,
+			Path:     deliveryPath,
+			Summary:  "Get a delivery of a webhook",
+			Response: types.WebhookDelivery{},
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "MISSING_ID"},
+				{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+				{Status: fiber.StatusInternalServerError, Code: "LOAD_DELIVERIES_FAILED"},
+			},
+		}},
+		{ResourceOperationUpdate, authConfig.RequireAuthForUpdate, openapi.Operation{
+			Method:   fiber.MethodPost,
+			Path:     deliveryPath + "/redeliver",
+			Summary:  "Send the payload of a delivery again as a new delivery",
+			Response: types.WebhookDelivery{},
+			Status:   fiber.StatusAccepted,
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "MISSING_ID"},
+				{Status: fiber.StatusNotFound, Code: "NOT_FOUND"},
+				{Status: fiber.StatusInternalServerError, Code: "LOAD_DELIVERIES_FAILED"},
+				{Status: fiber.StatusInternalServerError, Code: "REDELIVER_FAILED"},
+			},
+		}},
+	})
+}
+
+// findDelivery loads a delivery of the subscription in the URL
+func (wr *WebhookRouter) findDelivery(c fiber.Ctx) (*types.WebhookDelivery, error) {
+	id, _, err := wr.findEntity(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var delivery types.WebhookDelivery
+	err = wr.apiServer.store.WebhookDeliveries().FindByID(c.Params(deliveryParam), &delivery,
+		store.Filter{Expression: "subscription_id", Value: id})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apierror.NotFound("Delivery not found")
+	}
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to load webhook delivery")
+		return nil, apierror.Internal(types.ErrorCodeLoadDeliveriesFailed, "Failed to load delivery")
+	}
+	return &delivery, nil
+}
+
+// ListDeliveries returns a page of the deliveries of a subscription, newest first
+func (wr *WebhookRouter) ListDeliveries(c fiber.Ctx) error {
+	id, _, err := wr.findEntity(c)
+	if err != nil {
+		return err
+	}
+
+	query, err := parseListQuery(c, deliveryListConfig())
+	if err != nil {
+		return apierror.BadRequest(types.ErrorCodeInvalidQuery, err.Error())
+	}
+	query.Filters = append(query.Filters, store.Filter{Expression: "subscription_id", Value: id})
+
+	result, err := wr.apiServer.store.WebhookDeliveries().List(query)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to list webhook deliveries")
+		return apierror.Internal(types.ErrorCodeLoadDeliveriesFailed, "Failed to load deliveries")
+	}
+
+	return respondList(c, query, result)
+}
+
+// GetDelivery returns a single delivery of a subscription with the log of its latest attempt
+func (wr *WebhookRouter) GetDelivery(c fiber.Ctx) error {
+	delivery, err := wr.findDelivery(c)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(delivery)
+}
+
+// Redeliver sends the payload of a delivery again
+// It is sent as a new delivery so the log of the original is kept
+func (wr *WebhookRouter) Redeliver(c fiber.Ctx) error {
+	original, err := wr.findDelivery(c)
+	if err != nil {
+		return err
+	}
+
+	delivery := &types.WebhookDelivery{
+		ID:             uuid.New().String(),
+		SubscriptionID: original.SubscriptionID,
+		Event:          original.Event,
+		Payload:        original.Payload,
+		RedeliveryOf:   original.ID,
+	}
+	if err := wr.apiServer.enqueueDelivery(c, delivery); err != nil {
+		log.Error().Err(err).Str("delivery_id", original.ID).Msg("Failed to redeliver webhook")
+		return apierror.Internal(types.ErrorCodeRedeliverFailed, "Failed to redeliver webhook")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(delivery)
+}
+
+// dispatchWebhook sends an event to every webhook subscription of the user that wants it
+// The change has already been saved so failures are only logged
+func (apiServer *StackAPIServer) dispatchWebhook(c fiber.Ctx, userID string, event types.WebhookEvent, data any) {
+	apiServer.jobqueue.DispatchWebhook(c.Context(), userID, event, data)
+}
+
+// enqueueDelivery logs a pending delivery along with the job that sends it
+func (apiServer *StackAPIServer) enqueueDelivery(c fiber.Ctx, delivery *types.WebhookDelivery) error {
+	return apiServer.jobqueue.CreateWebhookDelivery(c.Context(), delivery)
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"strings"
+
+	"github.com/binocarlos/kai-stack/api/pkg/apierror"
+	"github.com/binocarlos/kai-stack/api/pkg/jobqueue"
+	"github.com/binocarlos/kai-stack/api/pkg/openapi"
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// RegisterWorkerRoutes registers the routes that workers post job results to
+func (apiServer *StackAPIServer) RegisterWorkerRoutes() {
+	// Worker callbacks - authenticated with the shared worker secret
+	workerRouter := apiServer.router.Group("/worker", apiServer.RequireWorkerAuth)
+	workerRouter.Post("/test", apiServer.WorkerTestResult)
+	workerRouter.Post("/error", apiServer.WorkerErrorResult)
+	workerRouter.Post("/panic", apiServer.WorkerPanicResult)
+
+	workerResults := []struct {
+		path    string
+		request any
+		summary string
+	}{
+		{"/worker/test", jobqueue.TestResult{}, "Save the result of a test job"},
+		{"/worker/error", jobqueue.ErrorResult{}, "Save the final error of a job"},
+		{"/worker/panic", jobqueue.PanicResult{}, "Save the stack trace of a job that panicked"},
+	}
+	for _, workerResult := range workerResults {
+		apiServer.document(openapi.Operation{
+			Method:   fiber.MethodPost,
+			Path:     workerResult.path,
+			Summary:  workerResult.summary,
+			Tags:     []string{"worker"},
+			Request:  workerResult.request,
+			Response: types.JobResult{},
+			Status:   fiber.StatusCreated,
+			Security: []string{securityWorker},
+			Errors: []openapi.ErrorResponse{
+				{Status: fiber.StatusBadRequest, Code: "INVALID_REQUEST"},
+				{Status: fiber.StatusBadRequest, Code: "MISSING_JOB_ID"},
+				{Status: fiber.StatusInternalServerError, Code: "SAVE_JOB_RESULT_FAILED"},
+			},
+		})
+	}
+}
+
+// RequireWorkerAuth is a middleware that checks the request carries the worker secret
+func (apiServer *StackAPIServer) RequireWorkerAuth(c fiber.Ctx) error {
+	secret := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+	expected := apiServer.cfg.Worker.Secret
+	if secret == "" || expected == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) != 1 {
+		log.Warn().
+			Str("path", c.Path()).
+			Str("ip", c.IP()).
+			Msg("Worker auth middleware: Invalid worker secret")
+		return apierror.Unauthorized("Invalid worker secret")
+	}
+	return c.Next()
+}
+
+// WorkerTestResult stores the result of a completed test job
+func (apiServer *StackAPIServer) WorkerTestResult(c fiber.Ctx) error {
+	req, err := getRequestData[jobqueue.TestResult](c)
+	if err != nil {
+		return apierror.BadRequest(types.ErrorCodeInvalidRequest, "Invalid request body")
+	}
+	return apiServer.saveJobResult(c, req.JobID, types.JobResultTypeTest)
+}
+
+// WorkerErrorResult stores the final error of a job that ran out of attempts
+func (apiServer *StackAPIServer) WorkerErrorResult(c fiber.Ctx) error {
+	req, err := getRequestData[jobqueue.ErrorResult](c)
+	if err != nil {
+		return apierror.BadRequest(types.ErrorCodeInvalidRequest, "Invalid request body")
+	}
+	return apiServer.saveJobResult(c, req.JobID, types.JobResultTypeError)
+}
+
+// WorkerPanicResult stores the stack trace of a job that panicked on its final attempt
+func (apiServer *StackAPIServer) WorkerPanicResult(c fiber.Ctx) error {
+	req, err := getRequestData[jobqueue.PanicResult](c)
+	if err != nil {
+		return apierror.BadRequest(types.ErrorCodeInvalidRequest, "Invalid request body")
+	}
+	return apiServer.saveJobResult(c, req.JobID, types.JobResultTypePanic)
+}
+
+// saveJobResult stores the raw request body against the job
+func (apiServer *StackAPIServer) saveJobResult(c fiber.Ctx, jobID int64, resultType types.JobResultType) error {
+	if jobID == 0 {
+		return apierror.BadRequest(types.ErrorCodeMissingJobID, "job_id is required")
+	}
+
+	result := &types.JobResult{
+		ID:      uuid.New().String(),
+		JobID:   jobID,
+		Type:    resultType,
+		Payload: json.RawMessage(append([]byte(nil), c.Body()...)),
+	}
+
+	if err := apiServer.store.JobResults().Create(result); err != nil {
+		log.Error().Err(err).Int64("job_id", jobID).Msg("Failed to save job result")
+		return apierror.Internal(types.ErrorCodeSaveJobResultFailed, "Failed to save job result")
+	}
+
+	log.Info().
+		Int64("job_id", jobID).
+		Str("type", string(resultType)).
+		Msg("Saved job result")
+
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository struct {
+	*Repository[types.APIKey]
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{
+		Repository: NewRepository[types.APIKey](db),
+	}
+}
+
+// FindActiveByHash loads the unrevoked API key with the given hash
+func (r *APIKeyRepository) FindActiveByHash(keyHash string) (*types.APIKey, error) {
+	var key types.APIKey
+	err := r.db.First(&key, "key_hash = ? AND revoked_at = 0", keyHash).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// LoadForUser returns the unrevoked API keys of a user, newest first
+func (r *APIKeyRepository) LoadForUser(userID string) ([]types.APIKey, error) {
+	var keys []types.APIKey
+	err := r.db.Where("user_id = ? AND revoked_at = 0", userID).Order("created_at desc").Find(&keys).Error
+	return keys, err
+}
+
+// Revoke revokes one of the user's API keys
+// gorm.ErrRecordNotFound is returned if the user has no such active key
+func (r *APIKeyRepository) Revoke(id string, userID string) error {
+	result := r.db.Model(&types.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at = 0", id, userID).
+		Update("revoked_at", time.Now().Unix())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// TouchLastUsed records that the key was used, at most once per interval
+// so busy scripts don't turn every request into a write
+func (r *APIKeyRepository) TouchLastUsed(key *types.APIKey, interval time.Duration) error {
+	now := time.Now().Unix()
+	if now-key.LastUsedAt < int64(interval.Seconds()) {
+		return nil
+	}
+	return r.db.Model(&types.APIKey{}).
+		Where("id = ?", key.ID).
+		UpdateColumn("last_used_at", now).Error
+}
@@ -0,0 +1,16 @@
+package store
+
+import (
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"gorm.io/gorm"
+)
+
+type AuditEventRepository struct {
+	*Repository[types.AuditEvent]
+}
+
+func NewAuditEventRepository(db *gorm.DB) *AuditEventRepository {
+	return &AuditEventRepository{
+		Repository: NewRepository[types.AuditEvent](db),
+	}
+}
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+)
+
+// CHANGE_EVENTS_CHANNEL is the Postgres NOTIFY channel change events are published on
+const CHANGE_EVENTS_CHANNEL = "change_events"
+
+// PublishChangeEvent sends a change event to every API replica listening on CHANGE_EVENTS_CHANNEL
+// The event is numbered from the change_event_ids sequence as it is sent so IDs
+// are the same on every replica - the payload must stay under the 8000 byte NOTIFY limit
+func (s *PostgresStore) PublishChangeEvent(ctx context.Context, event *types.ChangeEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.gdb.WithContext(ctx).Exec(
+		"SELECT pg_notify(?, jsonb_set(?::jsonb, '{id}', to_jsonb(nextval('change_event_ids')))::text)",
+		CHANGE_EVENTS_CHANNEL, string(payload),
+	).Error
+}
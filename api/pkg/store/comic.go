@@ -1,8 +1,12 @@
 package store
 
 import (
+	"encoding/json"
+
 	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ComicRepository struct {
@@ -11,12 +15,60 @@ type ComicRepository struct {
 
 func NewComicRepository(db *gorm.DB) *ComicRepository {
 	return &ComicRepository{
-		Repository: NewRepository[types.Comic](db),
+		Repository: NewVersionedRepository[types.Comic](db).WithSoftDelete(),
 	}
 }
 
 func (r *ComicRepository) LoadForUser(userID string) ([]types.Comic, error) {
 	var comics []types.Comic
-	err := r.db.Where("user = ?", userID).Find(&comics).Error
+	err := r.live().Where("user_id = ?", userID).Find(&comics).Error
 	return comics, err
 }
+
+// SaveScript writes the generated script onto a comic without touching its other fields
+// The comic from before the script is kept as a revision by userID in the same transaction
+// and the updated comic is returned
+func (r *ComicRepository) SaveScript(id string, userID string, script *types.ComicScript) (*types.Comic, error) {
+	resource, err := r.Table()
+	if err != nil {
+		return nil, err
+	}
+
+	var comic types.Comic
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(DELETED_AT_COLUMN+" = 0").
+			First(&comic, "id = ?", id).Error
+		if err != nil {
+			return err
+		}
+
+		previous, err := json.Marshal(&comic)
+		if err != nil {
+			return err
+		}
+		err = recordRevision(tx, &types.Revision{
+			ID:       uuid.New().String(),
+			Resource: resource,
+			EntityID: id,
+			UserID:   userID,
+			Snapshot: previous,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&comic).Select("script", "updated_at").Updates(&types.Comic{Script: script}).Error; err != nil {
+			return err
+		}
+		// the script is serialized by the struct update so the version is a second statement
+		if err := tx.Model(&comic).UpdateColumn(VERSION_COLUMN, incrementVersion).Error; err != nil {
+			return err
+		}
+		return tx.First(&comic, "id = ?", id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &comic, nil
+}
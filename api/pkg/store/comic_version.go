@@ -0,0 +1,171 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotPublished is returned by Unpublish when the comic is not published
+var ErrNotPublished = errors.New("comic is not published")
+
+type ComicVersionRepository struct {
+	*Repository[types.ComicVersion]
+}
+
+func NewComicVersionRepository(db *gorm.DB) *ComicVersionRepository {
+	return &ComicVersionRepository{
+		Repository: NewRepository[types.ComicVersion](db),
+	}
+}
+
+// Publish snapshots the comic with its pages and panels into a new version
+// and makes that version the public one
+func (r *ComicVersionRepository) Publish(comicID string) (*types.ComicVersion, error) {
+	var version *types.ComicVersion
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var comic types.Comic
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&comic, "id = ?", comicID).Error
+		if err != nil {
+			return err
+		}
+
+		snapshot := &types.ComicSnapshot{
+			Config: comic.Config,
+			Script: comic.Script,
+			Pages:  []types.PageSnapshot{},
+		}
+
+		var pages []types.Page
+		if err := tx.Where("comic_id = ?", comicID).Order("position asc, id asc").Find(&pages).Error; err != nil {
+			return err
+		}
+		for _, page := range pages {
+			var panels []types.Panel
+			if err := tx.Where("page_id = ?", page.ID).Order("position asc, id asc").Find(&panels).Error; err != nil {
+				return err
+			}
+			if panels == nil {
+				panels = []types.Panel{}
+			}
+			snapshot.Pages = append(snapshot.Pages, types.PageSnapshot{Page: page, Panels: panels})
+		}
+
+		var latest int
+		err = tx.Model(&types.ComicVersion{}).
+			Where("comic_id = ?", comicID).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+
+		version = &types.ComicVersion{
+			ID:       uuid.New().String(),
+			ComicID:  comicID,
+			Version:  latest + 1,
+			Snapshot: snapshot,
+		}
+		if err := tx.Create(version).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&comic).Updates(map[string]any{
+			"status":            types.ConfigTypePublished,
+			"published_version": version.Version,
+			VERSION_COLUMN:      incrementVersion,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// Unpublish makes the comic a private preview again, its versions are kept
+// ErrNotPublished is returned if the comic is not published so nothing is changed
+func (r *ComicVersionRepository) Unpublish(comicID string) error {
+	result := r.db.Model(&types.Comic{ID: comicID}).
+		Where("status = ?", types.ConfigTypePublished).
+		Updates(map[string]any{
+			"status":       types.ConfigTypePreview,
+			VERSION_COLUMN: incrementVersion,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotPublished
+	}
+	return nil
+}
+
+// LoadForComic returns every version of a comic, newest first
+func (r *ComicVersionRepository) LoadForComic(comicID string) ([]types.ComicVersion, error) {
+	var versions []types.ComicVersion
+	err := r.db.Where("comic_id = ?", comicID).Order("version desc").Find(&versions).Error
+	return versions, err
+}
+
+// publishedScope limits a query to the current public version of each published comic
+func publishedScope(db *gorm.DB) *gorm.DB {
+	return db.
+		Joins("JOIN comics ON comics.id = comic_versions.comic_id AND comics.published_version = comic_versions.version").
+		Where("comics.status = ? AND comics.deleted_at = 0", types.ConfigTypePublished)
+}
+
+// FindPublished returns the public version of a comic
+// gorm.ErrRecordNotFound is returned if the comic is not published
+func (r *ComicVersionRepository) FindPublished(comicID string) (*types.ComicVersion, error) {
+	var version types.ComicVersion
+	err := r.db.Scopes(publishedScope).
+		Where("comic_versions.comic_id = ?", comicID).
+		First(&version).Error
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// ListPublished loads a page of the public versions of published comics
+// Sort columns must be qualified with the comic_versions table, cursors are not supported
+func (r *ComicVersionRepository) ListPublished(query *ListQuery) (*ListResult[types.ComicVersion], error) {
+	if query.Cursor != nil {
+		return nil, fmt.Errorf("cursor pagination is not supported for published comics")
+	}
+
+	var total int64
+	if err := r.db.Model(&types.ComicVersion{}).Scopes(publishedScope).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	db := r.db.Scopes(publishedScope)
+	for _, sort := range query.Sort {
+		direction := "ASC"
+		if sort.Desc {
+			direction = "DESC"
+		}
+		db = db.Order(fmt.Sprintf("%s %s", sort.Column, direction))
+	}
+	db = db.Order("comic_versions.id ASC")
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		db = db.Offset(query.Offset)
+	}
+
+	var versions []types.ComicVersion
+	if err := db.Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	return &ListResult[types.ComicVersion]{
+		Items: versions,
+		Total: total,
+	}, nil
+}
@@ -0,0 +1,23 @@
+package store
+
+import (
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"gorm.io/gorm"
+)
+
+type JobResultRepository struct {
+	*Repository[types.JobResult]
+}
+
+func NewJobResultRepository(db *gorm.DB) *JobResultRepository {
+	return &JobResultRepository{
+		Repository: NewRepository[types.JobResult](db),
+	}
+}
+
+// LoadForJob returns every result posted for a job, oldest first
+func (r *JobResultRepository) LoadForJob(jobID int64) ([]types.JobResult, error) {
+	var results []types.JobResult
+	err := r.db.Where("job_id = ?", jobID).Order("created_at asc").Find(&results).Error
+	return results, err
+}
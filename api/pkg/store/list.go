@@ -0,0 +1,194 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// SortField orders a list query by a single column
+// Column must be a trusted column name - never pass user input directly
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// Filter restricts a list query to rows where Expression equals Value
+// Expression must be a trusted SQL expression (e.g. "user_id" or "config #>> '{name}'")
+// Value is always passed as a bound parameter
+type Filter struct {
+	Expression string
+	Value      any
+	// Op compares Expression with Value instead of = (one of <, <=, > or >=)
+	Op string
+}
+
+// Cursor marks the last row of a page for keyset pagination
+// Value is the value of the sort column and ID the primary key of that row
+type Cursor struct {
+	Value any    `json:"v"`
+	ID    string `json:"id"`
+}
+
+// ListQuery describes a page of rows to load from a repository
+// If Cursor is set then Offset is ignored and the page starts after the cursor row
+// Keyset pagination with a cursor supports at most one sort field (plus the ID tie-breaker)
+type ListQuery struct {
+	Limit   int
+	Offset  int
+	Sort    []SortField
+	Filters []Filter
+	Cursor  *Cursor
+}
+
+// ListResult is a page of rows along with the total matching the filters
+// NextCursor is nil when there are no more rows
+type ListResult[T any] struct {
+	Items      []T
+	Total      int64
+	NextCursor *Cursor
+}
+
+// filterOps are the comparisons a Filter can use
+var filterOps = map[string]bool{"=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// filterScope returns a gorm scope that applies each filter as a condition
+func filterScope(filters []Filter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, filter := range filters {
+			op := filter.Op
+			if op == "" {
+				op = "="
+			}
+			if !filterOps[op] {
+				db.AddError(fmt.Errorf("unsupported filter operator %q", op))
+				return db
+			}
+			db = db.Where(fmt.Sprintf("%s %s ?", filter.Expression, op), filter.Value)
+		}
+		return db
+	}
+}
+
+// List loads a page of entities using the given query
+func (r *Repository[T]) List(query *ListQuery) (*ListResult[T], error) {
+	return r.list(r.live, query)
+}
+
+// ListTrash loads a page of the entities in the trash using the given query
+func (r *Repository[T]) ListTrash(query *ListQuery) (*ListResult[T], error) {
+	return r.list(r.trashed, query)
+}
+
+// list loads a page of the rows selected by base using the given query
+func (r *Repository[T]) list(base func() *gorm.DB, query *ListQuery) (*ListResult[T], error) {
+	if query.Cursor != nil && len(query.Sort) > 1 {
+		return nil, fmt.Errorf("cursor pagination supports a single sort field")
+	}
+
+	var total int64
+	if err := base().Model(new(T)).Scopes(filterScope(query.Filters)).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	db := base().Scopes(filterScope(query.Filters))
+
+	if query.Cursor != nil {
+		column, desc := "id", false
+		if len(query.Sort) == 1 {
+			column, desc = query.Sort[0].Column, query.Sort[0].Desc
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		if column == "id" {
+			db = db.Where(fmt.Sprintf("id %s ?", op), query.Cursor.ID)
+		} else {
+			db = db.Where(
+				fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", column, op, column, op),
+				query.Cursor.Value, query.Cursor.Value, query.Cursor.ID,
+			)
+		}
+	} else if query.Offset > 0 {
+		db = db.Offset(query.Offset)
+	}
+
+	// always finish with the ID so the ordering (and therefore the cursor) is stable
+	// the ID follows the direction of the first sort unless it is sorted explicitly
+	idDesc := len(query.Sort) > 0 && query.Sort[0].Desc
+	for _, sort := range query.Sort {
+		if sort.Column == "id" {
+			idDesc = sort.Desc
+			continue
+		}
+		direction := "ASC"
+		if sort.Desc {
+			direction = "DESC"
+		}
+		db = db.Order(fmt.Sprintf("%s %s", sort.Column, direction))
+	}
+	if idDesc {
+		db = db.Order("id DESC")
+	} else {
+		db = db.Order("id ASC")
+	}
+
+	// load one extra row so we know if there is another page
+	var items []T
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit + 1)
+	}
+	if err := db.Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	result := &ListResult[T]{
+		Items: items,
+		Total: total,
+	}
+
+	if query.Limit > 0 && len(items) > query.Limit {
+		result.Items = items[:query.Limit]
+		// multi-column sorts can only be paged with an offset
+		if len(query.Sort) <= 1 {
+			cursor, err := r.cursorFor(&result.Items[query.Limit-1], query.Sort)
+			if err != nil {
+				return nil, err
+			}
+			result.NextCursor = cursor
+		}
+	}
+
+	return result, nil
+}
+
+// cursorFor builds the cursor pointing at the given entity
+func (r *Repository[T]) cursorFor(entity *T, sort []SortField) (*Cursor, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(entity); err != nil {
+		return nil, fmt.Errorf("failed to parse entity schema: %w", err)
+	}
+
+	value := reflect.ValueOf(entity).Elem()
+
+	idField := stmt.Schema.LookUpField("id")
+	if idField == nil {
+		return nil, fmt.Errorf("entity has no id column")
+	}
+	id, _ := idField.ValueOf(context.Background(), value)
+
+	cursor := &Cursor{ID: fmt.Sprint(id)}
+
+	if len(sort) == 1 && sort[0].Column != "id" {
+		field := stmt.Schema.LookUpField(sort[0].Column)
+		if field == nil {
+			return nil, fmt.Errorf("entity has no %s column", sort[0].Column)
+		}
+		cursor.Value, _ = field.ValueOf(context.Background(), value)
+	}
+
+	return cursor, nil
+}
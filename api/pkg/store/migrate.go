@@ -0,0 +1,214 @@
+package store
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/rs/zerolog/log"
+)
+
+// migrationsFS holds the numbered up/down SQL migrations that are compiled into the binary
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// MigrationInfo describes a single migration and whether it has been applied
+type MigrationInfo struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+// MigrationStatus is the current migration version of the database
+type MigrationStatus struct {
+	Version    uint
+	Dirty      bool
+	Migrations []MigrationInfo
+}
+
+// withMigrator runs fn with a golang-migrate instance using a dedicated connection
+// from the store's pool - the pool itself is left open when fn returns
+func (s *PostgresStore) withMigrator(ctx context.Context, fn func(m *migrate.Migrate) error) error {
+	// If schema is specified, check if it exists and if not - create it
+	if s.cfg.Schema != "" {
+		err := s.gdb.WithContext(ctx).Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", s.cfg.Schema)).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	sqlDB, err := s.gdb.DB()
+	if err != nil {
+		return err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get migration connection: %w", err)
+	}
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{
+		SchemaName: s.cfg.Schema,
+	})
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to create migration driver: %w", err)
+	}
+
+	sourceDriver, err := iofs.New(migrationsFS, "migrations")
+	if err != nil {
+		_ = driver.Close()
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", sourceDriver, s.cfg.Database, driver)
+	if err != nil {
+		_ = sourceDriver.Close()
+		_ = driver.Close()
+		return fmt.Errorf("failed to create migrator: %w", err)
+	}
+	defer func() {
+		// closes the dedicated connection but not the pool
+		sourceErr, dbErr := m.Close()
+		if sourceErr != nil || dbErr != nil {
+			log.Warn().AnErr("source", sourceErr).AnErr("database", dbErr).Msg("failed to close migrator")
+		}
+	}()
+
+	return fn(m)
+}
+
+// MigrateUp applies every pending migration
+func (s *PostgresStore) MigrateUp(ctx context.Context) error {
+	return s.withMigrator(ctx, func(m *migrate.Migrate) error {
+		err := m.Up()
+		if errors.Is(err, migrate.ErrNoChange) {
+			log.Info().Msg("database migrations are up to date")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		version, _, _ := m.Version()
+		log.Info().Uint("version", version).Msg("applied database migrations")
+		return nil
+	})
+}
+
+// MigrateDown rolls back the given number of applied migrations
+func (s *PostgresStore) MigrateDown(ctx context.Context, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("steps must be at least 1")
+	}
+	return s.withMigrator(ctx, func(m *migrate.Migrate) error {
+		err := m.Steps(-steps)
+		if errors.Is(err, migrate.ErrNoChange) {
+			return nil
+		}
+		return err
+	})
+}
+
+// MigrationStatus returns the current version and which migrations have been applied
+func (s *PostgresStore) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	migrations, err := listMigrations(migrationsFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{}
+	err = s.withMigrator(ctx, func(m *migrate.Migrate) error {
+		version, dirty, err := m.Version()
+		if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+			return err
+		}
+		status.Version = version
+		status.Dirty = dirty
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range migrations {
+		migration.Applied = migration.Version <= status.Version
+		status.Migrations = append(status.Migrations, migration)
+	}
+
+	return status, nil
+}
+
+// CreateMigration writes an empty up/down migration pair to dir using the next version number
+// dir should be the migrations folder in the source tree (e.g. api/pkg/store/migrations)
+func CreateMigration(dir string, name string) ([]string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), "_")
+	if name == "" {
+		return nil, fmt.Errorf("migration name is required")
+	}
+
+	migrations, err := listMigrations(os.DirFS(dir), ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var version uint = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	var files []string
+	for _, direction := range []source.Direction{source.Up, source.Down} {
+		filename := filepath.Join(dir, fmt.Sprintf("%06d_%s.%s.sql", version, name, direction))
+		err := os.WriteFile(filename, []byte(fmt.Sprintf("-- %s migration for %s\n", direction, name)), 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write migration: %w", err)
+		}
+		files = append(files, filename)
+	}
+
+	return files, nil
+}
+
+// listMigrations returns the migrations found in a folder ordered by version
+func listMigrations(fsys fs.FS, dir string) ([]MigrationInfo, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[uint]MigrationInfo{}
+	for _, entry := range entries {
+		migration, err := source.Parse(entry.Name())
+		if err != nil {
+			continue
+		}
+		byVersion[migration.Version] = MigrationInfo{
+			Version: migration.Version,
+			Name:    migration.Identifier,
+		}
+	}
+
+	migrations := make([]MigrationInfo, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS comics (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    created_at BIGINT,
+    updated_at BIGINT,
+    config JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_comics_user_id ON comics (user_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS users (
+    id VARCHAR(36) PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    roles JSONB,
+    created_at BIGINT,
+    updated_at BIGINT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS job_results (
+    id VARCHAR(36) PRIMARY KEY,
+    job_id BIGINT NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    payload JSONB,
+    created_at BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_results_job_id ON job_results (job_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS panels;
+DROP TABLE IF EXISTS pages;
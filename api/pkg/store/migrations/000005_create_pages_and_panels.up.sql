@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS pages (
+    id VARCHAR(36) PRIMARY KEY,
+    comic_id VARCHAR(36) NOT NULL REFERENCES comics (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0,
+    layout VARCHAR(64),
+    created_at BIGINT,
+    updated_at BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_pages_comic_id ON pages (comic_id, position);
+
+CREATE TABLE IF NOT EXISTS panels (
+    id VARCHAR(36) PRIMARY KEY,
+    page_id VARCHAR(36) NOT NULL REFERENCES pages (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0,
+    layout JSONB,
+    caption TEXT,
+    dialogue JSONB,
+    image_url TEXT,
+    created_at BIGINT,
+    updated_at BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_panels_page_id ON panels (page_id, position);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS comic_versions;
+
+DROP INDEX IF EXISTS idx_comics_status;
+ALTER TABLE comics DROP COLUMN IF EXISTS published_version;
+ALTER TABLE comics DROP COLUMN IF EXISTS status;
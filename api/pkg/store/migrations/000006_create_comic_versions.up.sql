@@ -0,0 +1,15 @@
+ALTER TABLE comics ADD COLUMN IF NOT EXISTS status INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comics ADD COLUMN IF NOT EXISTS published_version INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_comics_status ON comics (status);
+
+CREATE TABLE IF NOT EXISTS comic_versions (
+    id VARCHAR(36) PRIMARY KEY,
+    comic_id VARCHAR(36) NOT NULL REFERENCES comics (id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    snapshot JSONB,
+    created_at BIGINT,
+    UNIQUE (comic_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_comic_versions_comic_id ON comic_versions (comic_id);
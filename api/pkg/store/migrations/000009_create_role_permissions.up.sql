@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(64) NOT NULL,
+    permission VARCHAR(128) NOT NULL,
+    created_at BIGINT,
+    PRIMARY KEY (role, permission)
+);
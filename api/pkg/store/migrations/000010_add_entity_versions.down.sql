@@ -0,0 +1,3 @@
+ALTER TABLE panels DROP COLUMN IF EXISTS version;
+ALTER TABLE pages DROP COLUMN IF EXISTS version;
+ALTER TABLE comics DROP COLUMN IF EXISTS version;
@@ -0,0 +1,3 @@
+ALTER TABLE comics ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE panels ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_comics_deleted_at;
+ALTER TABLE comics DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,3 @@
+ALTER TABLE comics ADD COLUMN IF NOT EXISTS deleted_at BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_comics_deleted_at ON comics (deleted_at) WHERE deleted_at > 0;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS revisions (
+    id VARCHAR(36) PRIMARY KEY,
+    resource VARCHAR(64) NOT NULL,
+    entity_id VARCHAR(36) NOT NULL,
+    number INTEGER NOT NULL,
+    user_id VARCHAR(36),
+    snapshot JSONB,
+    created_at BIGINT,
+    UNIQUE (resource, entity_id, number)
+);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id VARCHAR(36) PRIMARY KEY,
+    actor_id VARCHAR(36),
+    ip VARCHAR(64),
+    method VARCHAR(16) NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    status INTEGER NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    resource VARCHAR(64),
+    resource_id VARCHAR(36),
+    before JSONB,
+    after JSONB,
+    request_id VARCHAR(64),
+    created_at BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_resource ON audit_events (resource, resource_id, created_at);
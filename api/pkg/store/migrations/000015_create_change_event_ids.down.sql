@@ -0,0 +1 @@
+DROP SEQUENCE IF EXISTS change_event_ids;
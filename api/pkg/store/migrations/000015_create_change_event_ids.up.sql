@@ -0,0 +1 @@
+CREATE SEQUENCE IF NOT EXISTS change_event_ids;
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"gorm.io/gorm"
+)
+
+type PageRepository struct {
+	*Repository[types.Page]
+}
+
+func NewPageRepository(db *gorm.DB) *PageRepository {
+	return &PageRepository{
+		Repository: NewVersionedRepository[types.Page](db).WithPositions("comic_id"),
+	}
+}
+
+// LoadForComic returns the pages of a comic in order
+func (r *PageRepository) LoadForComic(comicID string) ([]types.Page, error) {
+	var pages []types.Page
+	err := r.db.Where("comic_id = ?", comicID).Order("position asc, id asc").Find(&pages).Error
+	return pages, err
+}
+
+type PanelRepository struct {
+	*Repository[types.Panel]
+}
+
+func NewPanelRepository(db *gorm.DB) *PanelRepository {
+	return &PanelRepository{
+		Repository: NewVersionedRepository[types.Panel](db).WithPositions("page_id"),
+	}
+}
+
+// LoadForPage returns the panels of a page in order
+func (r *PanelRepository) LoadForPage(pageID string) ([]types.Panel, error) {
+	var panels []types.Panel
+	err := r.db.Where("page_id = ?", pageID).Order("position asc, id asc").Find(&panels).Error
+	return panels, err
+}
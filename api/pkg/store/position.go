@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrReorderMismatch is returned by Reorder when the IDs are not exactly the parent's children
+var ErrReorderMismatch = errors.New("ids must list every child exactly once")
+
+// POSITION_COLUMN orders the rows under a parent starting at 0
+const POSITION_COLUMN = "position"
+
+// WithPositions makes Create insert entities at their position among the rows with the same
+// value in parentColumn - rows at or after it are moved down one to make room, and a negative
+// position or one past the end adds the entity at the end
+// parentColumn must be a trusted column name
+func (r *Repository[T]) WithPositions(parentColumn string) *Repository[T] {
+	r.positionParent = parentColumn
+	return r
+}
+
+// createAtPosition inserts the entity at its position under its parent in one transaction
+func (r *Repository[T]) createAtPosition(entity *T) error {
+	parentID, err := r.FieldString(entity, r.positionParent)
+	if err != nil {
+		return err
+	}
+	field, err := r.field(entity, POSITION_COLUMN)
+	if err != nil {
+		return err
+	}
+	value, _ := field.ValueOf(context.Background(), reflect.ValueOf(entity).Elem())
+	position, ok := value.(int)
+	if !ok {
+		return fmt.Errorf("%s column must be an int", POSITION_COLUMN)
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := r.lockParent(tx, parentID); err != nil {
+			return err
+		}
+
+		var next int
+		err := tx.Model(new(T)).
+			Scopes(r.notTrashed).
+			Where(fmt.Sprintf("%s = ?", r.positionParent), parentID).
+			Select(fmt.Sprintf("COALESCE(MAX(%s) + 1, 0)", POSITION_COLUMN)).
+			Scan(&next).Error
+		if err != nil {
+			return err
+		}
+
+		if position < 0 || position > next {
+			position = next
+		}
+		if position < next {
+			updates := map[string]any{POSITION_COLUMN: gorm.Expr(POSITION_COLUMN + " + 1")}
+			if r.versioned {
+				updates[VERSION_COLUMN] = incrementVersion
+			}
+			err := tx.Model(new(T)).
+				Scopes(r.notTrashed).
+				Where(fmt.Sprintf("%s = ?", r.positionParent), parentID).
+				Where(POSITION_COLUMN+" >= ?", position).
+				Updates(updates).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := r.SetField(entity, POSITION_COLUMN, position); err != nil {
+			return err
+		}
+		return tx.Create(entity).Error
+	})
+}
+
+// lockParent holds a transaction level lock on the rows under a parent so concurrent
+// creates and reorders under it run one at a time rather than picking the same positions
+func (r *Repository[T]) lockParent(tx *gorm.DB, parentID string) error {
+	table, err := r.Table()
+	if err != nil {
+		return err
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", table+":"+parentID).Error
+}
+
+// Reorder sets the position of every row under the parent to its index in ids
+// parentColumn must be a trusted column name
+func (r *Repository[T]) Reorder(parentColumn string, parentID string, ids []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if parentColumn == r.positionParent {
+			if err := r.lockParent(tx, parentID); err != nil {
+				return err
+			}
+		}
+
+		var existing []string
+		err := tx.Model(new(T)).
+			Scopes(r.notTrashed).
+			Where(fmt.Sprintf("%s = ?", parentColumn), parentID).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Pluck("id", &existing).Error
+		if err != nil {
+			return err
+		}
+
+		if len(existing) != len(ids) {
+			return ErrReorderMismatch
+		}
+		children := make(map[string]bool, len(existing))
+		for _, id := range existing {
+			children[id] = true
+		}
+		for _, id := range ids {
+			if !children[id] {
+				return ErrReorderMismatch
+			}
+			delete(children, id)
+		}
+
+		for position, id := range ids {
+			updates := map[string]any{POSITION_COLUMN: position}
+			if r.versioned {
+				updates[VERSION_COLUMN] = incrementVersion
+			}
+			err := tx.Model(new(T)).
+				Where("id = ?", id).
+				Updates(updates).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -6,11 +6,11 @@ import (
 	"fmt"
 
 	"github.com/binocarlos/kai-stack/api/pkg/config"
-	"github.com/binocarlos/kai-stack/api/pkg/types"
-	_ "github.com/doug-martin/goqu/v9/dialect/postgres"        // postgres query builder
-	_ "github.com/golang-migrate/migrate/v4/database/postgres" // postgres migrations
-	_ "github.com/lib/pq"                                      // enable postgres driver
+	"github.com/binocarlos/kai-stack/api/pkg/metrics"
+	_ "github.com/doug-martin/goqu/v9/dialect/postgres" // postgres query builder
+	_ "github.com/lib/pq"                               // enable postgres driver
 
+	gormpostgres "gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
@@ -19,7 +19,24 @@ type PostgresStore struct {
 
 	gdb *gorm.DB
 
-	comics *ComicRepository
+	comics   *ComicRepository
+	versions *ComicVersionRepository
+	pages    *PageRepository
+	panels   *PanelRepository
+	users    *UserRepository
+
+	revisions   *RevisionRepository
+	auditEvents *AuditEventRepository
+
+	webhookSubscriptions *WebhookSubscriptionRepository
+	webhookDeliveries    *WebhookDeliveryRepository
+
+	refreshTokens *RefreshTokenRepository
+	revokedTokens *RevokedAccessTokenRepository
+	apiKeys       *APIKeyRepository
+	permissions   *RolePermissionRepository
+
+	jobResults *JobResultRepository
 }
 
 func NewPostgresStore(
@@ -27,39 +44,69 @@ func NewPostgresStore(
 ) (*PostgresStore, error) {
 
 	// Waiting for connection
-	gormDB, err := connect(context.Background(), connectConfig{
-		host:            cfg.Host,
-		port:            cfg.Port,
-		schemaName:      cfg.Schema,
-		database:        cfg.Database,
-		username:        cfg.Username,
-		password:        cfg.Password,
-		ssl:             cfg.SSL,
-		idleConns:       cfg.IdleConns,
-		maxConns:        cfg.MaxConns,
-		maxConnIdleTime: cfg.MaxConnIdleTime,
-		maxConnLifetime: cfg.MaxConnLifetime,
-	})
+	gormDB, err := connect(context.Background(), newConnectConfig(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Postgres: %w", err)
 	}
 
-	store := &PostgresStore{
-		cfg:    cfg,
-		gdb:    gormDB,
-		comics: NewComicRepository(gormDB),
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return nil, err
 	}
+	metrics.RegisterDB("postgres", sqlDB)
+
+	store := newPostgresStore(cfg, gormDB)
 
-	if cfg.AutoMigrate {
-		err = store.autoMigrate()
+	if cfg.MigrateOnStartup {
+		err = store.MigrateUp(context.Background())
 		if err != nil {
-			return nil, fmt.Errorf("there was an error doing the automigration: %s", err.Error())
+			return nil, fmt.Errorf("there was an error applying the migrations: %s", err.Error())
 		}
 	}
 
 	return store, nil
 }
 
+// NewOfflineStore creates a store that is never connected to a database
+// It is for tooling that needs the repositories to build the routes (e.g. writing
+// the OpenAPI document) - queries are built in dry run mode and never executed
+func NewOfflineStore(cfg config.Database) (*PostgresStore, error) {
+	gormDB, err := gorm.Open(gormpostgres.New(gormpostgres.Config{}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create offline store: %w", err)
+	}
+	return newPostgresStore(cfg, gormDB), nil
+}
+
+// newPostgresStore creates the repositories on top of a gorm handle
+func newPostgresStore(cfg config.Database, gormDB *gorm.DB) *PostgresStore {
+	return &PostgresStore{
+		cfg:      cfg,
+		gdb:      gormDB,
+		comics:   NewComicRepository(gormDB),
+		versions: NewComicVersionRepository(gormDB),
+		pages:    NewPageRepository(gormDB),
+		panels:   NewPanelRepository(gormDB),
+		users:    NewUserRepository(gormDB),
+
+		revisions:   NewRevisionRepository(gormDB),
+		auditEvents: NewAuditEventRepository(gormDB),
+
+		webhookSubscriptions: NewWebhookSubscriptionRepository(gormDB),
+		webhookDeliveries:    NewWebhookDeliveryRepository(gormDB),
+
+		refreshTokens: NewRefreshTokenRepository(gormDB),
+		revokedTokens: NewRevokedAccessTokenRepository(gormDB),
+		apiKeys:       NewAPIKeyRepository(gormDB),
+		permissions:   NewRolePermissionRepository(gormDB),
+
+		jobResults: NewJobResultRepository(gormDB),
+	}
+}
+
 func (s *PostgresStore) Close() error {
 	sqlDB, err := s.gdb.DB()
 	if err != nil {
@@ -68,28 +115,21 @@ func (s *PostgresStore) Close() error {
 	return sqlDB.Close()
 }
 
-func (s *PostgresStore) autoMigrate() error {
-	// If schema is specified, check if it exists and if not - create it
-	if s.cfg.Schema != "" {
-		err := s.gdb.WithContext(context.Background()).Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", s.cfg.Schema)).Error
-		if err != nil {
-			return err
-		}
-	}
-
-	err := s.gdb.WithContext(context.Background()).AutoMigrate(
-		&types.Comic{},
-	)
+// Ping checks the database is reachable
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	sqlDB, err := s.gdb.DB()
 	if err != nil {
 		return err
 	}
+	return sqlDB.PingContext(ctx)
+}
 
-	// if err := createFK(s.gdb, types.Comic{}, types.User{}, "user_id", "id", "CASCADE", "CASCADE"); err != nil {
-	// 	log.Err(err).Msg("failed to add DB FK")
-	// }
-
-	return nil
+// DSN returns the connection string of the database
+// for subsystems that need a connection of their own (e.g. to LISTEN)
+func (s *PostgresStore) DSN() string {
+	return newConnectConfig(s.cfg).dsn()
 }
+
 func (s *PostgresStore) SQLDB() (*sql.DB, error) {
 	// expose the underlying *sql.DB for reuse in other subsystems (e.g. job queue)
 	return s.gdb.DB()
@@ -99,3 +139,68 @@ func (s *PostgresStore) SQLDB() (*sql.DB, error) {
 func (s *PostgresStore) Comics() *ComicRepository {
 	return s.comics
 }
+
+// ComicVersions returns the published comic version repository
+func (s *PostgresStore) ComicVersions() *ComicVersionRepository {
+	return s.versions
+}
+
+// Pages returns the comic page repository
+func (s *PostgresStore) Pages() *PageRepository {
+	return s.pages
+}
+
+// Panels returns the page panel repository
+func (s *PostgresStore) Panels() *PanelRepository {
+	return s.panels
+}
+
+// Revisions returns the entity revision repository
+func (s *PostgresStore) Revisions() *RevisionRepository {
+	return s.revisions
+}
+
+// AuditEvents returns the audit log repository
+func (s *PostgresStore) AuditEvents() *AuditEventRepository {
+	return s.auditEvents
+}
+
+// WebhookSubscriptions returns the webhook subscription repository
+func (s *PostgresStore) WebhookSubscriptions() *WebhookSubscriptionRepository {
+	return s.webhookSubscriptions
+}
+
+// WebhookDeliveries returns the webhook delivery log repository
+func (s *PostgresStore) WebhookDeliveries() *WebhookDeliveryRepository {
+	return s.webhookDeliveries
+}
+
+// Users returns the user repository
+func (s *PostgresStore) Users() *UserRepository {
+	return s.users
+}
+
+// RefreshTokens returns the refresh token repository
+func (s *PostgresStore) RefreshTokens() *RefreshTokenRepository {
+	return s.refreshTokens
+}
+
+// RevokedAccessTokens returns the access token blacklist repository
+func (s *PostgresStore) RevokedAccessTokens() *RevokedAccessTokenRepository {
+	return s.revokedTokens
+}
+
+// APIKeys returns the API key repository
+func (s *PostgresStore) APIKeys() *APIKeyRepository {
+	return s.apiKeys
+}
+
+// RolePermissions returns the role to permission table repository
+func (s *PostgresStore) RolePermissions() *RolePermissionRepository {
+	return s.permissions
+}
+
+// JobResults returns the job result repository
+func (s *PostgresStore) JobResults() *JobResultRepository {
+	return s.jobResults
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrRefreshTokenUsed is returned by Rotate when the token was already exchanged
+var ErrRefreshTokenUsed = errors.New("refresh token has already been used")
+
+type RefreshTokenRepository struct {
+	*Repository[types.RefreshToken]
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		Repository: NewRepository[types.RefreshToken](db),
+	}
+}
+
+// FindByHash loads the refresh token with the given hash
+func (r *RefreshTokenRepository) FindByHash(tokenHash string) (*types.RefreshToken, error) {
+	var token types.RefreshToken
+	err := r.db.First(&token, "token_hash = ?", tokenHash).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Rotate marks the old token as used and stores its replacement in one transaction
+// ErrRefreshTokenUsed is returned if another request used the old token first
+func (r *RefreshTokenRepository) Rotate(old *types.RefreshToken, replacement *types.RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&types.RefreshToken{}).
+			Where("id = ? AND used_at = 0 AND revoked_at = 0", old.ID).
+			Update("used_at", time.Now().Unix())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenUsed
+		}
+		return tx.Create(replacement).Error
+	})
+}
+
+// RevokeFamily revokes every token issued from the same login
+func (r *RefreshTokenRepository) RevokeFamily(familyID string) error {
+	return r.db.Model(&types.RefreshToken{}).
+		Where("family_id = ? AND revoked_at = 0", familyID).
+		Update("revoked_at", time.Now().Unix()).Error
+}
+
+type RevokedAccessTokenRepository struct {
+	*Repository[types.RevokedAccessToken]
+}
+
+func NewRevokedAccessTokenRepository(db *gorm.DB) *RevokedAccessTokenRepository {
+	return &RevokedAccessTokenRepository{
+		Repository: NewRepository[types.RevokedAccessToken](db),
+	}
+}
+
+// Revoke blacklists an access token until it expires
+// Entries for tokens that have already expired are removed at the same time
+func (r *RevokedAccessTokenRepository) Revoke(jti string, expiresAt time.Time) error {
+	err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&types.RevokedAccessToken{
+		JTI:       jti,
+		ExpiresAt: expiresAt.Unix(),
+	}).Error
+	if err != nil {
+		return err
+	}
+	return r.db.Where("expires_at < ?", time.Now().Unix()).Delete(&types.RevokedAccessToken{}).Error
+}
+
+// LoadActive returns the jti of every blacklisted access token that has not expired
+func (r *RevokedAccessTokenRepository) LoadActive() ([]string, error) {
+	var jtis []string
+	err := r.db.Model(&types.RevokedAccessToken{}).
+		Where("expires_at >= ?", time.Now().Unix()).
+		Pluck("jti", &jtis).Error
+	return jtis, err
+}
+
+// IsRevoked reports whether an access token has been blacklisted
+func (r *RevokedAccessTokenRepository) IsRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&types.RevokedAccessToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
@@ -1,36 +1,264 @@
 package store
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
+// VERSION_COLUMN is the column versioned repositories use for optimistic concurrency
+const VERSION_COLUMN = "version"
+
+// DELETED_AT_COLUMN is the column soft deleting repositories use to mark rows as trashed
+// it is the unix time the row was deleted, 0 for rows that are not in the trash
+const DELETED_AT_COLUMN = "deleted_at"
+
+// ErrVersionConflict is returned by Update when the entity was changed since it was loaded
+var ErrVersionConflict = errors.New("entity was changed by another request")
+
+// ErrNotInTrash is returned by Restore when there is no trashed entity with the ID
+var ErrNotInTrash = errors.New("entity is not in the trash")
+
+// incrementVersion is added to the updates of versioned rows that don't go through Update
+var incrementVersion = gorm.Expr(VERSION_COLUMN + " + 1")
+
 type Repository[T any] struct {
 	db *gorm.DB
+	// versioned repositories keep a version column that every change increments
+	versioned bool
+	// soft deleting repositories move deleted rows to the trash instead of removing them
+	softDelete bool
+	// positioned repositories insert rows at their position under the parent in this column
+	positionParent string
 }
 
 func NewRepository[T any](db *gorm.DB) *Repository[T] {
 	return &Repository[T]{db: db}
 }
 
+// NewVersionedRepository creates a repository for an entity with a version column
+// Update only saves an entity if the database still has the version it was loaded with,
+// so concurrent writers get ErrVersionConflict instead of overwriting each other
+func NewVersionedRepository[T any](db *gorm.DB) *Repository[T] {
+	return &Repository[T]{db: db, versioned: true}
+}
+
+// WithSoftDelete makes Delete move entities to the trash by setting their deleted_at column
+// Trashed entities are left out of every query until they are restored or purged
+func (r *Repository[T]) WithSoftDelete() *Repository[T] {
+	r.softDelete = true
+	return r
+}
+
+// SoftDeletes reports whether deleted entities are kept in the trash
+func (r *Repository[T]) SoftDeletes() bool {
+	return r.softDelete
+}
+
+// live returns a query that leaves out trashed rows
+func (r *Repository[T]) live() *gorm.DB {
+	return r.db.Scopes(r.notTrashed)
+}
+
+// notTrashed is a gorm scope that leaves out trashed rows
+func (r *Repository[T]) notTrashed(db *gorm.DB) *gorm.DB {
+	if !r.softDelete {
+		return db
+	}
+	return db.Where(DELETED_AT_COLUMN + " = 0")
+}
+
+// trashed returns a query that only loads trashed rows
+// repositories without soft delete have no trash (or deleted_at column) so it matches nothing
+func (r *Repository[T]) trashed() *gorm.DB {
+	if !r.softDelete {
+		return r.db.Where("FALSE")
+	}
+	return r.db.Where(DELETED_AT_COLUMN + " > 0")
+}
+
+// Versioned reports whether the entities have a version column
+func (r *Repository[T]) Versioned() bool {
+	return r.versioned
+}
+
+// Version returns the version of an entity from a versioned repository
+func (r *Repository[T]) Version(entity *T) (int64, error) {
+	field, err := r.field(entity, VERSION_COLUMN)
+	if err != nil {
+		return 0, err
+	}
+	value, _ := field.ValueOf(context.Background(), reflect.ValueOf(entity).Elem())
+	version, ok := value.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%s column must be an int64", VERSION_COLUMN)
+	}
+	return version, nil
+}
+
 func (r *Repository[T]) Create(entity *T) error {
+	if r.versioned {
+		if err := r.SetField(entity, VERSION_COLUMN, int64(1)); err != nil {
+			return err
+		}
+	}
+	if r.positionParent != "" {
+		return r.createAtPosition(entity)
+	}
 	return r.db.Create(entity).Error
 }
 
-func (r *Repository[T]) FindByID(id string, entity *T) error {
-	return r.db.First(entity, "id = ?", id).Error
+// FindByID loads the entity with the given ID
+// Any filters are added to the query (e.g. to scope the lookup to an owner)
+func (r *Repository[T]) FindByID(id string, entity *T, filters ...Filter) error {
+	return r.live().Scopes(filterScope(filters)).First(entity, "id = ?", id).Error
 }
 
 func (r *Repository[T]) FindAll(entities *[]T) error {
-	return r.db.Find(entities).Error
+	return r.live().Find(entities).Error
 }
 
+// Update saves every field of the entity
+// Versioned entities are only saved if their version is unchanged in the database
+// and the version is incremented, otherwise ErrVersionConflict is returned
+// Trashed entities are never saved so a racing update can't restore them,
+// gorm.ErrRecordNotFound is returned for an unversioned entity that is gone or trashed
 func (r *Repository[T]) Update(entity *T) error {
-	return r.db.Save(entity).Error
+	if !r.versioned {
+		result := r.live().Model(entity).Select("*").Updates(entity)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	}
+
+	version, err := r.Version(entity)
+	if err != nil {
+		return err
+	}
+	if err := r.SetField(entity, VERSION_COLUMN, version+1); err != nil {
+		return err
+	}
+	result := r.live().Model(entity).
+		Where(VERSION_COLUMN+" = ?", version).
+		Select("*").
+		Updates(entity)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = ErrVersionConflict
+	}
+	if result.Error != nil {
+		// the entity keeps the version it was loaded with
+		if err := r.SetField(entity, VERSION_COLUMN, version); err != nil {
+			return errors.Join(result.Error, err)
+		}
+		return result.Error
+	}
+	return nil
+}
+
+// Delete removes the entity with the given ID, soft deleting repositories move it to the trash
+// Any filters are added to the query (e.g. to scope the delete to an owner or to the version
+// it was loaded with) and gorm.ErrRecordNotFound is returned if no entity matches them
+func (r *Repository[T]) Delete(id string, filters ...Filter) error {
+	var result *gorm.DB
+	if r.softDelete {
+		updates := map[string]any{DELETED_AT_COLUMN: time.Now().Unix()}
+		if r.versioned {
+			updates[VERSION_COLUMN] = incrementVersion
+		}
+		result = r.live().Model(new(T)).Scopes(filterScope(filters)).Where("id = ?", id).Updates(updates)
+	} else {
+		var entity T
+		result = r.db.Scopes(filterScope(filters)).Delete(&entity, "id = ?", id)
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Restore takes the entity with the given ID out of the trash
+// ErrNotInTrash is returned if no trashed entity matches the ID and filters
+func (r *Repository[T]) Restore(id string, filters ...Filter) error {
+	updates := map[string]any{DELETED_AT_COLUMN: 0}
+	if r.versioned {
+		updates[VERSION_COLUMN] = incrementVersion
+	}
+	result := r.trashed().Model(new(T)).Scopes(filterScope(filters)).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotInTrash
+	}
+	return nil
+}
+
+// Purge permanently removes the entities that were moved to the trash before the cutoff
+// It returns the number of entities removed
+func (r *Repository[T]) Purge(cutoff time.Time) (int64, error) {
+	if !r.softDelete {
+		return 0, nil
+	}
+	result := r.trashed().Where(DELETED_AT_COLUMN+" < ?", cutoff.Unix()).Delete(new(T))
+	return result.RowsAffected, result.Error
+}
+
+// ID returns the primary key of an entity
+func (r *Repository[T]) ID(entity *T) (string, error) {
+	return r.FieldString(entity, "id")
+}
+
+// FieldString returns the struct field mapped to the given column on the entity as a string
+func (r *Repository[T]) FieldString(entity *T, column string) (string, error) {
+	field, err := r.field(entity, column)
+	if err != nil {
+		return "", err
+	}
+	value, _ := field.ValueOf(context.Background(), reflect.ValueOf(entity).Elem())
+	return fmt.Sprint(value), nil
+}
+
+// Table returns the name of the entities' table
+func (r *Repository[T]) Table() (string, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return "", fmt.Errorf("failed to parse entity schema: %w", err)
+	}
+	return stmt.Schema.Table, nil
+}
+
+// SetField sets the struct field mapped to the given column on the entity
+func (r *Repository[T]) SetField(entity *T, column string, value any) error {
+	field, err := r.field(entity, column)
+	if err != nil {
+		return err
+	}
+	return field.Set(context.Background(), reflect.ValueOf(entity).Elem(), value)
 }
 
-func (r *Repository[T]) Delete(id string) error {
-	var entity T
-	return r.db.Delete(&entity, "id = ?", id).Error
+// field returns the schema field mapped to the given column
+func (r *Repository[T]) field(entity *T, column string) (*schema.Field, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(entity); err != nil {
+		return nil, fmt.Errorf("failed to parse entity schema: %w", err)
+	}
+	field := stmt.Schema.LookUpField(column)
+	if field == nil {
+		return nil, fmt.Errorf("entity has no %s column", column)
+	}
+	return field, nil
 }
 
 func (r *Repository[T]) Where(query interface{}, args ...interface{}) *gorm.DB {
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"gorm.io/gorm"
+)
+
+type RevisionRepository struct {
+	*Repository[types.Revision]
+}
+
+func NewRevisionRepository(db *gorm.DB) *RevisionRepository {
+	return &RevisionRepository{
+		Repository: NewRepository[types.Revision](db),
+	}
+}
+
+// Record stores a revision numbered after the latest revision of its entity
+func (r *RevisionRepository) Record(revision *types.Revision) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return recordRevision(tx, revision)
+	})
+}
+
+// recordRevision numbers and stores a revision within tx so it can be saved along with the update it records
+func recordRevision(tx *gorm.DB, revision *types.Revision) error {
+	var latest int
+	err := tx.Model(&types.Revision{}).
+		Where("resource = ? AND entity_id = ?", revision.Resource, revision.EntityID).
+		Select("COALESCE(MAX(number), 0)").
+		Scan(&latest).Error
+	if err != nil {
+		return err
+	}
+	revision.Number = latest + 1
+	return tx.Create(revision).Error
+}
+
+// FindByNumber loads a revision of an entity
+func (r *RevisionRepository) FindByNumber(resource string, entityID string, number int) (*types.Revision, error) {
+	var revision types.Revision
+	err := r.db.
+		Where("resource = ? AND entity_id = ? AND number = ?", resource, entityID, number).
+		First(&revision).Error
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// PurgeOrphans removes the revisions of entities that no longer exist in the resource's table
+// resource must be a trusted table name
+func (r *RevisionRepository) PurgeOrphans(resource string) (int64, error) {
+	result := r.db.
+		Where("resource = ?", resource).
+		Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s WHERE %s.id = revisions.entity_id)", resource, resource)).
+		Delete(&types.Revision{})
+	return result.RowsAffected, result.Error
+}
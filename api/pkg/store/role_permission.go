@@ -0,0 +1,29 @@
+package store
+
+import (
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"gorm.io/gorm"
+)
+
+type RolePermissionRepository struct {
+	*Repository[types.RolePermission]
+}
+
+func NewRolePermissionRepository(db *gorm.DB) *RolePermissionRepository {
+	return &RolePermissionRepository{
+		Repository: NewRepository[types.RolePermission](db),
+	}
+}
+
+// LoadAll returns the permissions of every role that has rows in the table
+func (r *RolePermissionRepository) LoadAll() (map[string][]string, error) {
+	var rows []types.RolePermission
+	if err := r.db.Order("role asc, permission asc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	permissions := map[string][]string{}
+	for _, row := range rows {
+		permissions[row.Role] = append(permissions[row.Role], row.Permission)
+	}
+	return permissions, nil
+}
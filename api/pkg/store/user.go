@@ -0,0 +1,53 @@
+package store
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// ErrEmailTaken is returned by Create when another account already has the email
+var ErrEmailTaken = errors.New("an account with this email already exists")
+
+// uniqueViolation is the Postgres error code for a conflict on a unique index
+const uniqueViolation = "23505"
+
+type UserRepository struct {
+	*Repository[types.UserAccount]
+}
+
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{
+		Repository: NewRepository[types.UserAccount](db),
+	}
+}
+
+// NormalizeEmail lower-cases and trims an email so lookups are case insensitive
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// FindByEmail loads the user account with the given email address
+func (r *UserRepository) FindByEmail(email string) (*types.UserAccount, error) {
+	var user types.UserAccount
+	err := r.db.First(&user, "email = ?", NormalizeEmail(email)).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Create inserts a user account
+// Two registrations racing for the same email conflict on the unique email index
+// and the loser gets ErrEmailTaken
+func (r *UserRepository) Create(user *types.UserAccount) error {
+	err := r.Repository.Create(user)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return ErrEmailTaken
+	}
+	return err
+}
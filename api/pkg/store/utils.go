@@ -11,6 +11,7 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/database/postgres" // postgres migrations
 	_ "github.com/lib/pq"                                      // enable postgres driver
 
+	"github.com/binocarlos/kai-stack/api/pkg/config"
 	"github.com/rs/zerolog/log"
 	gormpostgres "gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -96,6 +97,40 @@ type connectConfig struct {
 	maxConnLifetime time.Duration
 }
 
+func newConnectConfig(cfg config.Database) connectConfig {
+	return connectConfig{
+		host:            cfg.Host,
+		port:            cfg.Port,
+		schemaName:      cfg.Schema,
+		database:        cfg.Database,
+		username:        cfg.Username,
+		password:        cfg.Password,
+		ssl:             cfg.SSL,
+		idleConns:       cfg.IdleConns,
+		maxConns:        cfg.MaxConns,
+		maxConnIdleTime: cfg.MaxConnIdleTime,
+		maxConnLifetime: cfg.MaxConnLifetime,
+	}
+}
+
+// dsn returns the key=value connection string for the config
+func (cfg connectConfig) dsn() string {
+	// Read SSL setting from environment
+	sslSettings := "sslmode=disable"
+	if cfg.ssl {
+		sslSettings = "sslmode=require"
+	}
+
+	dsn := fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s %s",
+		cfg.username, cfg.password, cfg.host, cfg.port, cfg.database, sslSettings)
+
+	// Resolve unqualified table names (e.g. in the SQL migrations) to the schema
+	if cfg.schemaName != "" {
+		dsn += fmt.Sprintf(" search_path=%s", cfg.schemaName)
+	}
+	return dsn
+}
+
 func connect(ctx context.Context, cfg connectConfig) (*gorm.DB, error) {
 	for {
 		select {
@@ -113,16 +148,7 @@ func connect(ctx context.Context, cfg connectConfig) (*gorm.DB, error) {
 				dialector gorm.Dialector
 			)
 
-			// Read SSL setting from environment
-			sslSettings := "sslmode=disable"
-			if cfg.ssl {
-				sslSettings = "sslmode=require"
-			}
-
-			dsn := fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s %s",
-				cfg.username, cfg.password, cfg.host, cfg.port, cfg.database, sslSettings)
-
-			dialector = gormpostgres.Open(dsn)
+			dialector = gormpostgres.Open(cfg.dsn())
 
 			// gormConfig := &gorm.Config{
 			// 	Logger: NewGormLogger(time.Second, true),
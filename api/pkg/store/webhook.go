@@ -0,0 +1,58 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/binocarlos/kai-stack/api/pkg/types"
+	"gorm.io/gorm"
+)
+
+type WebhookSubscriptionRepository struct {
+	*Repository[types.WebhookSubscription]
+}
+
+func NewWebhookSubscriptionRepository(db *gorm.DB) *WebhookSubscriptionRepository {
+	return &WebhookSubscriptionRepository{
+		Repository: NewRepository[types.WebhookSubscription](db),
+	}
+}
+
+// LoadForEvent returns the subscriptions of a user that are subscribed to the event
+func (r *WebhookSubscriptionRepository) LoadForEvent(userID string, event types.WebhookEvent) ([]types.WebhookSubscription, error) {
+	events, err := json.Marshal([]types.WebhookEvent{event})
+	if err != nil {
+		return nil, err
+	}
+	var subscriptions []types.WebhookSubscription
+	err = r.db.Where("user_id = ? AND events @> ?::jsonb", userID, string(events)).Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+type WebhookDeliveryRepository struct {
+	*Repository[types.WebhookDelivery]
+}
+
+func NewWebhookDeliveryRepository(db *gorm.DB) *WebhookDeliveryRepository {
+	return &WebhookDeliveryRepository{
+		Repository: NewRepository[types.WebhookDelivery](db),
+	}
+}
+
+// RecordAttempt logs the outcome of sending the delivery
+// status is the delivery's status after the attempt and delivered_at is set once it succeeds
+func (r *WebhookDeliveryRepository) RecordAttempt(delivery *types.WebhookDelivery) error {
+	updates := map[string]any{
+		"status":          delivery.Status,
+		"attempts":        gorm.Expr("attempts + 1"),
+		"response_status": delivery.ResponseStatus,
+		"response_body":   delivery.ResponseBody,
+		"error":           delivery.Error,
+	}
+	if delivery.Status == types.WebhookDeliveryStatusSucceeded {
+		updates["delivered_at"] = time.Now().Unix()
+	}
+	return r.db.Model(&types.WebhookDelivery{}).
+		Where("id = ?", delivery.ID).
+		UpdateColumns(updates).Error
+}
@@ -1,5 +1,7 @@
 package types
 
+import "encoding/json"
+
 type User struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
@@ -12,11 +14,170 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// RegisterRequest represents the expected request body for registering a new account
+type RegisterRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
 // LoginResponse represents the response body for successful login
+// Token is a short lived access token, exchange RefreshToken at /user/refresh for a new pair
+// before ExpiresAt (unix seconds)
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+// RefreshRequest represents the expected request body for refreshing an access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// CreateAPIKeyRequest represents the expected request body for creating an API key
+// Scopes default to every role the user has
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreateAPIKeyResponse is returned once when an API key is created
+// Key is the only time the secret is shown - it cannot be recovered later
+type CreateAPIKeyResponse struct {
+	Key    string `json:"key"`
+	APIKey APIKey `json:"api_key"`
 }
 
 type UserStatusResponse struct {
 	UserID string `json:"user_id"`
 }
+
+// Job is the api representation of a background job
+// Timestamps are unix seconds with 0 meaning not set
+type Job struct {
+	ID          int64           `json:"id"`
+	Kind        string          `json:"kind"`
+	State       string          `json:"state"`
+	Queue       string          `json:"queue"`
+	Attempt     int             `json:"attempt"`
+	MaxAttempts int             `json:"max_attempts"`
+	Args        json.RawMessage `json:"args" ts_type:"any"`
+	Errors      []JobError      `json:"errors"`
+	CreatedAt   int64           `json:"created_at"`
+	ScheduledAt int64           `json:"scheduled_at"`
+	AttemptedAt int64           `json:"attempted_at"`
+	FinalizedAt int64           `json:"finalized_at"`
+}
+
+// JobError is the error recorded for a failed job attempt
+type JobError struct {
+	Attempt int    `json:"attempt"`
+	At      int64  `json:"at"`
+	Error   string `json:"error"`
+	Trace   string `json:"trace"`
+}
+
+// JobListResponse is a page of jobs, pass NextCursor as ?cursor= to get the next page
+type JobListResponse struct {
+	Jobs       []Job  `json:"jobs"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ReorderRequest sets the order of the children of a resource
+// IDs must list every child exactly once in the new order
+type ReorderRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// JSONPatchOperation is one operation of an RFC 6902 JSON Patch
+// the body of a PATCH sent as application/json-patch+json is a list of them
+// Op is add, remove, replace, move, copy or test, Path and From are JSON Pointers (e.g. /config/name)
+type JSONPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty" ts_type:"any"`
+}
+
+// ComicCreateRequest is the request body for creating a new comic
+// ID, UserID, CreatedAt, and UpdatedAt are not included as they're set by the system
+type ComicCreateRequest struct {
+	Config *ComicConfig `json:"config" validate:"required"`
+}
+
+// ComicUpdateRequest is the request body for updating a comic
+// ID, UserID, CreatedAt, and UpdatedAt are not included as they're managed by the system
+type ComicUpdateRequest struct {
+	Config *ComicConfig `json:"config" validate:"required"`
+}
+
+// PageCreateRequest is the request body for adding a page to a comic
+// The page is added at the end unless Position is given, pages at or after it move down one
+type PageCreateRequest struct {
+	Position *int   `json:"position" validate:"omitempty,min=0"`
+	Layout   string `json:"layout" validate:"max=64,pattern=^[a-z0-9_-]*$"`
+}
+
+// PageUpdateRequest is the request body for updating a page
+// Pages are moved with the reorder endpoint rather than by updating Position
+type PageUpdateRequest struct {
+	Layout string `json:"layout" validate:"max=64,pattern=^[a-z0-9_-]*$"`
+}
+
+// PanelCreateRequest is the request body for adding a panel to a page
+// The panel is added at the end unless Position is given, panels at or after it move down one
+type PanelCreateRequest struct {
+	Position *int              `json:"position" validate:"omitempty,min=0"`
+	Layout   *PanelLayout      `json:"layout"`
+	Caption  string            `json:"caption" validate:"max=1000"`
+	Dialogue []DialogueBalloon `json:"dialogue" validate:"max=20,dive"`
+	ImageURL string            `json:"image_url" validate:"omitempty,url"`
+}
+
+// PanelUpdateRequest is the request body for updating a panel
+// Panels are moved with the reorder endpoint rather than by updating Position
+type PanelUpdateRequest struct {
+	Layout   *PanelLayout      `json:"layout"`
+	Caption  string            `json:"caption" validate:"max=1000"`
+	Dialogue []DialogueBalloon `json:"dialogue" validate:"max=20,dive"`
+	ImageURL string            `json:"image_url" validate:"omitempty,url"`
+}
+
+// WebhookSubscriptionCreateRequest is the request body for subscribing a URL to events
+type WebhookSubscriptionCreateRequest struct {
+	URL    string         `json:"url" validate:"required,url,max=2048"`
+	Secret string         `json:"secret" validate:"required,min=16,max=255"`
+	Events []WebhookEvent `json:"events" validate:"required,min=1,max=20,dive,webhook_event"`
+}
+
+// WebhookSubscriptionUpdateRequest is the request body for updating a webhook subscription
+// The secret is kept unless a new one is given
+type WebhookSubscriptionUpdateRequest struct {
+	URL    string         `json:"url" validate:"required,url,max=2048"`
+	Secret string         `json:"secret" validate:"omitempty,min=16,max=255"`
+	Events []WebhookEvent `json:"events" validate:"required,min=1,max=20,dive,webhook_event"`
+}
+
+// FieldError is a request field that failed validation
+// Field is the JSON path of the field (e.g. config.name or dialogue[0].text)
+// and Rule is the validate tag that failed with its Param (e.g. max and 255)
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Problem is the RFC 7807 body (application/problem+json) of every error response
+// Code is the stable ErrorCode to switch on, RequestID matches the X-Request-ID header
+// and Fields lists the failed fields when the code is VALIDATION_FAILED
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      ErrorCode    `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+}
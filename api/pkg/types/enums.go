@@ -14,3 +14,276 @@ var AllComicTypes = []struct {
 	{ConfigTypePreview, "preview"},
 	{ConfigTypePublished, "published"},
 }
+
+type JobResultType string
+
+const (
+	JobResultTypeTest  JobResultType = "test"
+	JobResultTypeError JobResultType = "error"
+	JobResultTypePanic JobResultType = "panic"
+)
+
+var AllJobResultTypes = []struct {
+	Value  JobResultType
+	TSName string
+}{
+	{JobResultTypeTest, "test"},
+	{JobResultTypeError, "error"},
+	{JobResultTypePanic, "panic"},
+}
+
+type BalloonType string
+
+const (
+	BalloonTypeSpeech  BalloonType = "speech"
+	BalloonTypeThought BalloonType = "thought"
+	BalloonTypeShout   BalloonType = "shout"
+	BalloonTypeWhisper BalloonType = "whisper"
+)
+
+var AllBalloonTypes = []struct {
+	Value  BalloonType
+	TSName string
+}{
+	{BalloonTypeSpeech, "speech"},
+	{BalloonTypeThought, "thought"},
+	{BalloonTypeShout, "shout"},
+	{BalloonTypeWhisper, "whisper"},
+}
+
+// AuditAction is what a mutating API call recorded in the audit log did
+// Calls that don't say what they changed are recorded as a request
+type AuditAction string
+
+const (
+	AuditActionRequest AuditAction = "request"
+	AuditActionCreate  AuditAction = "create"
+	AuditActionUpdate  AuditAction = "update"
+	AuditActionDelete  AuditAction = "delete"
+	AuditActionRestore AuditAction = "restore"
+	AuditActionLogin   AuditAction = "login"
+	AuditActionLogout  AuditAction = "logout"
+)
+
+var AllAuditActions = []struct {
+	Value  AuditAction
+	TSName string
+}{
+	{AuditActionRequest, "request"},
+	{AuditActionCreate, "create"},
+	{AuditActionUpdate, "update"},
+	{AuditActionDelete, "delete"},
+	{AuditActionRestore, "restore"},
+	{AuditActionLogin, "login"},
+	{AuditActionLogout, "logout"},
+}
+
+// WebhookEvent is a resource lifecycle event webhook subscriptions can be sent
+type WebhookEvent string
+
+const (
+	WebhookEventComicCreated     WebhookEvent = "comic.created"
+	WebhookEventComicUpdated     WebhookEvent = "comic.updated"
+	WebhookEventComicDeleted     WebhookEvent = "comic.deleted"
+	WebhookEventComicRestored    WebhookEvent = "comic.restored"
+	WebhookEventComicPublished   WebhookEvent = "comic.published"
+	WebhookEventComicUnpublished WebhookEvent = "comic.unpublished"
+)
+
+var AllWebhookEvents = []struct {
+	Value  WebhookEvent
+	TSName string
+}{
+	{WebhookEventComicCreated, "comic_created"},
+	{WebhookEventComicUpdated, "comic_updated"},
+	{WebhookEventComicDeleted, "comic_deleted"},
+	{WebhookEventComicRestored, "comic_restored"},
+	{WebhookEventComicPublished, "comic_published"},
+	{WebhookEventComicUnpublished, "comic_unpublished"},
+}
+
+// WebhookDeliveryStatus is where a webhook delivery is up to
+// A pending delivery is still being attempted, failed ones ran out of attempts
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "failed"
+)
+
+var AllWebhookDeliveryStatuses = []struct {
+	Value  WebhookDeliveryStatus
+	TSName string
+}{
+	{WebhookDeliveryStatusPending, "pending"},
+	{WebhookDeliveryStatusSucceeded, "succeeded"},
+	{WebhookDeliveryStatusFailed, "failed"},
+}
+
+// ChangeEventKind is what a change event streamed from /events is about
+type ChangeEventKind string
+
+const (
+	ChangeEventKindResource ChangeEventKind = "resource"
+	ChangeEventKindJob      ChangeEventKind = "job"
+)
+
+var AllChangeEventKinds = []struct {
+	Value  ChangeEventKind
+	TSName string
+}{
+	{ChangeEventKindResource, "resource"},
+	{ChangeEventKindJob, "job"},
+}
+
+// ErrorCode is the stable machine readable code of an API error
+// Clients should switch on the code rather than the status or the message
+type ErrorCode string
+
+const (
+	ErrorCodeInternalError         ErrorCode = "INTERNAL_ERROR"
+	ErrorCodeNotFound              ErrorCode = "NOT_FOUND"
+	ErrorCodeMethodNotAllowed      ErrorCode = "METHOD_NOT_ALLOWED"
+	ErrorCodeRequestTooLarge       ErrorCode = "REQUEST_TOO_LARGE"
+	ErrorCodeUnauthorized          ErrorCode = "UNAUTHORIZED"
+	ErrorCodeForbidden             ErrorCode = "FORBIDDEN"
+	ErrorCodePermissionCheckFailed ErrorCode = "PERMISSION_CHECK_FAILED"
+	ErrorCodeInvalidRequest        ErrorCode = "INVALID_REQUEST"
+	ErrorCodeInvalidQuery          ErrorCode = "INVALID_QUERY"
+	ErrorCodeInvalidID             ErrorCode = "INVALID_ID"
+	ErrorCodeMissingID             ErrorCode = "MISSING_ID"
+	ErrorCodeValidationFailed      ErrorCode = "VALIDATION_FAILED"
+	ErrorCodeValidationError       ErrorCode = "VALIDATION_ERROR"
+	ErrorCodeMappingFailed         ErrorCode = "MAPPING_FAILED"
+	ErrorCodeUnsupportedMediaType  ErrorCode = "UNSUPPORTED_MEDIA_TYPE"
+	ErrorCodeInvalidPatch          ErrorCode = "INVALID_PATCH"
+	ErrorCodePatchTestFailed       ErrorCode = "PATCH_TEST_FAILED"
+	ErrorCodeReadOnlyField         ErrorCode = "READ_ONLY_FIELD"
+	ErrorCodePreconditionFailed    ErrorCode = "PRECONDITION_FAILED"
+	ErrorCodeVersionConflict       ErrorCode = "VERSION_CONFLICT"
+	ErrorCodeBeforeCreateFailed    ErrorCode = "BEFORE_CREATE_FAILED"
+	ErrorCodeBeforeUpdateFailed    ErrorCode = "BEFORE_UPDATE_FAILED"
+	ErrorCodeBeforeDeleteFailed    ErrorCode = "BEFORE_DELETE_FAILED"
+	ErrorCodeParentNotFound        ErrorCode = "PARENT_NOT_FOUND"
+	ErrorCodeListFailed            ErrorCode = "LIST_FAILED"
+	ErrorCodeLoadFailed            ErrorCode = "LOAD_FAILED"
+	ErrorCodeCreateFailed          ErrorCode = "CREATE_FAILED"
+	ErrorCodeUpdateFailed          ErrorCode = "UPDATE_FAILED"
+	ErrorCodeDeleteFailed          ErrorCode = "DELETE_FAILED"
+	ErrorCodeRestoreFailed         ErrorCode = "RESTORE_FAILED"
+	ErrorCodeInvalidRevision       ErrorCode = "INVALID_REVISION"
+	ErrorCodeRevisionsFailed       ErrorCode = "REVISIONS_FAILED"
+	ErrorCodeRegistrationDisabled  ErrorCode = "REGISTRATION_DISABLED"
+	ErrorCodeMissingCredentials    ErrorCode = "MISSING_CREDENTIALS"
+	ErrorCodePasswordTooShort      ErrorCode = "PASSWORD_TOO_SHORT"
+	ErrorCodeEmailTaken            ErrorCode = "EMAIL_TAKEN"
+	ErrorCodeRegisterFailed        ErrorCode = "REGISTER_FAILED"
+	ErrorCodeInvalidCredentials    ErrorCode = "INVALID_CREDENTIALS"
+	ErrorCodeLoginFailed           ErrorCode = "LOGIN_FAILED"
+	ErrorCodeTokenFailed           ErrorCode = "TOKEN_FAILED"
+	ErrorCodeInvalidRefreshToken   ErrorCode = "INVALID_REFRESH_TOKEN"
+	ErrorCodeRefreshFailed         ErrorCode = "REFRESH_FAILED"
+	ErrorCodeLogoutFailed          ErrorCode = "LOGOUT_FAILED"
+	ErrorCodeInvalidScope          ErrorCode = "INVALID_SCOPE"
+	ErrorCodeLoadAPIKeysFailed     ErrorCode = "LOAD_API_KEYS_FAILED"
+	ErrorCodeCreateAPIKeyFailed    ErrorCode = "CREATE_API_KEY_FAILED"
+	ErrorCodeRevokeAPIKeyFailed    ErrorCode = "REVOKE_API_KEY_FAILED"
+	ErrorCodeMissingUserID         ErrorCode = "MISSING_USER_ID"
+	ErrorCodeLoadUserComicsFailed  ErrorCode = "LOAD_USER_COMICS_FAILED"
+	ErrorCodeInvalidComic          ErrorCode = "INVALID_COMIC"
+	ErrorCodeEnqueueFailed         ErrorCode = "ENQUEUE_FAILED"
+	ErrorCodePublishFailed         ErrorCode = "PUBLISH_FAILED"
+	ErrorCodeUnpublishFailed       ErrorCode = "UNPUBLISH_FAILED"
+	ErrorCodeNotPublished          ErrorCode = "NOT_PUBLISHED"
+	ErrorCodeLoadVersionsFailed    ErrorCode = "LOAD_VERSIONS_FAILED"
+	ErrorCodeInvalidOrder          ErrorCode = "INVALID_ORDER"
+	ErrorCodeReorderFailed         ErrorCode = "REORDER_FAILED"
+	ErrorCodeLoadDeliveriesFailed  ErrorCode = "LOAD_DELIVERIES_FAILED"
+	ErrorCodeRedeliverFailed       ErrorCode = "REDELIVER_FAILED"
+	ErrorCodeInvalidEventID        ErrorCode = "INVALID_EVENT_ID"
+	ErrorCodeListJobsFailed        ErrorCode = "LIST_JOBS_FAILED"
+	ErrorCodeJobRunning            ErrorCode = "JOB_RUNNING"
+	ErrorCodeJobGetFailed          ErrorCode = "JOB_GET_FAILED"
+	ErrorCodeJobCancelFailed       ErrorCode = "JOB_CANCEL_FAILED"
+	ErrorCodeJobRetryFailed        ErrorCode = "JOB_RETRY_FAILED"
+	ErrorCodeJobDeleteFailed       ErrorCode = "JOB_DELETE_FAILED"
+	ErrorCodeLoadJobResultsFailed  ErrorCode = "LOAD_JOB_RESULTS_FAILED"
+	ErrorCodeMissingJobID          ErrorCode = "MISSING_JOB_ID"
+	ErrorCodeSaveJobResultFailed   ErrorCode = "SAVE_JOB_RESULT_FAILED"
+)
+
+var AllErrorCodes = []struct {
+	Value  ErrorCode
+	TSName string
+}{
+	{ErrorCodeInternalError, "INTERNAL_ERROR"},
+	{ErrorCodeNotFound, "NOT_FOUND"},
+	{ErrorCodeMethodNotAllowed, "METHOD_NOT_ALLOWED"},
+	{ErrorCodeRequestTooLarge, "REQUEST_TOO_LARGE"},
+	{ErrorCodeUnauthorized, "UNAUTHORIZED"},
+	{ErrorCodeForbidden, "FORBIDDEN"},
+	{ErrorCodePermissionCheckFailed, "PERMISSION_CHECK_FAILED"},
+	{ErrorCodeInvalidRequest, "INVALID_REQUEST"},
+	{ErrorCodeInvalidQuery, "INVALID_QUERY"},
+	{ErrorCodeInvalidID, "INVALID_ID"},
+	{ErrorCodeMissingID, "MISSING_ID"},
+	{ErrorCodeValidationFailed, "VALIDATION_FAILED"},
+	{ErrorCodeValidationError, "VALIDATION_ERROR"},
+	{ErrorCodeMappingFailed, "MAPPING_FAILED"},
+	{ErrorCodeUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE"},
+	{ErrorCodeInvalidPatch, "INVALID_PATCH"},
+	{ErrorCodePatchTestFailed, "PATCH_TEST_FAILED"},
+	{ErrorCodeReadOnlyField, "READ_ONLY_FIELD"},
+	{ErrorCodePreconditionFailed, "PRECONDITION_FAILED"},
+	{ErrorCodeVersionConflict, "VERSION_CONFLICT"},
+	{ErrorCodeBeforeCreateFailed, "BEFORE_CREATE_FAILED"},
+	{ErrorCodeBeforeUpdateFailed, "BEFORE_UPDATE_FAILED"},
+	{ErrorCodeBeforeDeleteFailed, "BEFORE_DELETE_FAILED"},
+	{ErrorCodeParentNotFound, "PARENT_NOT_FOUND"},
+	{ErrorCodeListFailed, "LIST_FAILED"},
+	{ErrorCodeLoadFailed, "LOAD_FAILED"},
+	{ErrorCodeCreateFailed, "CREATE_FAILED"},
+	{ErrorCodeUpdateFailed, "UPDATE_FAILED"},
+	{ErrorCodeDeleteFailed, "DELETE_FAILED"},
+	{ErrorCodeRestoreFailed, "RESTORE_FAILED"},
+	{ErrorCodeInvalidRevision, "INVALID_REVISION"},
+	{ErrorCodeRevisionsFailed, "REVISIONS_FAILED"},
+	{ErrorCodeRegistrationDisabled, "REGISTRATION_DISABLED"},
+	{ErrorCodeMissingCredentials, "MISSING_CREDENTIALS"},
+	{ErrorCodePasswordTooShort, "PASSWORD_TOO_SHORT"},
+	{ErrorCodeEmailTaken, "EMAIL_TAKEN"},
+	{ErrorCodeRegisterFailed, "REGISTER_FAILED"},
+	{ErrorCodeInvalidCredentials, "INVALID_CREDENTIALS"},
+	{ErrorCodeLoginFailed, "LOGIN_FAILED"},
+	{ErrorCodeTokenFailed, "TOKEN_FAILED"},
+	{ErrorCodeInvalidRefreshToken, "INVALID_REFRESH_TOKEN"},
+	{ErrorCodeRefreshFailed, "REFRESH_FAILED"},
+	{ErrorCodeLogoutFailed, "LOGOUT_FAILED"},
+	{ErrorCodeInvalidScope, "INVALID_SCOPE"},
+	{ErrorCodeLoadAPIKeysFailed, "LOAD_API_KEYS_FAILED"},
+	{ErrorCodeCreateAPIKeyFailed, "CREATE_API_KEY_FAILED"},
+	{ErrorCodeRevokeAPIKeyFailed, "REVOKE_API_KEY_FAILED"},
+	{ErrorCodeMissingUserID, "MISSING_USER_ID"},
+	{ErrorCodeLoadUserComicsFailed, "LOAD_USER_COMICS_FAILED"},
+	{ErrorCodeInvalidComic, "INVALID_COMIC"},
+	{ErrorCodeEnqueueFailed, "ENQUEUE_FAILED"},
+	{ErrorCodePublishFailed, "PUBLISH_FAILED"},
+	{ErrorCodeUnpublishFailed, "UNPUBLISH_FAILED"},
+	{ErrorCodeNotPublished, "NOT_PUBLISHED"},
+	{ErrorCodeLoadVersionsFailed, "LOAD_VERSIONS_FAILED"},
+	{ErrorCodeInvalidOrder, "INVALID_ORDER"},
+	{ErrorCodeReorderFailed, "REORDER_FAILED"},
+	{ErrorCodeLoadDeliveriesFailed, "LOAD_DELIVERIES_FAILED"},
+	{ErrorCodeRedeliverFailed, "REDELIVER_FAILED"},
+	{ErrorCodeInvalidEventID, "INVALID_EVENT_ID"},
+	{ErrorCodeListJobsFailed, "LIST_JOBS_FAILED"},
+	{ErrorCodeJobRunning, "JOB_RUNNING"},
+	{ErrorCodeJobGetFailed, "JOB_GET_FAILED"},
+	{ErrorCodeJobCancelFailed, "JOB_CANCEL_FAILED"},
+	{ErrorCodeJobRetryFailed, "JOB_RETRY_FAILED"},
+	{ErrorCodeJobDeleteFailed, "JOB_DELETE_FAILED"},
+	{ErrorCodeLoadJobResultsFailed, "LOAD_JOB_RESULTS_FAILED"},
+	{ErrorCodeMissingJobID, "MISSING_JOB_ID"},
+	{ErrorCodeSaveJobResultFailed, "SAVE_JOB_RESULT_FAILED"},
+}
@@ -1,14 +1,267 @@
 package types
 
+import "encoding/json"
+
 type ComicConfig struct {
-	Name        string `json:"name" gorm:"type:varchar(255);not null"`
-	Description string `json:"description" gorm:"type:text"`
+	Name        string `json:"name" gorm:"type:varchar(255);not null" validate:"required,max=255"`
+	Description string `json:"description" gorm:"type:text" validate:"max=10000"`
 }
 
+// Comic is a comic owned by a user
+// Status is preview until the comic is published and only published comics are public,
+// PublishedVersion is the ComicVersion shown publicly (0 if never published)
+// Version is incremented by every change and is sent as the ETag of the comic
+// DeletedAt is when the comic was moved to the trash, 0 if it is not in the trash
 type Comic struct {
-	ID        string       `json:"id" gorm:"primaryKey;type:varchar(36)"`
-	UserID    string       `json:"user_id" gorm:"type:varchar(36);not null"`
-	CreatedAt int64        `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt int64        `json:"updated_at" gorm:"autoUpdateTime"`
-	Config    *ComicConfig `json:"config" gorm:"type:jsonb"`
+	ID               string       `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID           string       `json:"user_id" gorm:"type:varchar(36);not null"`
+	CreatedAt        int64        `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        int64        `json:"updated_at" gorm:"autoUpdateTime"`
+	Config           *ComicConfig `json:"config" gorm:"type:jsonb"`
+	Script           *ComicScript `json:"script,omitempty" gorm:"serializer:json;type:jsonb"`
+	Status           ComicType    `json:"status" gorm:"not null;default:0"`
+	PublishedVersion int          `json:"published_version" gorm:"not null;default:0"`
+	Version          int64        `json:"version" gorm:"not null;default:1"`
+	DeletedAt        int64        `json:"deleted_at" gorm:"not null;default:0"`
+}
+
+// ComicVersion is an immutable snapshot of a comic taken when it is published
+type ComicVersion struct {
+	ID        string         `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ComicID   string         `json:"comic_id" gorm:"type:varchar(36);not null;index"`
+	Version   int            `json:"version" gorm:"not null"`
+	Snapshot  *ComicSnapshot `json:"snapshot" gorm:"serializer:json;type:jsonb"`
+	CreatedAt int64          `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// Revision is the JSON of an entity as it was before one of its updates
+// Resource is the entity's table and Number counts the entity's revisions from 1,
+// UserID is the user that made the update (empty if it was not made by a user)
+type Revision struct {
+	ID        string          `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Resource  string          `json:"resource" gorm:"type:varchar(64);not null"`
+	EntityID  string          `json:"entity_id" gorm:"type:varchar(36);not null"`
+	Number    int             `json:"number" gorm:"not null"`
+	UserID    string          `json:"user_id" gorm:"type:varchar(36)"`
+	Snapshot  json.RawMessage `json:"snapshot" gorm:"type:jsonb" ts_type:"any"`
+	CreatedAt int64           `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// ComicSnapshot is the content of a comic at the time it was published
+type ComicSnapshot struct {
+	Config *ComicConfig   `json:"config"`
+	Script *ComicScript   `json:"script,omitempty"`
+	Pages  []PageSnapshot `json:"pages"`
+}
+
+// PageSnapshot is a page and its panels in order
+type PageSnapshot struct {
+	Page   Page    `json:"page"`
+	Panels []Panel `json:"panels"`
+}
+
+// ComicScript is the script generated for a comic by the generate_comic job
+type ComicScript struct {
+	Title    string             `json:"title"`
+	Synopsis string             `json:"synopsis"`
+	Panels   []ComicScriptPanel `json:"panels"`
+}
+
+// ComicScriptPanel is a single panel of a generated comic script
+type ComicScriptPanel struct {
+	Description string              `json:"description"`
+	Caption     string              `json:"caption,omitempty"`
+	Dialogue    []ComicDialogueLine `json:"dialogue"`
+}
+
+// ComicDialogueLine is a line spoken by a character in a panel
+type ComicDialogueLine struct {
+	Character string `json:"character"`
+	Text      string `json:"text"`
+}
+
+// Page is a single page of a comic, pages are ordered by Position starting at 0
+// Version is incremented by every change and is sent as the ETag of the page
+type Page struct {
+	ID        string `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ComicID   string `json:"comic_id" gorm:"type:varchar(36);not null;index"`
+	Position  int    `json:"position" gorm:"not null;default:0"`
+	Layout    string `json:"layout" gorm:"type:varchar(64)"`
+	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt int64  `json:"updated_at" gorm:"autoUpdateTime"`
+	Version   int64  `json:"version" gorm:"not null;default:1"`
+}
+
+// Panel is a single panel on a page, panels are ordered by Position starting at 0
+// Version is incremented by every change and is sent as the ETag of the panel
+type Panel struct {
+	ID        string            `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	PageID    string            `json:"page_id" gorm:"type:varchar(36);not null;index"`
+	Position  int               `json:"position" gorm:"not null;default:0"`
+	Layout    *PanelLayout      `json:"layout,omitempty" gorm:"serializer:json;type:jsonb"`
+	Caption   string            `json:"caption" gorm:"type:text"`
+	Dialogue  []DialogueBalloon `json:"dialogue" gorm:"serializer:json;type:jsonb"`
+	ImageURL  string            `json:"image_url" gorm:"type:text"`
+	CreatedAt int64             `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt int64             `json:"updated_at" gorm:"autoUpdateTime"`
+	Version   int64             `json:"version" gorm:"not null;default:1"`
+}
+
+// PanelLayout is where a panel sits on its page as fractions (0-1) of the page size
+type PanelLayout struct {
+	X      float64 `json:"x" validate:"min=0,max=1"`
+	Y      float64 `json:"y" validate:"min=0,max=1"`
+	Width  float64 `json:"width" validate:"gt=0,max=1"`
+	Height float64 `json:"height" validate:"gt=0,max=1"`
+}
+
+// DialogueBalloon is a balloon of text spoken or thought by a character in a panel
+type DialogueBalloon struct {
+	Character string      `json:"character" validate:"max=255"`
+	Text      string      `json:"text" validate:"required,max=1000"`
+	Type      BalloonType `json:"type" validate:"omitempty,balloon_type"`
+}
+
+// UserAccount is a registered user stored in the users table
+type UserAccount struct {
+	ID           string   `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Email        string   `json:"email" gorm:"type:varchar(255);uniqueIndex;not null"`
+	PasswordHash string   `json:"-" gorm:"type:varchar(255);not null"`
+	Roles        []string `json:"roles" gorm:"serializer:json;type:jsonb"`
+	CreatedAt    int64    `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    int64    `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (UserAccount) TableName() string {
+	return "users"
+}
+
+// RefreshToken is a single use refresh token, only the SHA-256 hash of the token is stored
+// Every token issued from one login shares a FamilyID so a replayed token can revoke the whole session
+type RefreshToken struct {
+	ID        string   `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	FamilyID  string   `json:"family_id" gorm:"type:varchar(36);not null;index"`
+	UserID    string   `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	Roles     []string `json:"roles" gorm:"serializer:json;type:jsonb"`
+	TokenHash string   `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt int64    `json:"expires_at" gorm:"not null"`
+	UsedAt    int64    `json:"used_at" gorm:"not null;default:0"`
+	RevokedAt int64    `json:"revoked_at" gorm:"not null;default:0"`
+	CreatedAt int64    `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// RevokedAccessToken blacklists the jti of an access token until it expires
+type RevokedAccessToken struct {
+	JTI       string `json:"jti" gorm:"primaryKey;type:varchar(36)"`
+	ExpiresAt int64  `json:"expires_at" gorm:"not null;index"`
+	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// APIKey is a long lived key a user creates for scripts and CI
+// Only the SHA-256 hash of the key is stored, Prefix is kept so the user can tell keys apart
+// Scopes are the roles the key may use - a key never has a role its user doesn't have
+type APIKey struct {
+	ID         string   `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID     string   `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	Name       string   `json:"name" gorm:"type:varchar(255);not null"`
+	Prefix     string   `json:"prefix" gorm:"type:varchar(16);not null"`
+	KeyHash    string   `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	Scopes     []string `json:"scopes" gorm:"serializer:json;type:jsonb"`
+	LastUsedAt int64    `json:"last_used_at" gorm:"not null;default:0"`
+	RevokedAt  int64    `json:"revoked_at" gorm:"not null;default:0"`
+	CreatedAt  int64    `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// RolePermission grants a permission to a role
+// A role with any rows in this table uses them instead of its configured permissions
+type RolePermission struct {
+	Role       string `json:"role" gorm:"primaryKey;type:varchar(64)"`
+	Permission string `json:"permission" gorm:"primaryKey;type:varchar(128)"`
+	CreatedAt  int64  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// AuditEvent records a mutating API call - who made it, from where and what it changed
+// Route is the matched route pattern and Status the response status, Resource is the table
+// of the entity the call changed and Before and After its JSON either side of the change
+type AuditEvent struct {
+	ID         string          `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ActorID    string          `json:"actor_id" gorm:"type:varchar(36)"`
+	IP         string          `json:"ip" gorm:"type:varchar(64)"`
+	Method     string          `json:"method" gorm:"type:varchar(16);not null"`
+	Route      string          `json:"route" gorm:"type:varchar(255);not null"`
+	Status     int             `json:"status" gorm:"not null"`
+	Action     AuditAction     `json:"action" gorm:"type:varchar(32);not null"`
+	Resource   string          `json:"resource" gorm:"type:varchar(64)"`
+	ResourceID string          `json:"resource_id" gorm:"type:varchar(36)"`
+	Before     json.RawMessage `json:"before,omitempty" gorm:"type:jsonb" ts_type:"any"`
+	After      json.RawMessage `json:"after,omitempty" gorm:"type:jsonb" ts_type:"any"`
+	RequestID  string          `json:"request_id" gorm:"type:varchar(64)"`
+	CreatedAt  int64           `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// WebhookSubscription sends the events in Events that happen to its user's comics to URL
+// Every delivery body is signed with an HMAC-SHA256 of Secret, which is never returned
+type WebhookSubscription struct {
+	ID        string         `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID    string         `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	URL       string         `json:"url" gorm:"type:text;not null"`
+	Secret    string         `json:"-" gorm:"type:varchar(255);not null"`
+	Events    []WebhookEvent `json:"events" gorm:"serializer:json;type:jsonb"`
+	CreatedAt int64          `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt int64          `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// WebhookDelivery is one event sent to a webhook subscription and the log of sending it
+// Attempts counts the requests made so far and ResponseStatus, ResponseBody and Error
+// are from the latest one - a redelivery is a new delivery of the same payload
+type WebhookDelivery struct {
+	ID             string                `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	SubscriptionID string                `json:"subscription_id" gorm:"type:varchar(36);not null;index"`
+	Event          WebhookEvent          `json:"event" gorm:"type:varchar(64);not null"`
+	Payload        json.RawMessage       `json:"payload" gorm:"type:jsonb;not null" ts_type:"any"`
+	Status         WebhookDeliveryStatus `json:"status" gorm:"type:varchar(16);not null"`
+	Attempts       int                   `json:"attempts" gorm:"not null;default:0"`
+	ResponseStatus int                   `json:"response_status" gorm:"not null;default:0"`
+	ResponseBody   string                `json:"response_body" gorm:"type:text"`
+	Error          string                `json:"error" gorm:"type:text"`
+	RedeliveryOf   string                `json:"redelivery_of,omitempty" gorm:"type:varchar(36)"`
+	JobID          int64                 `json:"job_id" gorm:"not null;default:0"`
+	CreatedAt      int64                 `json:"created_at" gorm:"autoCreateTime"`
+	DeliveredAt    int64                 `json:"delivered_at" gorm:"not null;default:0"`
+}
+
+// WebhookPayload is the body POSTed to a webhook subscription's URL
+// ID identifies the event and is kept by redeliveries so receivers can skip events
+// they have already handled, Data is the entity the event happened to
+type WebhookPayload struct {
+	ID        string          `json:"id"`
+	Event     WebhookEvent    `json:"event"`
+	CreatedAt int64           `json:"created_at"`
+	Data      json.RawMessage `json:"data" ts_type:"any"`
+}
+
+// ChangeEvent tells a user's clients that something they own changed so they can reload it
+// Resource events name the table, ID and parent ID of the entity and what was done to it,
+// job events the job and the state it moved to - IDs increase across every API replica
+type ChangeEvent struct {
+	ID         int64           `json:"id"`
+	Kind       ChangeEventKind `json:"kind"`
+	UserID     string          `json:"user_id"`
+	Resource   string          `json:"resource,omitempty"`
+	ResourceID string          `json:"resource_id,omitempty"`
+	ParentID   string          `json:"parent_id,omitempty"`
+	Action     AuditAction     `json:"action,omitempty"`
+	JobID      int64           `json:"job_id,omitempty"`
+	JobKind    string          `json:"job_kind,omitempty"`
+	JobState   string          `json:"job_state,omitempty"`
+	CreatedAt  int64           `json:"created_at"`
+}
+
+// JobResult is a payload posted back to the api by a worker when a job finishes
+type JobResult struct {
+	ID        string          `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	JobID     int64           `json:"job_id" gorm:"index;not null"`
+	Type      JobResultType   `json:"type" gorm:"type:varchar(32);not null"`
+	Payload   json.RawMessage `json:"payload" gorm:"type:jsonb" ts_type:"any"`
+	CreatedAt int64           `json:"created_at" gorm:"autoCreateTime"`
 }
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/binocarlos/kai-stack/api/pkg/types"
 	"github.com/tkrajina/typescriptify-golang-structs/typescriptify"
@@ -14,14 +18,102 @@ func main() {
 	converter := typescriptify.New().
 		Add(types.Comic{}).
 		AddEnum(types.AllComicTypes).
+		AddEnum(types.AllJobResultTypes).
+		AddEnum(types.AllBalloonTypes).
+		AddEnum(types.AllAuditActions).
+		AddEnum(types.AllWebhookEvents).
+		AddEnum(types.AllWebhookDeliveryStatuses).
+		AddEnum(types.AllChangeEventKinds).
+		Add(types.Page{}).
+		Add(types.Panel{}).
+		Add(types.ComicVersion{}).
+		Add(types.Revision{}).
+		Add(types.ReorderRequest{}).
+		Add(types.JSONPatchOperation{}).
+		Add(types.ComicCreateRequest{}).
+		Add(types.ComicUpdateRequest{}).
+		Add(types.PageCreateRequest{}).
+		Add(types.PageUpdateRequest{}).
+		Add(types.PanelCreateRequest{}).
+		Add(types.PanelUpdateRequest{}).
+		AddEnum(types.AllErrorCodes).
+		Add(types.FieldError{}).
+		Add(types.Problem{}).
 		Add(types.LoginRequest{}).
+		Add(types.RegisterRequest{}).
 		Add(types.LoginResponse{}).
+		Add(types.RefreshRequest{}).
+		Add(types.CreateAPIKeyRequest{}).
+		Add(types.CreateAPIKeyResponse{}).
 		Add(types.UserStatusResponse{}).
-		Add(types.User{})
+		Add(types.User{}).
+		Add(types.UserAccount{}).
+		Add(types.AuditEvent{}).
+		Add(types.WebhookSubscription{}).
+		Add(types.WebhookSubscriptionCreateRequest{}).
+		Add(types.WebhookSubscriptionUpdateRequest{}).
+		Add(types.WebhookDelivery{}).
+		Add(types.WebhookPayload{}).
+		Add(types.ChangeEvent{}).
+		Add(types.JobResult{}).
+		Add(types.Job{}).
+		Add(types.JobListResponse{})
 	converter.CreateInterface = true
 	converter.BackupDir = ""
+	converter.WithCustomCodeAfter(validationRules(
+		types.ComicCreateRequest{},
+		types.ComicUpdateRequest{},
+		types.PageCreateRequest{},
+		types.PageUpdateRequest{},
+		types.PanelCreateRequest{},
+		types.PanelUpdateRequest{},
+		types.WebhookSubscriptionCreateRequest{},
+		types.WebhookSubscriptionUpdateRequest{},
+	))
 	err := converter.ConvertToFile(*filePath)
 	if err != nil {
 		panic(err.Error())
 	}
 }
+
+// validationRules writes the validate tags of the request types as a TypeScript constant
+// keyed by type name and then by JSON field path (e.g. "config.name" or "dialogue[].text")
+// so the frontend can check forms with the same rules as the api
+func validationRules(values ...interface{}) string {
+	var code strings.Builder
+	code.WriteString("export const ValidationRules: Record<string, Record<string, string>> = {\n")
+	for _, value := range values {
+		t := reflect.TypeOf(value)
+		fmt.Fprintf(&code, "    %s: {\n", t.Name())
+		writeFieldRules(&code, t, "")
+		code.WriteString("    },\n")
+	}
+	code.WriteString("};")
+	return code.String()
+}
+
+func writeFieldRules(code *strings.Builder, t reflect.Type, prefix string) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		path := prefix + name
+		if rules := field.Tag.Get("validate"); rules != "" {
+			quotedPath, _ := json.Marshal(path)
+			quotedRules, _ := json.Marshal(rules)
+			fmt.Fprintf(code, "        %s: %s,\n", quotedPath, quotedRules)
+		}
+		if field.Type.Kind() == reflect.Slice {
+			path += "[]"
+		}
+		writeFieldRules(code, field.Type, path+".")
+	}
+}
@@ -5,6 +5,128 @@ export enum ComicType {
     preview = 0,
     published = 1,
 }
+export enum JobResultType {
+    test = "test",
+    error = "error",
+    panic = "panic",
+}
+export enum BalloonType {
+    speech = "speech",
+    thought = "thought",
+    shout = "shout",
+    whisper = "whisper",
+}
+export enum AuditAction {
+    request = "request",
+    create = "create",
+    update = "update",
+    delete = "delete",
+    restore = "restore",
+    login = "login",
+    logout = "logout",
+}
+export enum WebhookEvent {
+    comic_created = "comic.created",
+    comic_updated = "comic.updated",
+    comic_deleted = "comic.deleted",
+    comic_restored = "comic.restored",
+    comic_published = "comic.published",
+    comic_unpublished = "comic.unpublished",
+}
+export enum WebhookDeliveryStatus {
+    pending = "pending",
+    succeeded = "succeeded",
+    failed = "failed",
+}
+export enum ChangeEventKind {
+    resource = "resource",
+    job = "job",
+}
+export enum ErrorCode {
+    INTERNAL_ERROR = "INTERNAL_ERROR",
+    NOT_FOUND = "NOT_FOUND",
+    METHOD_NOT_ALLOWED = "METHOD_NOT_ALLOWED",
+    REQUEST_TOO_LARGE = "REQUEST_TOO_LARGE",
+    UNAUTHORIZED = "UNAUTHORIZED",
+    FORBIDDEN = "FORBIDDEN",
+    PERMISSION_CHECK_FAILED = "PERMISSION_CHECK_FAILED",
+    INVALID_REQUEST = "INVALID_REQUEST",
+    INVALID_QUERY = "INVALID_QUERY",
+    INVALID_ID = "INVALID_ID",
+    MISSING_ID = "MISSING_ID",
+    VALIDATION_FAILED = "VALIDATION_FAILED",
+    VALIDATION_ERROR = "VALIDATION_ERROR",
+    MAPPING_FAILED = "MAPPING_FAILED",
+    UNSUPPORTED_MEDIA_TYPE = "UNSUPPORTED_MEDIA_TYPE",
+    INVALID_PATCH = "INVALID_PATCH",
+    PATCH_TEST_FAILED = "PATCH_TEST_FAILED",
+    READ_ONLY_FIELD = "READ_ONLY_FIELD",
+    PRECONDITION_FAILED = "PRECONDITION_FAILED",
+    VERSION_CONFLICT = "VERSION_CONFLICT",
+    BEFORE_CREATE_FAILED = "BEFORE_CREATE_FAILED",
+    BEFORE_UPDATE_FAILED = "BEFORE_UPDATE_FAILED",
+    BEFORE_DELETE_FAILED = "BEFORE_DELETE_FAILED",
+    PARENT_NOT_FOUND = "PARENT_NOT_FOUND",
+    LIST_FAILED = "LIST_FAILED",
+    LOAD_FAILED = "LOAD_FAILED",
+    CREATE_FAILED = "CREATE_FAILED",
+    UPDATE_FAILED = "UPDATE_FAILED",
+    DELETE_FAILED = "DELETE_FAILED",
+    RESTORE_FAILED = "RESTORE_FAILED",
+    INVALID_REVISION = "INVALID_REVISION",
+    REVISIONS_FAILED = "REVISIONS_FAILED",
+    REGISTRATION_DISABLED = "REGISTRATION_DISABLED",
+    MISSING_CREDENTIALS = "MISSING_CREDENTIALS",
+    PASSWORD_TOO_SHORT = "PASSWORD_TOO_SHORT",
+    EMAIL_TAKEN = "EMAIL_TAKEN",
+    REGISTER_FAILED = "REGISTER_FAILED",
+    INVALID_CREDENTIALS = "INVALID_CREDENTIALS",
+    LOGIN_FAILED = "LOGIN_FAILED",
+    TOKEN_FAILED = "TOKEN_FAILED",
+    INVALID_REFRESH_TOKEN = "INVALID_REFRESH_TOKEN",
+    REFRESH_FAILED = "REFRESH_FAILED",
+    LOGOUT_FAILED = "LOGOUT_FAILED",
+    INVALID_SCOPE = "INVALID_SCOPE",
+    LOAD_API_KEYS_FAILED = "LOAD_API_KEYS_FAILED",
+    CREATE_API_KEY_FAILED = "CREATE_API_KEY_FAILED",
+    REVOKE_API_KEY_FAILED = "REVOKE_API_KEY_FAILED",
+    MISSING_USER_ID = "MISSING_USER_ID",
+    LOAD_USER_COMICS_FAILED = "LOAD_USER_COMICS_FAILED",
+    INVALID_COMIC = "INVALID_COMIC",
+    ENQUEUE_FAILED = "ENQUEUE_FAILED",
+    PUBLISH_FAILED = "PUBLISH_FAILED",
+    UNPUBLISH_FAILED = "UNPUBLISH_FAILED",
+    NOT_PUBLISHED = "NOT_PUBLISHED",
+    LOAD_VERSIONS_FAILED = "LOAD_VERSIONS_FAILED",
+    INVALID_ORDER = "INVALID_ORDER",
+    REORDER_FAILED = "REORDER_FAILED",
+    LOAD_DELIVERIES_FAILED = "LOAD_DELIVERIES_FAILED",
+    REDELIVER_FAILED = "REDELIVER_FAILED",
+    INVALID_EVENT_ID = "INVALID_EVENT_ID",
+    LIST_JOBS_FAILED = "LIST_JOBS_FAILED",
+    JOB_RUNNING = "JOB_RUNNING",
+    JOB_GET_FAILED = "JOB_GET_FAILED",
+    JOB_CANCEL_FAILED = "JOB_CANCEL_FAILED",
+    JOB_RETRY_FAILED = "JOB_RETRY_FAILED",
+    JOB_DELETE_FAILED = "JOB_DELETE_FAILED",
+    LOAD_JOB_RESULTS_FAILED = "LOAD_JOB_RESULTS_FAILED",
+    MISSING_JOB_ID = "MISSING_JOB_ID",
+    SAVE_JOB_RESULT_FAILED = "SAVE_JOB_RESULT_FAILED",
+}
+export interface ComicDialogueLine {
+    character: string;
+    text: string;
+}
+export interface ComicScriptPanel {
+    description: string;
+    caption?: string;
+    dialogue: ComicDialogueLine[];
+}
+export interface ComicScript {
+    title: string;
+    synopsis: string;
+    panels: ComicScriptPanel[];
+}
 export interface ComicConfig {
     name: string;
     description: string;
@@ -15,13 +137,153 @@ export interface Comic {
     created_at: number;
     updated_at: number;
     config?: ComicConfig;
+    script?: ComicScript;
+    status: ComicType;
+    published_version: number;
+    version: number;
+    deleted_at: number;
+}
+export interface Page {
+    id: string;
+    comic_id: string;
+    position: number;
+    layout: string;
+    created_at: number;
+    updated_at: number;
+    version: number;
+}
+export interface DialogueBalloon {
+    character: string;
+    text: string;
+    type: BalloonType;
+}
+export interface PanelLayout {
+    x: number;
+    y: number;
+    width: number;
+    height: number;
+}
+export interface Panel {
+    id: string;
+    page_id: string;
+    position: number;
+    layout?: PanelLayout;
+    caption: string;
+    dialogue: DialogueBalloon[];
+    image_url: string;
+    created_at: number;
+    updated_at: number;
+    version: number;
+}
+export interface PageSnapshot {
+    page: Page;
+    panels: Panel[];
+}
+export interface ComicSnapshot {
+    config?: ComicConfig;
+    script?: ComicScript;
+    pages: PageSnapshot[];
+}
+export interface ComicVersion {
+    id: string;
+    comic_id: string;
+    version: number;
+    snapshot?: ComicSnapshot;
+    created_at: number;
+}
+export interface Revision {
+    id: string;
+    resource: string;
+    entity_id: string;
+    number: number;
+    user_id: string;
+    snapshot: any;
+    created_at: number;
+}
+export interface ReorderRequest {
+    ids: string[];
+}
+export interface JSONPatchOperation {
+    op: string;
+    path: string;
+    from?: string;
+    value?: any;
+}
+export interface ComicCreateRequest {
+    config?: ComicConfig;
+}
+export interface ComicUpdateRequest {
+    config?: ComicConfig;
+}
+export interface PageCreateRequest {
+    position?: number;
+    layout: string;
+}
+export interface PageUpdateRequest {
+    layout: string;
+}
+export interface PanelCreateRequest {
+    position?: number;
+    layout?: PanelLayout;
+    caption: string;
+    dialogue: DialogueBalloon[];
+    image_url: string;
+}
+export interface PanelUpdateRequest {
+    layout?: PanelLayout;
+    caption: string;
+    dialogue: DialogueBalloon[];
+    image_url: string;
+}
+export interface FieldError {
+    field: string;
+    rule: string;
+    param?: string;
+    message: string;
+}
+export interface Problem {
+    type: string;
+    title: string;
+    status: number;
+    detail?: string;
+    instance?: string;
+    code: ErrorCode;
+    request_id?: string;
+    fields?: FieldError[];
 }
 export interface LoginRequest {
     email: string;
     password: string;
 }
+export interface RegisterRequest {
+    email: string;
+    password: string;
+}
 export interface LoginResponse {
     token: string;
+    refresh_token: string;
+    expires_at: number;
+}
+export interface RefreshRequest {
+    refresh_token: string;
+}
+export interface CreateAPIKeyRequest {
+    name: string;
+    scopes: string[];
+}
+export interface APIKey {
+    id: string;
+    user_id: string;
+    name: string;
+    prefix: string;
+    scopes: string[];
+    last_used_at: number;
+    revoked_at: number;
+    created_at: number;
+}
+export interface CreateAPIKeyResponse {
+    key: string;
+    api_key: APIKey;
 }
 export interface UserStatusResponse {
     user_id: string;
@@ -30,4 +292,165 @@ export interface User {
     user_id: string;
     email: string;
     token: string;
-}
\ No newline at end of file
+}
+export interface UserAccount {
+    id: string;
+    email: string;
+    roles: string[];
+    created_at: number;
+    updated_at: number;
+}
+export interface AuditEvent {
+    id: string;
+    actor_id: string;
+    ip: string;
+    method: string;
+    route: string;
+    status: number;
+    action: AuditAction;
+    resource: string;
+    resource_id: string;
+    before?: any;
+    after?: any;
+    request_id: string;
+    created_at: number;
+}
+export interface WebhookSubscription {
+    id: string;
+    user_id: string;
+    url: string;
+    events: string[];
+    created_at: number;
+    updated_at: number;
+}
+export interface WebhookSubscriptionCreateRequest {
+    url: string;
+    secret: string;
+    events: string[];
+}
+export interface WebhookSubscriptionUpdateRequest {
+    url: string;
+    secret: string;
+    events: string[];
+}
+export interface WebhookDelivery {
+    id: string;
+    subscription_id: string;
+    event: WebhookEvent;
+    payload: any;
+    status: WebhookDeliveryStatus;
+    attempts: number;
+    response_status: number;
+    response_body: string;
+    error: string;
+    redelivery_of?: string;
+    job_id: number;
+    created_at: number;
+    delivered_at: number;
+}
+export interface WebhookPayload {
+    id: string;
+    event: WebhookEvent;
+    created_at: number;
+    data: any;
+}
+export interface ChangeEvent {
+    id: number;
+    kind: ChangeEventKind;
+    user_id: string;
+    resource?: string;
+    resource_id?: string;
+    parent_id?: string;
+    action?: AuditAction;
+    job_id?: number;
+    job_kind?: string;
+    job_state?: string;
+    created_at: number;
+}
+export interface JobResult {
+    id: string;
+    job_id: number;
+    type: JobResultType;
+    payload: any;
+    created_at: number;
+}
+export interface JobError {
+    attempt: number;
+    at: number;
+    error: string;
+    trace: string;
+}
+export interface Job {
+    id: number;
+    kind: string;
+    state: string;
+    queue: string;
+    attempt: number;
+    max_attempts: number;
+    args: any;
+    errors: JobError[];
+    created_at: number;
+    scheduled_at: number;
+    attempted_at: number;
+    finalized_at: number;
+}
+export interface JobListResponse {
+    jobs: Job[];
+    next_cursor?: string;
+}
+
+export const ValidationRules: Record<string, Record<string, string>> = {
+    ComicCreateRequest: {
+        "config": "required",
+        "config.name": "required,max=255",
+        "config.description": "max=10000",
+    },
+    ComicUpdateRequest: {
+        "config": "required",
+        "config.name": "required,max=255",
+        "config.description": "max=10000",
+    },
+    PageCreateRequest: {
+        "position": "omitempty,min=0",
+        "layout": "max=64,pattern=^[a-z0-9_-]*$",
+    },
+    PageUpdateRequest: {
+        "layout": "max=64,pattern=^[a-z0-9_-]*$",
+    },
+    PanelCreateRequest: {
+        "position": "omitempty,min=0",
+        "layout.x": "min=0,max=1",
+        "layout.y": "min=0,max=1",
+        "layout.width": "gt=0,max=1",
+        "layout.height": "gt=0,max=1",
+        "caption": "max=1000",
+        "dialogue": "max=20,dive",
+        "dialogue[].character": "max=255",
+        "dialogue[].text": "required,max=1000",
+        "dialogue[].type": "omitempty,balloon_type",
+        "image_url": "omitempty,url",
+    },
+    PanelUpdateRequest: {
+        "layout.x": "min=0,max=1",
+        "layout.y": "min=0,max=1",
+        "layout.width": "gt=0,max=1",
+        "layout.height": "gt=0,max=1",
+        "caption": "max=1000",
+        "dialogue": "max=20,dive",
+        "dialogue[].character": "max=255",
+        "dialogue[].text": "required,max=1000",
+        "dialogue[].type": "omitempty,balloon_type",
+        "image_url": "omitempty,url",
+    },
+    WebhookSubscriptionCreateRequest: {
+        "url": "required,url,max=2048",
+        "secret": "required,min=16,max=255",
+        "events": "required,min=1,max=20,dive,webhook_event",
+    },
+    WebhookSubscriptionUpdateRequest: {
+        "url": "required,url,max=2048",
+        "secret": "omitempty,min=16,max=255",
+        "events": "required,min=1,max=20,dive,webhook_event",
+    },
+};
+
@@ -1,5 +1,9 @@
 export const extractErrorMessage = (error: any): string => {
   if(error.response && error.response.data) {
+    // errors from the api are application/problem+json (see Problem in gotypes.ts)
+    if (error.response.data.detail || error.response.data.title) {
+      return (error.response.data.detail || error.response.data.title) as string
+    }
     if (error.response.data.message || error.response.data.error) {
       return (error.response.data.message || error.response.data.error) as string
     }